@@ -0,0 +1,159 @@
+// blocking.go provides a q-gram candidate index used to limit fuzzy
+// (Levenshtein) comparisons to the values that could plausibly match.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"sort"
+)
+
+// DefaultQGram is the q-gram length used by NewBlocker when q is less than one
+const DefaultQGram = 2
+
+// posting records how many times a q-gram occurs in an indexed value
+type posting struct {
+	id    int
+	count int
+}
+
+// Blocker is a q-gram index of values that returns the candidates which
+// could be within a maximum number of edit operations of a query value.
+// It uses a length filter (values differing in length by more than the
+// allowed edits can't match) and the q-gram count filter (a value within
+// k edits of another shares at least max(n,m) + q - 1 - k*q of their
+// padded q-grams). Both filters are lossless so blocking never drops a
+// value Levenshtein would have matched.
+type Blocker struct {
+	// Comparisons counts the candidates returned by Candidates and
+	// Pruned counts the indexed values they left out.
+	Comparisons int64
+	Pruned      int64
+
+	q        int
+	maxEdits int
+	ids      []int
+	lengths  map[int][]int
+	postings map[string][]posting
+}
+
+// MaxEdits returns the maximum number of edit operations possible within
+// a Levenshtein distance of maxDistance for the given costs. It returns
+// -1 if the number is unbounded (e.g. a cost of zero).
+func MaxEdits(maxDistance int, insertCost int, deleteCost int, substituteCost int) int {
+	minCost := insertCost
+	if deleteCost < minCost {
+		minCost = deleteCost
+	}
+	if substituteCost < minCost {
+		minCost = substituteCost
+	}
+	if minCost <= 0 || maxDistance < 0 {
+		return -1
+	}
+	return maxDistance / minCost
+}
+
+// NewBlocker creates an empty Blocker for q-grams of length q where
+// matches are at most maxEdits edit operations apart. If maxEdits is
+// negative Candidates returns every indexed value.
+func NewBlocker(q int, maxEdits int) *Blocker {
+	if q < 1 {
+		q = DefaultQGram
+	}
+	return &Blocker{
+		q:        q,
+		maxEdits: maxEdits,
+		lengths:  map[int][]int{},
+		postings: map[string][]posting{},
+	}
+}
+
+// qGrams counts the q-grams of s padded with q-1 sentinel runes on
+// each side. It returns the counts and the length of s in runes.
+func qGrams(s string, q int) (map[string]int, int) {
+	runes := []rune(s)
+	n := len(runes)
+	padded := make([]rune, 0, n+2*(q-1))
+	for i := 0; i < q-1; i++ {
+		padded = append(padded, 0)
+	}
+	padded = append(padded, runes...)
+	for i := 0; i < q-1; i++ {
+		padded = append(padded, 0)
+	}
+	grams := map[string]int{}
+	for i := 0; i+q <= len(padded); i++ {
+		grams[string(padded[i:i+q])]++
+	}
+	return grams, n
+}
+
+// Add indexes val under id. Values should be normalized (e.g. lower
+// cased for case insensitive matching) the same way as the queries.
+func (b *Blocker) Add(id int, val string) {
+	b.ids = append(b.ids, id)
+	grams, n := qGrams(val, b.q)
+	b.lengths[n] = append(b.lengths[n], id)
+	for gram, count := range grams {
+		b.postings[gram] = append(b.postings[gram], posting{id: id, count: count})
+	}
+}
+
+// Size returns the number of values indexed
+func (b *Blocker) Size() int {
+	return len(b.ids)
+}
+
+// Candidates returns the ids, in ascending order, of the indexed values
+// which could be within the maximum edits of val.
+func (b *Blocker) Candidates(val string) []int {
+	var candidates []int
+	if b.maxEdits < 0 {
+		candidates = append(candidates, b.ids...)
+		sort.Ints(candidates)
+		b.Comparisons += int64(len(candidates))
+		return candidates
+	}
+	k := b.maxEdits
+	grams, n := qGrams(val, b.q)
+	// Count the shared q-grams and lengths of the values having any
+	common := map[int]int{}
+	for gram, count := range grams {
+		for _, p := range b.postings[gram] {
+			if p.count < count {
+				common[p.id] += p.count
+			} else {
+				common[p.id] += count
+			}
+		}
+	}
+	seen := map[int]bool{}
+	for m := n - k; m <= n+k; m++ {
+		for _, id := range b.lengths[m] {
+			longest := n
+			if m > longest {
+				longest = m
+			}
+			if common[id] >= longest+b.q-1-k*b.q && !seen[id] {
+				seen[id] = true
+				candidates = append(candidates, id)
+			}
+		}
+	}
+	sort.Ints(candidates)
+	b.Comparisons += int64(len(candidates))
+	b.Pruned += int64(len(b.ids) - len(candidates))
+	return candidates
+}
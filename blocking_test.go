@@ -0,0 +1,77 @@
+package datatools
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMaxEdits(t *testing.T) {
+	if n := MaxEdits(5, 1, 1, 1); n != 5 {
+		t.Errorf("expected 5, got %d", n)
+	}
+	if n := MaxEdits(5, 2, 3, 2); n != 2 {
+		t.Errorf("expected 2, got %d", n)
+	}
+	if n := MaxEdits(5, 0, 1, 1); n != -1 {
+		t.Errorf("expected -1, got %d", n)
+	}
+}
+
+func TestBlocker(t *testing.T) {
+	titles := []string{
+		"the lord of the rings",
+		"the lord of the flies",
+		"a tale of two cities",
+		"the hobbit",
+		"hobbit",
+		"",
+	}
+	b := NewBlocker(2, 2)
+	for i, title := range titles {
+		b.Add(i, title)
+	}
+	if b.Size() != len(titles) {
+		t.Errorf("expected size %d, got %d", len(titles), b.Size())
+	}
+	candidates := b.Candidates("the lord of the ring")
+	if len(candidates) != 1 || candidates[0] != 0 {
+		t.Errorf("expected [0], got %v", candidates)
+	}
+	if b.Comparisons != 1 || b.Pruned != 5 {
+		t.Errorf("expected 1 comparison and 5 pruned, got %d and %d", b.Comparisons, b.Pruned)
+	}
+
+	// Blocking must not drop any value Levenshtein would match
+	r := rand.New(rand.NewSource(1))
+	randomString := func() string {
+		s := make([]rune, r.Intn(12))
+		for i := range s {
+			s[i] = rune('a' + r.Intn(3))
+		}
+		return string(s)
+	}
+	values := make([]string, 200)
+	for i := range values {
+		values[i] = randomString()
+	}
+	for _, k := range []int{0, 1, 2, 4} {
+		for _, q := range []int{1, 2, 3} {
+			b := NewBlocker(q, k)
+			for i, val := range values {
+				b.Add(i, val)
+			}
+			for j := 0; j < 50; j++ {
+				query := randomString()
+				found := map[int]bool{}
+				for _, id := range b.Candidates(query) {
+					found[id] = true
+				}
+				for i, val := range values {
+					if Levenshtein(val, query, 1, 1, 1, true) <= k && !found[i] {
+						t.Errorf("q %d, k %d, %q dropped %q", q, k, query, val)
+					}
+				}
+			}
+		}
+	}
+}
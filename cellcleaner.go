@@ -0,0 +1,192 @@
+// cellcleaner.go provides repairs for the text of CSV cells, Unicode
+// normalization, mojibake, whitespace, control characters and punctuation.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	// 3rd Party packages
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// smartPunctuation maps typographic punctuation to plain ASCII
+	smartPunctuation = strings.NewReplacer(
+		"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+		"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+		"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "―", "-",
+		"…", "...",
+	)
+)
+
+// CellCleaner repairs the text of CSV cells. The repairs are applied
+// in the order mojibake, normalization, control characters,
+// punctuation then whitespace.
+type CellCleaner struct {
+	// Normalization is "NFC" or "NFKC", empty leaves the form unchanged
+	Normalization string
+	// RepairMojibake undoes UTF-8 decoded as Windows-1252 or Latin-1
+	RepairMojibake bool
+	// StripControl removes control characters other than tab, line feed
+	// and carriage return along with stray byte order marks
+	StripControl bool
+	// ReplacePunctuation turns smart quotes, dashes and ellipses into ASCII
+	ReplacePunctuation bool
+	// CollapseSpace turns each run of white space, including non-breaking
+	// spaces, into a single space
+	CollapseSpace bool
+}
+
+// Validate checks the cleaner's Normalization setting
+func (c *CellCleaner) Validate() error {
+	switch strings.ToUpper(c.Normalization) {
+	case "", "NFC", "NFKC":
+		return nil
+	}
+	return fmt.Errorf("unsupported normalization %q, expected NFC or NFKC", c.Normalization)
+}
+
+// Clean returns the repaired cell along with the names of the repairs
+// which changed it ("mojibake", "nfc", "nfkc", "control", "punctuation"
+// and "whitespace").
+func (c *CellCleaner) Clean(s string) (string, []string) {
+	changes := []string{}
+	apply := func(name string, fn func(string) string) {
+		if t := fn(s); t != s {
+			s = t
+			changes = append(changes, name)
+		}
+	}
+	if c.RepairMojibake {
+		apply("mojibake", RepairMojibake)
+	}
+	switch strings.ToUpper(c.Normalization) {
+	case "NFC":
+		apply("nfc", norm.NFC.String)
+	case "NFKC":
+		apply("nfkc", norm.NFKC.String)
+	}
+	if c.StripControl {
+		apply("control", StripControl)
+	}
+	if c.ReplacePunctuation {
+		apply("punctuation", ReplaceSmartPunctuation)
+	}
+	if c.CollapseSpace {
+		apply("whitespace", CollapseSpace)
+	}
+	return s, changes
+}
+
+// RepairMojibake reverses text which was UTF-8 but decoded as
+// Windows-1252 or Latin-1, e.g. "JosÃ©" becomes "José". Each run of
+// non-ASCII characters is turned back into bytes and kept only if
+// they form valid UTF-8 so correctly encoded text is left alone. Text
+// which was mis-decoded twice is repaired too.
+func RepairMojibake(s string) string {
+	for i := 0; i < 2; i++ {
+		t := repairMojibakeRuns(s)
+		if t == s {
+			break
+		}
+		s = t
+	}
+	return s
+}
+
+// repairMojibakeRuns does a single pass of RepairMojibake
+func repairMojibakeRuns(s string) string {
+	var sb strings.Builder
+	run, raw := []byte{}, []rune{}
+	flush := func() {
+		if len(run) > 1 && utf8.Valid(run) {
+			sb.Write(run)
+		} else {
+			sb.WriteString(string(raw))
+		}
+		run, raw = run[:0], raw[:0]
+	}
+	for _, r := range s {
+		b, ok := byte(0), false
+		if r >= 0x80 {
+			b, ok = cp1252Byte(r)
+		}
+		if !ok {
+			flush()
+			sb.WriteRune(r)
+			continue
+		}
+		run = append(run, b)
+		raw = append(raw, r)
+	}
+	flush()
+	return sb.String()
+}
+
+// cp1252Byte returns the Windows-1252 (or Latin-1) byte for r
+func cp1252Byte(r rune) (byte, bool) {
+	if r < 0x100 {
+		return byte(r), true
+	}
+	for i, c := range cp1252 {
+		if c == r && c != utf8.RuneError {
+			return byte(0x80 + i), true
+		}
+	}
+	return 0, false
+}
+
+// StripControl removes control characters, other than tab, line feed
+// and carriage return, and byte order marks from s.
+func StripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case unicode.IsControl(r) || r == '\ufeff':
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// ReplaceSmartPunctuation turns curly quotes, primes, dashes and
+// ellipses into their plain ASCII equivalents.
+func ReplaceSmartPunctuation(s string) string {
+	return smartPunctuation.Replace(s)
+}
+
+// CollapseSpace turns each run of white space in s, including line
+// breaks and non-breaking spaces, into a single space.
+func CollapseSpace(s string) string {
+	var sb strings.Builder
+	inSpace := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !inSpace {
+				sb.WriteByte(' ')
+			}
+			inSpace = true
+			continue
+		}
+		inSpace = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+package datatools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRepairMojibake(t *testing.T) {
+	testData := map[string]string{
+		"JosÃ©":             "José",
+		"ZÃ¼rich, KÃ¶ln":    "Zürich, Köln",
+		"â€œquotedâ€\u009d": "“quoted”",
+		"cafÃƒÂ©":           "café",
+		"José":              "José",
+		"Ä":                 "Ä",
+		"naïve €5":          "naïve €5",
+		"plain ascii":       "plain ascii",
+	}
+	for src, expected := range testData {
+		if got := RepairMojibake(src); got != expected {
+			t.Errorf("expected %q, got %q for %q", expected, got, src)
+		}
+	}
+}
+
+func TestCellCleaner(t *testing.T) {
+	cleaner := &CellCleaner{
+		Normalization:      "nfc",
+		RepairMojibake:     true,
+		StripControl:       true,
+		ReplacePunctuation: true,
+		CollapseSpace:      true,
+	}
+	if err := cleaner.Validate(); err != nil {
+		t.Error(err)
+	}
+	testData := []struct {
+		src      string
+		expected string
+		changes  string
+	}{
+		{"José", "José", "nfc"},
+		{"JosÃ©", "José", "mojibake"},
+		{"a  b\n c", "a b c", "whitespace"},
+		{"\ufeffid\x00\x1b", "id", "control"},
+		{"“Hello” – it’s…", `"Hello" - it's...`, "punctuation"},
+		{"ok", "ok", ""},
+	}
+	for i, test := range testData {
+		got, changes := cleaner.Clean(test.src)
+		if got != test.expected {
+			t.Errorf("(%d) expected %q, got %q", i, test.expected, got)
+		}
+		if s := strings.Join(changes, ";"); s != test.changes {
+			t.Errorf("(%d) expected changes %q, got %q", i, test.changes, s)
+		}
+	}
+
+	// NFKC also folds compatibility characters
+	cleaner = &CellCleaner{Normalization: "NFKC"}
+	if got, _ := cleaner.Clean("ﬁle №１"); got != "file No1" {
+		t.Errorf("expected %q, got %q", "file No1", got)
+	}
+	cleaner = &CellCleaner{Normalization: "NFD"}
+	if err := cleaner.Validate(); err == nil {
+		t.Errorf("expected an error for NFD")
+	}
+}
@@ -31,6 +31,7 @@ import (
 
 	// My packages
 	"github.com/caltechlibrary/datatools"
+	"github.com/caltechlibrary/datatools/filter"
 )
 
 var (
@@ -78,6 +79,17 @@ per line.
 -i, -input
 : input filename
 
+-infer-rows
+: number of rows examined by -infer-types, zero examines all rows
+(default 1000)
+
+-infer-types
+: write numbers, booleans and nulls for columns where the rows examined
+(see -infer-rows) are consistently integers, numbers or booleans, empty
+cells in those columns become null. A later cell that isn't of the
+inferred type is written as text, reported as an error and {app_name}
+exits with 1, increase -infer-rows (or use zero) to avoid this
+
 -nl, -newline
 : include trailing newline in output
 
@@ -90,6 +102,14 @@ per line.
 -reuse-record
 : reuse the backing array
 
+-schema SCHEMA_JSON
+: cast cells using a Frictionless Data Table Schema (see csvvalidate),
+dates and times are written in ISO 8601 and "list" fields are split on
+their delimiter into arrays
+
+-sniff
+: guess the delimiter, header row and character encoding of the input (see csvsniff)
+
 -trim-leading-space
 : trim leading space in fields for CSV input
 
@@ -99,6 +119,9 @@ per line.
 -use-lazy-quotes
 : use lazy quotes for for CSV input
 
+-where
+: only convert rows matching a filter expression (see csvrows for the functions)
+
 
 # EXAMPLES
 
@@ -114,6 +137,12 @@ Convert data1.csv to JSON blobs, one line per blob
     csv2json -as-blobs -i data1.csv
 ~~~
 
+Convert the rows of data1.csv where the "Year" column is after 2017
+
+~~~
+    csv2json -i data1.csv -where '(gt (cols "Year") 2017)'
+~~~
+
 `
 
 	// Standard Options
@@ -131,10 +160,15 @@ Convert data1.csv to JSON blobs, one line per blob
 	asBlobs          bool
 	delimiter        string
 	lazyQuotes       bool
+	sniff            bool
 	trimLeadingSpace bool
 	fieldsPerRecord  int
 	reuseRecord      bool
+	inferTypes       bool
+	inferRows        int
+	schemaFName      string
 	pretty           bool
+	where            string
 )
 
 func main() {
@@ -162,10 +196,15 @@ func main() {
 	flag.StringVar(&delimiter, "d", "", "set the delimter character")
 	flag.StringVar(&delimiter, "delimiter", "", "set the delimter character")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in fields for CSV input")
 	flag.BoolVar(&reuseRecord, "reuse-record", false, "reuse the backing array")
+	flag.BoolVar(&inferTypes, "infer-types", false, "write numbers, booleans and nulls for consistently typed columns")
+	flag.IntVar(&inferRows, "infer-rows", datatools.DefaultInferRows, "number of rows examined by -infer-types, zero examines all rows")
+	flag.StringVar(&schemaFName, "schema", "", "cast cells using a Table Schema")
 	flag.IntVar(&fieldsPerRecord, "fields-per-record", 0, "Set the number of fields expected in the CSV read, -1 to turn off")
 	flag.BoolVar(&pretty, "pretty", false, "pretty print the JSON output")
+	flag.StringVar(&where, "where", "", "only convert rows matching a filter expression")
 
 	// Parse environment and options
 	flag.Parse()
@@ -210,10 +249,30 @@ func main() {
 	if newLine {
 		eol = "\n"
 	}
+	if inferTypes && schemaFName != "" {
+		fmt.Fprintln(eout, "use either -infer-types or -schema, not both")
+		os.Exit(1)
+	}
+
+	var rowFilter *filter.Filter
+	if where != "" {
+		rowFilter, err = filter.Parse(where)
+		if err != nil {
+			fmt.Fprintf(eout, "-where %s\n", err)
+			os.Exit(1)
+		}
+	}
 
 	rowNo := 0
 	fieldNames := []string{}
-	r := csv.NewReader(in)
+	var src io.Reader
+	src, delimiter, useHeader, err = datatools.SniffInput(in, sniff, delimiter, useHeader, "use-header")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
 	r.Comment = '#'
 	r.FieldsPerRecord = fieldsPerRecord
 	r.LazyQuotes = lazyQuotes
@@ -235,13 +294,68 @@ func main() {
 		for _, val := range row {
 			fieldNames = append(fieldNames, strings.TrimSpace(val))
 		}
+		if rowFilter != nil {
+			if err := rowFilter.SetHeader(fieldNames); err != nil {
+				fmt.Fprintln(eout, err)
+				os.Exit(1)
+			}
+		}
 		rowNo++
 	}
+
+	// Work out the fields used to cast cells, nil fields are left as text
+	var schema *datatools.TableSchema
+	fields := []*datatools.SchemaField{}
+	pending := [][]string{}
+	if schemaFName != "" {
+		schema, err = datatools.ReadTableSchema(schemaFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		fields = schema.ColumnFields(fieldNames, 0)
+	}
+	if inferTypes {
+		columns := len(fieldNames)
+		for inferRows <= 0 || len(pending) < inferRows {
+			row, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				fmt.Fprintln(eout, err)
+				os.Exit(1)
+			}
+			pending = append(pending, append([]string{}, row...))
+			if len(row) > columns {
+				columns = len(row)
+			}
+		}
+		names := make([]string, columns)
+		schema = datatools.InferTableSchemaRows(names, pending)
+		for _, field := range schema.Fields {
+			switch field.Type {
+			case "integer", "number", "boolean":
+				fields = append(fields, field)
+			default:
+				fields = append(fields, nil)
+			}
+		}
+	}
+	next := func() ([]string, error) {
+		if len(pending) > 0 {
+			row := pending[0]
+			pending = pending[1:]
+			return row, nil
+		}
+		return r.Read()
+	}
+
 	hasError := false
 	arrayOfObjects := []string{}
 	object := map[string]interface{}{}
 	for {
-		row, err := r.Read()
+		row, err := next()
 		if err == io.EOF {
 			break
 		}
@@ -249,14 +363,34 @@ func main() {
 			fmt.Fprintln(eout, err)
 			os.Exit(1)
 		}
+		if rowFilter != nil {
+			ok, err := rowFilter.Match(row)
+			if err != nil {
+				fmt.Fprintf(eout, "error row %d, %s\n", rowNo, err)
+				os.Exit(1)
+			}
+			if !ok {
+				rowNo++
+				continue
+			}
+		}
 
 		// Pad the fieldnames if necessary
 		object = map[string]interface{}{}
 		for col, val := range row {
+			var cell interface{} = val
+			if col < len(fields) && fields[col] != nil {
+				if cell, err = schema.JSONValue(fields[col], val); err != nil {
+					if !quiet {
+						fmt.Fprintf(eout, "error row %d, column %d, %s\n", rowNo, col+1, err)
+					}
+					hasError = true
+				}
+			}
 			if col < len(fieldNames) {
-				object[fieldNames[col]] = val
+				object[fieldNames[col]] = cell
 			} else {
-				object[fmt.Sprintf("col_%d", col)] = val
+				object[fmt.Sprintf("col_%d", col)] = cell
 			}
 		}
 		var src []byte
@@ -71,6 +71,17 @@ is one object per line. See https://jsonlines.org.
 -i, -input
 : input filename
 
+-infer-rows
+: number of rows examined by -infer-types, zero examines all rows
+(default 1000)
+
+-infer-types
+: write numbers, booleans and nulls for columns where the rows examined
+(see -infer-rows) are consistently integers, numbers or booleans, empty
+cells in those columns become null. A later cell that isn't of the
+inferred type is written as text, reported as an error and {app_name}
+exits with 1, increase -infer-rows (or use zero) to avoid this
+
 -nl, -newline
 : include trailing newline in output
 
@@ -83,6 +94,14 @@ is one object per line. See https://jsonlines.org.
 -reuse-record
 : reuse the backing array
 
+-schema SCHEMA_JSON
+: cast cells using a Frictionless Data Table Schema (see csvvalidate),
+dates and times are written in ISO 8601 and "list" fields are split on
+their delimiter into arrays
+
+-sniff
+: guess the delimiter, header row and character encoding of the input (see csvsniff)
+
 -trim-leading-space
 : trim leading space in fields for CSV input
 
@@ -127,9 +146,13 @@ Convert data1.csv to JSON line (one object line per blob)
 	asBlobs          bool
 	delimiter        string
 	lazyQuotes       bool
+	sniff            bool
 	trimLeadingSpace bool
 	fieldsPerRecord  int
 	reuseRecord      bool
+	inferTypes       bool
+	inferRows        int
+	schemaFName      string
 	forDataset       int
 )
 
@@ -157,8 +180,12 @@ func main() {
 	flag.StringVar(&delimiter, "d", "", "set the delimter character")
 	flag.StringVar(&delimiter, "delimiter", "", "set the delimter character")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in fields for CSV input")
 	flag.BoolVar(&reuseRecord, "reuse-record", false, "reuse the backing array")
+	flag.BoolVar(&inferTypes, "infer-types", false, "write numbers, booleans and nulls for consistently typed columns")
+	flag.IntVar(&inferRows, "infer-rows", datatools.DefaultInferRows, "number of rows examined by -infer-types, zero examines all rows")
+	flag.StringVar(&schemaFName, "schema", "", "cast cells using a Table Schema")
 	flag.IntVar(&fieldsPerRecord, "fields-per-record", 0, "Set the number of fields expected in the CSV read, -1 to turn off")
 	flag.IntVar(&forDataset, "for-dataset", -1, "generate a dataset compatible JSON lines output using column number as key")
 
@@ -205,10 +232,21 @@ func main() {
 	if newLine {
 		eol = "\n"
 	}
+	if inferTypes && schemaFName != "" {
+		fmt.Fprintln(eout, "use either -infer-types or -schema, not both")
+		os.Exit(1)
+	}
 
 	rowNo := 0
 	fieldNames := []string{}
-	r := csv.NewReader(in)
+	var src io.Reader
+	src, delimiter, useHeader, err = datatools.SniffInput(in, sniff, delimiter, useHeader, "use-header")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
 	r.Comment = '#'
 	r.FieldsPerRecord = fieldsPerRecord
 	r.LazyQuotes = lazyQuotes
@@ -232,10 +270,59 @@ func main() {
 		}
 		rowNo++
 	}
+
+	// Work out the fields used to cast cells, nil fields are left as text
+	var schema *datatools.TableSchema
+	fields := []*datatools.SchemaField{}
+	pending := [][]string{}
+	if schemaFName != "" {
+		schema, err = datatools.ReadTableSchema(schemaFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		fields = schema.ColumnFields(fieldNames, 0)
+	}
+	if inferTypes {
+		columns := len(fieldNames)
+		for inferRows <= 0 || len(pending) < inferRows {
+			row, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				fmt.Fprintln(eout, err)
+				os.Exit(1)
+			}
+			pending = append(pending, append([]string{}, row...))
+			if len(row) > columns {
+				columns = len(row)
+			}
+		}
+		names := make([]string, columns)
+		schema = datatools.InferTableSchemaRows(names, pending)
+		for _, field := range schema.Fields {
+			switch field.Type {
+			case "integer", "number", "boolean":
+				fields = append(fields, field)
+			default:
+				fields = append(fields, nil)
+			}
+		}
+	}
+	next := func() ([]string, error) {
+		if len(pending) > 0 {
+			row := pending[0]
+			pending = pending[1:]
+			return row, nil
+		}
+		return r.Read()
+	}
+
 	hasError := false
 	object := map[string]interface{}{}
 	for {
-		row, err := r.Read()
+		row, err := next()
 		if err == io.EOF {
 			break
 		}
@@ -248,10 +335,19 @@ func main() {
 		object = map[string]interface{}{}
 		key := ""
 		for col, val := range row {
+			var cell interface{} = val
+			if col < len(fields) && fields[col] != nil {
+				if cell, err = schema.JSONValue(fields[col], val); err != nil {
+					if !quiet {
+						fmt.Fprintf(eout, "error row %d, column %d, %s\n", rowNo, col+1, err)
+					}
+					hasError = true
+				}
+			}
 			if col < len(fieldNames) {
-				object[fieldNames[col]] = val
+				object[fieldNames[col]] = cell
 			} else {
-				object[fmt.Sprintf("col_%d", col)] = val
+				object[fmt.Sprintf("col_%d", col)] = cell
 			}
 			if (col == forDataset) {
 				key = fmt.Sprintf("%s", val);
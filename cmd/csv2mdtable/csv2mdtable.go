@@ -24,7 +24,6 @@ import (
 	"io"
 	"os"
 	"path"
-	"strings"
 
 	// My packages
 	"github.com/caltechlibrary/datatools"
@@ -74,6 +73,9 @@ table to stdout.
 -quiet
 : suppress error message
 
+-sniff
+: guess the delimiter and character encoding of the input (see csvsniff)
+
 -trim-leading-space
 : trim leading space in field(s) for CSV input
 
@@ -115,6 +117,7 @@ Convert data1.csv to data1.md using options.
 	// Application Options
 	delimiter        string
 	lazyQuotes       bool
+	sniff            bool
 	trimLeadingSpace bool
 )
 
@@ -143,6 +146,7 @@ func main() {
 	flag.StringVar(&delimiter, "d", "", "set delimiter character")
 	flag.StringVar(&delimiter, "delimiter", "", "set delimiter character")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "using lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
 
 	// Parse environment and options
@@ -189,39 +193,24 @@ func main() {
 		eol = "\n"
 	}
 
-	r := csv.NewReader(in)
+	var src io.Reader
+	src, delimiter, _, err = datatools.SniffInput(in, sniff, delimiter, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
 	r.LazyQuotes = lazyQuotes
 	r.TrimLeadingSpace = trimLeadingSpace
 
 	if delimiter != "" {
 		r.Comma = datatools.NormalizeDelimiterRune(delimiter)
 	}
-	writeHeader := true
 	fmt.Fprintf(out, "%s", eol)
-	for {
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			fmt.Fprintln(eout, err)
-			os.Exit(1)
-		}
-
-		fmt.Fprintf(out, "| %s |%s", strings.Join(record, " | "), "\n")
-		if writeHeader == true {
-			headerRow := []string{}
-
-			for _, rec := range record {
-				if len(rec) < 3 {
-					headerRow = append(headerRow, "---")
-				} else {
-					headerRow = append(headerRow, strings.Repeat("-", len(rec)))
-				}
-			}
-			fmt.Fprintf(out, "| %s |%s", strings.Join(headerRow, " | "), "\n")
-			writeHeader = false
-		}
+	if err := datatools.CSVToMarkdownTable(r, out); err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
 	}
 	fmt.Fprintf(out, "%s", eol)
 }
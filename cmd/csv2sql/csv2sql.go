@@ -0,0 +1,295 @@
+// csv2sql - is a command line that loads CSV content into a MySQL, Postgres
+// or SQLite3 table generating the CREATE TABLE statement as needed.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] -dsn DSN_URL -table TABLE_NAME [CSV_FILE]
+
+# DESCRIPTION
+
+{app_name} is the inverse of sql2csv. It reads CSV content (from
+CSV_FILE or standard input) and loads it into a MySQL 8, Postgres or
+SQLite3 table. The first row of the CSV content provides the column
+names. Column types (integer, real, date or text) are inferred from
+the values found in the first rows of the CSV content.
+
+The rows are inserted inside a single transaction using multi-row
+INSERT statements. If any row fails to load nothing is changed.
+
+The DSN_URL has the same form used by sql2csv, the URL's scheme
+indicates the type of database (e.g. "sqlite", "mysql", "postgres").
+
+~~~
+    sqlite://file:my_database.sqlite3
+    mysql://jane.doe:something_secret@/my_database
+    postgres://jane.doe@/my_database?sslmode=disable
+~~~
+
+The load modes are
+
+append
+: insert the rows into the table (the default)
+
+replace
+: remove the existing rows from the table then insert the rows
+
+upsert
+: insert new rows and update the existing rows matching the -key column
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-batch-size
+: number of rows sent with each INSERT statement (default 100)
+
+-create
+: create the table if it does not exist, in replace mode the table is
+dropped and recreated
+
+-d, -delimiter
+: set the input delimiter character
+
+-ddl
+: write the CREATE TABLE statement to standard out and exit without
+loading any rows
+
+-dsn
+: the data source name in URL form
+
+-infer-rows
+: number of rows examined to infer column types, zero examines all
+rows (default 1000)
+
+-key
+: the column used to match rows when upserting, given as it appears in
+the header or as the SQL column name it becomes. It is also used as the
+primary key when creating the table
+
+-mode
+: load mode, append, replace or upsert (default append)
+
+-quiet
+: suppress the summary written to standard error
+
+-table
+: the name of the table to load, defaults to the CSV filename without
+its extension
+
+-sniff
+: guess the delimiter and character encoding of the input (see csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+# EXAMPLES
+
+Show the CREATE TABLE statement inferred for books.csv for Postgres.
+
+~~~
+    {app_name} -dsn "postgres://${USER}@/reports?sslmode=disable" \
+        -ddl books.csv
+~~~
+
+Create a SQLite3 table called books and load it from books.csv.
+
+~~~
+    {app_name} -dsn sqlite://file:reports.db -create books.csv
+~~~
+
+Refresh a MySQL reporting table from a spreadsheet export updating
+rows by their "id" column.
+
+~~~
+    {app_name} -dsn "mysql://${USER}:${PASSWD}@/reports" \
+        -table holdings -mode upsert -key id holdings.csv
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	quiet       bool
+
+	// App Options
+	dsn              string
+	showDDL          bool
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+)
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+
+	cfg := new(datatools.CSVToSQLCfg)
+	cfg.Mode = datatools.SQLAppend
+	cfg.BatchSize = 100
+	cfg.InferRows = 1000
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&quiet, "quiet", false, "suppress the summary written to standard error")
+
+	// App Options
+	flag.StringVar(&dsn, "dsn", "", "the data source name in URL form")
+	flag.StringVar(&cfg.Table, "table", "", "the name of the table to load")
+	flag.StringVar(&cfg.Mode, "mode", cfg.Mode, "load mode, append, replace or upsert")
+	flag.StringVar(&cfg.Key, "key", "", "the column name used to match rows when upserting")
+	flag.IntVar(&cfg.BatchSize, "batch-size", cfg.BatchSize, "number of rows sent with each INSERT statement")
+	flag.IntVar(&cfg.InferRows, "infer-rows", cfg.InferRows, "number of rows examined to infer column types, zero examines all rows")
+	flag.BoolVar(&cfg.Create, "create", false, "create the table if it does not exist")
+	flag.BoolVar(&showDDL, "ddl", false, "write the CREATE TABLE statement to standard out and exit")
+	flag.StringVar(&delimiter, "d", "", "set the input delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the input delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+
+	// Parse env and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	inputFName := ""
+	if len(args) > 0 {
+		inputFName = args[0]
+	}
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+		if cfg.Table == "" {
+			cfg.Table = datatools.CSVTableName(inputFName)
+		}
+	}
+	if dsn == "" {
+		fmt.Fprintf(eout, "Missing -dsn, try %s -help\n", appName)
+		os.Exit(1)
+	}
+	if cfg.Table == "" {
+		fmt.Fprintf(eout, "Missing -table, try %s -help\n", appName)
+		os.Exit(1)
+	}
+
+	var src io.Reader
+	src, delimiter, _, err = datatools.SniffInput(in, sniff, delimiter, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+	if delimiter != "" {
+		r.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	}
+
+	store, err := datatools.OpenSQLStore(dsn)
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	if showDDL {
+		stmt, err := store.CSVToDDL(r, cfg)
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(out, "%s;\n", stmt)
+		os.Exit(0)
+	}
+
+	count, err := store.CSVToSQL(r, cfg)
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+	if !quiet {
+		fmt.Fprintf(eout, "%d rows loaded into %s\n", count, cfg.Table)
+	}
+}
@@ -77,6 +77,9 @@ If the Workbook does not exist then it is created.
 -sheet
 : Sheet name to create/replace
 
+-sniff
+: guess the delimiter and character encoding of the input (see csvsniff)
+
 -trim-leading-space
 : trim leading space in field(s) for CSV input
 
@@ -125,10 +128,11 @@ the workbook's 'My worksheet 2' sheet.
 	sheetName        string
 	delimiter        string
 	lazyQuotes       bool
+	sniff            bool
 	trimLeadingSpace bool
 )
 
-func csv2XLSXSheet(in *os.File, workbookName string, sheetName string, delimiter string, lazyQuotes, trimLeadingSpace bool) error {
+func csv2XLSXSheet(in io.Reader, workbookName string, sheetName string, delimiter string, lazyQuotes, trimLeadingSpace bool) error {
 	var workbook *xlsx.File
 
 	// Open the workbook
@@ -196,6 +200,7 @@ func main() {
 	flag.StringVar(&delimiter, "d", "", "set delimiter character (input)")
 	flag.StringVar(&delimiter, "delimiter", "", "set delimiter character (input)")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
 
 	// Parse environment and options
@@ -265,7 +270,14 @@ func main() {
 		fmt.Fprintf(eout, "Missing sheet name")
 		os.Exit(1)
 	}
-	err = csv2XLSXSheet(in, workbookName, sheetName, delimiter, lazyQuotes, trimLeadingSpace)
+	var src io.Reader
+	src, delimiter, _, err = datatools.SniffInput(in, sniff, delimiter, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	err = csv2XLSXSheet(src, workbookName, sheetName, delimiter, lazyQuotes, trimLeadingSpace)
 	if err != nil {
 		fmt.Fprintln(eout, err)
 		os.Exit(1)
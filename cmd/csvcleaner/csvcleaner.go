@@ -50,6 +50,12 @@ var (
 helps to address issues like variable number of columns, leading/trailing
 spaces in columns, and non-UTF-8 encoding issues.
 
+Cells can also be repaired. Repairs are applied in the order mojibake
+(UTF-8 text which was decoded as Windows-1252 or Latin-1, e.g. "JosÃ©"
+for "José"), Unicode normalization (NFC or NFKC), control characters,
+smart punctuation and finally white space. The -report option lists
+the cells which would change instead of writing the cleaned CSV.
+
 By default input is expected from standard in and output is sent to 
 standard out (errors to standard error). These can be modified by
 appropriate options. The csv file is processed as a stream of rows so 
@@ -72,6 +78,10 @@ minimal memory is used to operate on the file.
 -comma
 : if set use this character in place of a comma for delimiting cells
 
+-collapse-space
+: turn each run of white space, including line breaks and non-breaking
+spaces, into a single space
+
 -comment-char
 : if set, rows starting with this character will be ignored as comments
 
@@ -81,9 +91,15 @@ minimal memory is used to operate on the file.
 -i, -input
 : input filename
 
+-fix-mojibake
+: repair UTF-8 text which was decoded as Windows-1252 or Latin-1
+
 -left-trim
 : left trim spaces on CSV out
 
+-normalize FORM
+: normalize cells to the Unicode form NFC or NFKC
+
 -o, -output
 : output filename
 
@@ -93,6 +109,13 @@ minimal memory is used to operate on the file.
 -quiet
 : suppress error messages
 
+-replace-punctuation
+: replace smart quotes, dashes and ellipses with ASCII
+
+-report
+: write a CSV report of the cells changed with the columns row, column,
+changes, before and after instead of the cleaned CSV
+
 -reuse
 : if false then a new array is allocated for each row processed, if true the array gets reused
 
@@ -102,9 +125,15 @@ minimal memory is used to operate on the file.
 -stop-on-error
 : exit on error, useful if you're trying to debug a problematic CSV file
 
+-strip-control
+: remove control characters other than tab, line feed and carriage return
+
 -trim, -trim-spaces
 : trim spaces on CSV out
 
+-sniff
+: guess the delimiter and character encoding of the input (see csvsniff)
+
 -trim-leading-space
 : trim leading space from field(s) for CSV input
 
@@ -140,6 +169,16 @@ Trim leading and trailing spaces from output.
     cat mysheet.csv | {app_name} -trim-space
 ~~~
 
+Repair an old export with decomposed diacritics, mojibake and stray
+control characters, first checking what would change.
+
+~~~
+    {app_name} -i export.csv -normalize NFC -fix-mojibake \
+        -strip-control -collapse-space -report
+    {app_name} -i export.csv -normalize NFC -fix-mojibake \
+        -strip-control -collapse-space -o cleaned.csv
+~~~
+
 {app_name} {version}
 `
 
@@ -169,7 +208,14 @@ Trim leading and trailing spaces from output.
 	useCRLF          bool
 	stopOnError      bool
 	lazyQuotes       bool
+	sniff            bool
 	trimLeadingSpace bool
+	normalize        string
+	fixMojibake      bool
+	collapseSpace    bool
+	stripControl     bool
+	replacePunct     bool
+	report           bool
 
 	verbose bool
 )
@@ -206,7 +252,14 @@ func main() {
 	flag.BoolVar(&useCRLF, "use-crlf", useCRLF, "if set use a charage return and line feed in output")
 	flag.BoolVar(&stopOnError, "stop-on-error", false, "exit on error, useful if you're trying to debug a problematic CSV file")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space from field(s) for CSV input")
+	flag.StringVar(&normalize, "normalize", "", "normalize cells to the Unicode form NFC or NFKC")
+	flag.BoolVar(&fixMojibake, "fix-mojibake", false, "repair UTF-8 text which was decoded as Windows-1252 or Latin-1")
+	flag.BoolVar(&collapseSpace, "collapse-space", false, "turn each run of white space into a single space")
+	flag.BoolVar(&stripControl, "strip-control", false, "remove control characters")
+	flag.BoolVar(&replacePunct, "replace-punctuation", false, "replace smart quotes, dashes and ellipses with ASCII")
+	flag.BoolVar(&report, "report", false, "report the cells changed instead of writing the cleaned CSV")
 
 	flag.BoolVar(&verbose, "verbose", false, "write verbose output to standard error")
 
@@ -256,11 +309,30 @@ func main() {
 	if trimLeftSpace == true && trimRightSpace == true {
 		trimSpace = true
 	}
+	cleaner := &datatools.CellCleaner{
+		Normalization:      normalize,
+		RepairMojibake:     fixMojibake,
+		StripControl:       stripControl,
+		ReplacePunctuation: replacePunct,
+		CollapseSpace:      collapseSpace,
+	}
+	if err := cleaner.Validate(); err != nil {
+		fmt.Fprintf(eout, "-normalize %s\n", err)
+		os.Exit(1)
+	}
+	cleanCells := normalize != "" || fixMojibake || stripControl || replacePunct || collapseSpace
 
 	// Setup our CSV reader with any cli options
 	var rStr []rune
 
-	r := csv.NewReader(in)
+	var src io.Reader
+	src, comma, _, err = datatools.SniffInput(in, sniff, comma, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
 	if comma != "" {
 		rStr = []rune(comma)
 		if len(rStr) > 0 {
@@ -286,6 +358,12 @@ func main() {
 		}
 	}
 	w.UseCRLF = useCRLF
+	if report {
+		if err := w.Write([]string{"row", "column", "changes", "before", "after"}); err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
 
 	// i is so we can track row count as we process each streamed in row
 	hasError := false
@@ -321,22 +399,40 @@ func main() {
 				row = append(row, "")
 			}
 		}
-		if trimSpace || trimLeftSpace || trimRightSpace {
-			for i := range row {
-				s := row[i]
+		if trimSpace || trimLeftSpace || trimRightSpace || cleanCells {
+			for j := range row {
+				s := row[j]
+				changes := []string{}
 				switch {
 				case trimSpace:
-					row[i] = strings.TrimSpace(s)
+					s = strings.TrimSpace(s)
 				case trimRightSpace:
-					row[i] = strings.TrimRight(s, " \t\n\r")
+					s = strings.TrimRight(s, " \t\n\r")
 				case trimLeftSpace:
-					row[i] = strings.TrimLeft(s, " \t\n\r")
+					s = strings.TrimLeft(s, " \t\n\r")
+				}
+				if s != row[j] {
+					changes = append(changes, "trim")
+				}
+				if cleanCells {
+					var repairs []string
+					s, repairs = cleaner.Clean(s)
+					changes = append(changes, repairs...)
 				}
+				if report && len(changes) > 0 {
+					if err := w.Write([]string{fmt.Sprintf("%d", i), fmt.Sprintf("%d", j+1), strings.Join(changes, ";"), row[j], s}); err != nil {
+						fmt.Fprintf(eout, "error writing report for row %d: %s", i, err)
+						hasError = true
+					}
+				}
+				row[j] = s
 			}
 		}
-		if err := w.Write(row); err != nil {
-			fmt.Fprintf(eout, "error writing row %d: %s", i, err)
-			hasError = true
+		if !report {
+			if err := w.Write(row); err != nil {
+				fmt.Fprintf(eout, "error writing row %d: %s", i, err)
+				hasError = true
+			}
 		}
 		i++
 		if verbose == true && (i%100) == 0 {
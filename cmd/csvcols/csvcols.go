@@ -29,6 +29,7 @@ import (
 
 	// Caltech Library packages
 	"github.com/caltechlibrary/datatools"
+	"github.com/caltechlibrary/datatools/filter"
 
 	// 3rd Party packages
 	"github.com/google/uuid"
@@ -54,6 +55,16 @@ var (
 It can also be used CSV input rows and rendering only the column numbers
 listed on the commandline (first column is 1 not 0).
 
+Columns can be selected by number, by the names in the header row or
+a mix of both. Names containing commas should be enclosed in double
+quotes. Ranges of columns can be given by numbers (e.g. 2:4) or by
+names (e.g. "Title":"Year"). Negative numbers count back from the
+last column (e.g. -1 is the last column). Ranges may be left open
+(e.g. 2: is the second through the last column), take a step (e.g.
+1:10:2 is every other column of the first ten, ::-1 is every column
+in reverse) and "all" is every column. These are the same range
+expressions used by csvrows and range.
+
 # OPTIONS
 
 -help
@@ -66,7 +77,7 @@ listed on the commandline (first column is 1 not 0).
 : display version
 
 -col, -cols
-: output specified columns (e.g. -col 1,12:14,2,4))
+: output specified columns (e.g. -col 1,12:14,2,4 or -col "Title":"Year",-1)
 
 -d, -delimiter
 : set the input delimiter character
@@ -86,6 +97,9 @@ listed on the commandline (first column is 1 not 0).
 -skip-header-row
 : skip the header row
 
+-sniff
+: guess the delimiter and character encoding of the input (see csvsniff)
+
 -trim-leading-space
 : trim leading space in field(s) for CSV input
 
@@ -95,9 +109,20 @@ listed on the commandline (first column is 1 not 0).
 -uuid
 : add a prefix row with generated UUID cell
 
+-where
+: only output rows matching a filter expression (the header row is always output)
+
 -crlf
 : use a CRLF for end of line (EOL) on output (defualts to true on Windows)
 
+# FILTERS
+
+The -where option takes the same filter expressions as csvrows, a
+prefix notation testing the cells of each row, e.g.
+'(and (eq (cols 1) "x") (gt (cols "Year") 2017))'. Columns can be
+referred to by number or header name. See csvrows for the list of
+functions.
+
 # EXAMPLES
 
 Simple usage of building a CSV file one row at a time.
@@ -129,6 +154,24 @@ Using options filter a 3 column CSV file for columns 1,3 into 2col.csv
     {app_name} -i 3col.csv -col 1,3 -o 2col.csv
 ~~~
 
+Using the header row select the "Title" column and the last column.
+
+~~~
+    {app_name} -i books.csv -col 'Title,-1'
+~~~
+
+Move the "ID" column to the end keeping the others in order.
+
+~~~
+    {app_name} -i books.csv -col '2:,ID'
+~~~
+
+Select the "Title" and "Year" columns of books published after 2017.
+
+~~~
+    {app_name} -i books.csv -col 'Title,Year' -where '(gt (cols "Year") 2017)'
+~~~
+
 {app_name} {version}
 
 `
@@ -146,11 +189,13 @@ Using options filter a 3 column CSV file for columns 1,3 into 2col.csv
 
 	// App Options
 	outputColumns    string
+	where            string
 	prefixUUID       bool
 	skipHeaderRow    bool
 	delimiter        string
 	outputDelimiter  string
 	lazyQuotes       bool
+	sniff            bool
 	trimLeadingSpace bool
 	useCRLF          bool
 )
@@ -181,8 +226,11 @@ func selectedColumns(rowNo int, record []string, columnNos []int, prefixUUID boo
 	return result
 }
 
-func CSVColumns(in *os.File, out *os.File, eout *os.File, columnNos []int, prefixUUID bool, skipHeaderRow bool, delimiterIn string, delimiterOut string, lazyQuotes, trimLeadingSpace bool) {
-	var err error
+func CSVColumns(in io.Reader, out *os.File, eout *os.File, columns string, rowFilter *filter.Filter, prefixUUID bool, skipHeaderRow bool, delimiterIn string, delimiterOut string, lazyQuotes, trimLeadingSpace bool) {
+	var (
+		err       error
+		columnNos []int
+	)
 
 	r := csv.NewReader(in)
 	r.LazyQuotes = lazyQuotes
@@ -205,6 +253,29 @@ func CSVColumns(in *os.File, out *os.File, eout *os.File, columnNos []int, prefi
 			fmt.Fprintln(eout, err)
 
 		}
+		// NOTE: The first row is used to resolve column names
+		if i == 0 {
+			columnNos, err = datatools.ParseColumns(columns, rec)
+			if err != nil {
+				fmt.Fprintln(eout, err)
+				os.Exit(1)
+			}
+			if rowFilter != nil {
+				if err = rowFilter.SetHeader(rec); err != nil {
+					fmt.Fprintln(eout, err)
+					os.Exit(1)
+				}
+			}
+		} else if rowFilter != nil {
+			ok, err := rowFilter.Match(rec)
+			if err != nil {
+				fmt.Fprintf(eout, "row %d, %s\n", i+1, err)
+				os.Exit(1)
+			}
+			if !ok {
+				continue
+			}
+		}
 
 		row := selectedColumns(i, rec, columnNos, prefixUUID, skipHeaderRow)
 		err = w.Write(row)
@@ -241,15 +312,17 @@ func main() {
 	flag.BoolVar(&quiet, "quiet", false, "suppress error messages")
 
 	// App Options
-	flag.StringVar(&outputColumns, "col", "", "output specified columns (e.g. -col 1,12:14,2,4))")
-	flag.StringVar(&outputColumns, "cols", "", "output specified columns (e.g. -col 1,12:14,2,4))")
+	flag.StringVar(&outputColumns, "col", "", "output specified columns (e.g. -col 1,12:14,2,4 or -col \"Title\":\"Year\",-1)")
+	flag.StringVar(&outputColumns, "cols", "", "output specified columns (e.g. -col 1,12:14,2,4 or -col \"Title\":\"Year\",-1)")
 	flag.StringVar(&delimiter, "d", "", "set the input delimiter character")
 	flag.StringVar(&delimiter, "delimiter", "", "set the input delimiter character")
 	flag.StringVar(&outputDelimiter, "od", "", "set the output delimiter character")
 	flag.StringVar(&outputDelimiter, "output-delimiter", "", "set the output delimiter character")
 	flag.BoolVar(&skipHeaderRow, "skip-header-row", true, "skip the header row")
 	flag.BoolVar(&prefixUUID, "uuid", false, "add a prefix row with generated UUID cell")
+	flag.StringVar(&where, "where", "", "only output rows matching a filter expression")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes on CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
 	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use a CRLF for end of line (EOL)")
 
@@ -296,20 +369,27 @@ func main() {
 		os.Exit(0)
 	}
 
-	if outputColumns != "" {
-		columnNos, err := datatools.ParseRange(outputColumns)
+	var rowFilter *filter.Filter
+	if where != "" {
+		rowFilter, err = filter.Parse(where)
 		if err != nil {
-			fmt.Fprintln(eout, err)
+			fmt.Fprintf(eout, "-where %s\n", err)
 			os.Exit(1)
 		}
-		// NOTE: We need to adjust from humans counting from 1 to counting from zero
-		for i := 0; i < len(columnNos); i++ {
-			columnNos[i] = columnNos[i] - 1
-			if columnNos[i] < 0 {
-				columnNos[i] = 0
-			}
+		if outputColumns == "" {
+			outputColumns = "all"
 		}
-		CSVColumns(in, out, eout, columnNos, prefixUUID, skipHeaderRow, delimiter, outputDelimiter, lazyQuotes, trimLeadingSpace)
+	}
+
+	var src io.Reader
+	src, delimiter, _, err = datatools.SniffInput(in, sniff, delimiter, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	if outputColumns != "" {
+		CSVColumns(src, out, eout, outputColumns, rowFilter, prefixUUID, skipHeaderRow, delimiter, outputDelimiter, lazyQuotes, trimLeadingSpace)
 		os.Exit(0)
 	}
 
@@ -332,7 +412,7 @@ func main() {
 		w.Comma = datatools.NormalizeDelimiterRune(outputDelimiter)
 	}
 	if err := w.Write(cells); err != nil {
-		fmt.Fprint(eout, "error writing args as csv, %s\n", err)
+		fmt.Fprintf(eout, "error writing args as csv, %s\n", err)
 		os.Exit(1)
 	}
 	w.Flush()
@@ -0,0 +1,492 @@
+// csvdedupe - is a command line that finds rows with matching key columns
+// in a CSV file and removes, lists or labels the duplicates.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS]
+
+# DESCRIPTION
+
+{app_name} finds the rows of a CSV file whose key columns match and
+groups them into clusters of duplicates. The key is given with -key,
+a column number (counting from one), a header name or a list of them
+(e.g. -key Title or -key 2,4). Rows with an empty key column are
+never duplicates.
+
+By default keys match exactly ignoring case. The matching options
+are the same as csvfind's, -trimspaces, -case-sensitive and
+-stop-words normalize the key before comparing, -levenshtein matches
+keys within -max-edit-distance and -metric matches keys scoring at
+least -threshold with a similarity metric (levenshtein, jaro-winkler,
+token-sort, token-set, jaccard, soundex or metaphone). Fuzzy matches
+are chained, if A matches B and B matches C all three rows are in
+one cluster. When using more than one key column each must match.
+
+The -mode option sets what is output.
+
+first
+: drop duplicates keeping the first row of each cluster (the default)
+
+last
+: drop duplicates keeping the last row of each cluster
+
+duplicates
+: output only the rows that have duplicates, grouped by cluster
+
+cluster
+: output every row with a cluster id column appended, rows with the
+same id are duplicates. This is useful to review candidate merges in
+a spreadsheet.
+
+Fuzzy matching with -levenshtein uses a q-gram index to only compare
+keys that could be within the edit distance, -metric compares every
+pair of rows.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-case-sensitive
+: perform a case sensitive match (default is false)
+
+-cluster-column
+: the header of the cluster id column (default cluster_id)
+
+-d, -delimiter
+: set the delimiter character
+
+-delete-cost
+: set the delete cost to use for levenshtein matching
+
+-header-row
+: the first row is a header (default true)
+
+-i, -input
+: input filename
+
+-insert-cost
+: set the insert cost to use for levenshtein matching
+
+-key
+: the key column(s) to compare (default 1)
+
+-levenshtein
+: use levenshtein matching
+
+-max-edit-distance
+: set the edit distance thresh hold for match (default 5)
+
+-metric
+: use a similarity metric for matching, levenshtein, jaro-winkler,
+token-sort, token-set, jaccard, soundex or metaphone
+
+-mode
+: first, last, duplicates or cluster (default first)
+
+-o, -output
+: output filename
+
+-stop-words
+: use the colon delimited list of stop words
+
+-substitute-cost
+: set the substitution cost to use for levenshtein matching
+
+-threshold
+: the minimum similarity score (0 to 1) for a -metric match (default 0.85)
+
+-sniff
+: guess the delimiter, header row and character encoding of the input (see csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-trimspace, -trimspaces
+: trim spaces around cell values before comparing
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+-verbose
+: report the number of rows, clusters and comparisons to stderr
+
+-crlf
+: use CRLF for end of line (EOL) on write, defaults to true on Windows
+
+# EXAMPLES
+
+Remove the rows of books.csv with the same title keeping the first.
+
+~~~
+    {app_name} -i books.csv -key Title -trimspaces
+~~~
+
+List the rows with duplicate titles grouped together.
+
+~~~
+    {app_name} -i books.csv -key Title -mode duplicates
+~~~
+
+Label titles and authors that nearly match so they can be reviewed
+in a spreadsheet.
+
+~~~
+    {app_name} -i books.csv -key Title,Author -metric token-sort \
+        -threshold 0.9 -mode cluster -o review.csv
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	inputFName  string
+	outputFName string
+
+	// App Options
+	key              string
+	mode             string
+	clusterColumn    string
+	headerRow        bool
+	caseSensitive    bool
+	trimSpaces       bool
+	stopWordsOption  string
+	useLevenshtein   bool
+	maxEditDistance  int
+	insertCost       int
+	deleteCost       int
+	substituteCost   int
+	metricName       string
+	threshold        float64
+	verbose          bool
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+	useCRLF          bool
+)
+
+// normalizeCell applies the case, space and stop word options the same
+// way as csvfind
+func normalizeCell(val string, stopWords []string) string {
+	if caseSensitive == false {
+		val = strings.ToLower(val)
+	}
+	if trimSpaces == true {
+		val = strings.TrimSpace(val)
+	}
+	if len(stopWords) > 0 {
+		fields := strings.FieldsFunc(val, func(c rune) bool {
+			return datatools.Filter(c, "", false)
+		})
+		val = strings.Join(datatools.ApplyStopWords(fields, stopWords), " ")
+	}
+	return val
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+	useCRLF = (runtime.GOOS == "windows")
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&inputFName, "input", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.StringVar(&key, "key", "1", "the key column(s) to compare")
+	flag.StringVar(&mode, "mode", "first", "first, last, duplicates or cluster")
+	flag.StringVar(&clusterColumn, "cluster-column", "cluster_id", "the header of the cluster id column")
+	flag.BoolVar(&headerRow, "header-row", true, "the first row is a header")
+	flag.BoolVar(&caseSensitive, "case-sensitive", false, "perform a case sensitive match (default is false)")
+	flag.BoolVar(&trimSpaces, "trimspace", false, "trim spaces around cell values before comparing")
+	flag.BoolVar(&trimSpaces, "trimspaces", false, "trim spaces around cell values before comparing")
+	flag.StringVar(&stopWordsOption, "stop-words", "", "use the colon delimited list of stop words")
+	flag.BoolVar(&useLevenshtein, "levenshtein", false, "use levenshtein matching")
+	flag.IntVar(&maxEditDistance, "max-edit-distance", 5, "set the edit distance thresh hold for match")
+	flag.IntVar(&insertCost, "insert-cost", 1, "set the insert cost to use for levenshtein matching")
+	flag.IntVar(&deleteCost, "delete-cost", 1, "set the delete cost to use for levenshtein matching")
+	flag.IntVar(&substituteCost, "substitute-cost", 1, "set the substitution cost to use for levenshtein matching")
+	flag.StringVar(&metricName, "metric", "", "use a similarity metric for matching, levenshtein, jaro-winkler, token-sort, token-set, jaccard, soundex or metaphone")
+	flag.Float64Var(&threshold, "threshold", 0.85, "the minimum similarity score (0 to 1) for a -metric match")
+	flag.BoolVar(&verbose, "verbose", false, "report the number of rows, clusters and comparisons to stderr")
+	flag.StringVar(&delimiter, "d", "", "set the delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+
+	// Parse env and options
+	flag.Parse()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	switch mode {
+	case "first", "last", "duplicates", "cluster":
+	default:
+		fmt.Fprintf(eout, "Unknown mode %q, expected first, last, duplicates or cluster\n", mode)
+		os.Exit(1)
+	}
+	var metric datatools.SimilarityFunc
+	if metricName != "" {
+		metric, err = datatools.SimilarityMetric(metricName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
+	stopWords := []string{}
+	if len(stopWordsOption) > 0 {
+		if caseSensitive == false {
+			stopWordsOption = strings.ToLower(stopWordsOption)
+		}
+		stopWords = strings.Split(stopWordsOption, ":")
+	}
+
+	var src io.Reader
+	src, delimiter, headerRow, err = datatools.SniffInput(in, sniff, delimiter, headerRow, "header-row")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+	w := csv.NewWriter(out)
+	w.UseCRLF = useCRLF
+	if delimiter != "" {
+		r.Comma = datatools.NormalizeDelimiterRune(delimiter)
+		w.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	}
+
+	// Read the rows, the first row is used to resolve column names
+	rows := [][]string{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+			os.Exit(1)
+		}
+		rows = append(rows, row)
+	}
+	var header []string
+	if len(rows) > 0 {
+		header = rows[0]
+	}
+	cols, err := datatools.ParseColumns(key, header)
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+	if headerRow && len(rows) > 0 {
+		rows = rows[1:]
+	} else {
+		header = nil
+	}
+
+	keys := make([][]string, len(rows))
+	for i, row := range rows {
+		keys[i] = make([]string, len(cols))
+		for j, col := range cols {
+			if col < len(row) {
+				keys[i][j] = normalizeCell(row[col], stopWords)
+			}
+		}
+	}
+	var (
+		match   func(a []string, b []string) bool
+		blocker *datatools.Blocker
+	)
+	switch {
+	case metric != nil:
+		match = func(a []string, b []string) bool {
+			for i := range a {
+				if metric(a[i], b[i]) < threshold {
+					return false
+				}
+			}
+			return true
+		}
+	case useLevenshtein:
+		match = func(a []string, b []string) bool {
+			for i := range a {
+				if datatools.Levenshtein(a[i], b[i], insertCost, deleteCost, substituteCost, caseSensitive) > maxEditDistance {
+					return false
+				}
+			}
+			return true
+		}
+		if maxEdits := datatools.MaxEdits(maxEditDistance, insertCost, deleteCost, substituteCost); maxEdits >= 0 {
+			blocker = datatools.NewBlocker(datatools.DefaultQGram, maxEdits)
+			for i, k := range keys {
+				blocker.Add(i, k[0])
+			}
+		}
+	}
+	clusters := datatools.ClusterKeys(keys, match, blocker)
+
+	// Count the rows in each cluster and find their last rows
+	sizes, last := map[int]int{}, map[int]int{}
+	for i, cluster := range clusters {
+		sizes[cluster]++
+		last[cluster] = i
+	}
+	if verbose {
+		fmt.Fprintf(eout, "%d rows, %d clusters\n", len(rows), len(sizes))
+		if blocker != nil {
+			fmt.Fprintf(eout, "%d comparisons, %d pruned by blocking\n", blocker.Comparisons, blocker.Pruned)
+		}
+	}
+
+	if header != nil {
+		if mode == "cluster" {
+			header = append(header, clusterColumn)
+		}
+		if err := w.Write(header); err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
+	write := func(row []string) {
+		if err := w.Write(row); err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
+	switch mode {
+	case "first":
+		seen := map[int]bool{}
+		for i, row := range rows {
+			if !seen[clusters[i]] {
+				seen[clusters[i]] = true
+				write(row)
+			}
+		}
+	case "last":
+		for i, row := range rows {
+			if last[clusters[i]] == i {
+				write(row)
+			}
+		}
+	case "duplicates":
+		// Group the rows of each cluster in the order of their first row
+		grouped := map[int][]int{}
+		for i, cluster := range clusters {
+			if sizes[cluster] > 1 {
+				grouped[cluster] = append(grouped[cluster], i)
+			}
+		}
+		for cluster := 1; cluster <= len(sizes); cluster++ {
+			for _, i := range grouped[cluster] {
+				write(rows[i])
+			}
+		}
+	case "cluster":
+		for i, row := range rows {
+			write(append(row, fmt.Sprintf("%d", clusters[i])))
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+}
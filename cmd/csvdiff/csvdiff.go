@@ -0,0 +1,387 @@
+// csvdiff - is a command line that reports the rows added, removed and
+// modified between two CSV files matched on a key column.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] OLD_CSV NEW_CSV
+
+# DESCRIPTION
+
+{app_name} compares two CSV files row by row reporting the rows added,
+removed and modified. Rows are matched on the key column(s) given
+with -key, a column number (counting from one), a header name or a
+list of them (e.g. -key id or -key 1,3). Keys must be unique in each
+file.
+
+When the files have a header row columns are matched by name so a
+column may be added, removed or moved between the files. Only the
+columns found in both files are compared. Without a
+header row (-header-row=false) columns are compared by position.
+
+If both files are sorted by the key columns (e.g. with csvsort) use
+-sorted and they are compared in a single pass without holding
+either file in memory. Otherwise OLD_CSV is read into memory and the
+changes are reported in the order of NEW_CSV followed by the removed
+rows.
+
+The -format option controls the output.
+
+csv
+: a CSV file with a "change" column (added, removed or modified)
+followed by the columns of both files. Added rows show the new values,
+removed rows the old values and modified rows show changed cells as
+"OLD->NEW".
+
+jsonl
+: one JSON object per line with the change type, the key, the row
+and for modified rows the old and new value of each changed cell
+
+summary
+: a human readable list of changes followed by counts of the rows
+added, removed, modified and unchanged
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-d, -delimiter
+: set the delimiter character
+
+-format
+: output format, csv, jsonl or summary (default csv)
+
+-header-row
+: the first row of each file is a header (default true)
+
+-key
+: the key column(s) to match rows on (default 1)
+
+-o, -output
+: output filename
+
+-sorted
+: both files are sorted by the key, compare them in a single pass
+
+-sniff
+: guess the delimiter, header row and character encoding of each file
+(see csvsniff), the header row and output delimiter follow OLD_CSV
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+-crlf
+: use CRLF for end of line (EOL) on write, defaults to true on Windows
+
+# EXAMPLES
+
+Report the changes between two weekly exports matched on "id".
+
+~~~
+    {app_name} -key id export-2024-01-01.csv export-2024-01-08.csv
+~~~
+
+Summarize the changes of two large exports already sorted by
+"id".
+
+~~~
+    csvsort -i old.csv -key id -o old-sorted.csv
+    csvsort -i new.csv -key id -o new-sorted.csv
+    {app_name} -key id -sorted -format summary \
+       old-sorted.csv new-sorted.csv
+~~~
+
+The keys are compared as strings so sort with the string type (the
+csvsort default) when using -sorted.
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	outputFName string
+
+	// App Options
+	key              string
+	format           string
+	headerRow        bool
+	sorted           bool
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+	useCRLF          bool
+)
+
+// cellValues is the old and new value of a changed cell in JSON Lines output
+type cellValues struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// jsonChange is a row change in JSON Lines output
+type jsonChange struct {
+	Change string                 `json:"change"`
+	Key    map[string]string      `json:"key"`
+	Row    map[string]string      `json:"row"`
+	Cells  map[string]*cellValues `json:"cells,omitempty"`
+}
+
+// changeRow returns the values to report for a change
+func changeRow(change *datatools.CSVRowChange) []string {
+	if change.Change == datatools.CSVRemoved {
+		return change.Old
+	}
+	return change.New
+}
+
+// describeKey formats a key as COLUMN=VALUE pairs
+func describeKey(columns []string, key []string) string {
+	parts := []string{}
+	for i, val := range key {
+		parts = append(parts, fmt.Sprintf("%s=%q", columns[i], val))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+	useCRLF = (runtime.GOOS == "windows")
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.StringVar(&key, "key", "1", "the key column(s) to match rows on")
+	flag.StringVar(&format, "format", "csv", "output format, csv, jsonl or summary")
+	flag.BoolVar(&headerRow, "header-row", true, "the first row of each file is a header")
+	flag.BoolVar(&sorted, "sorted", false, "both files are sorted by the key, compare them in a single pass")
+	flag.StringVar(&delimiter, "d", "", "set the delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of each file")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+
+	// Parse env and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup IO
+	var err error
+
+	out := os.Stdout
+	eout := os.Stderr
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if len(args) != 2 {
+		fmt.Fprintf(eout, "Expected OLD_CSV and NEW_CSV, try %s -help\n", appName)
+		os.Exit(1)
+	}
+	switch format {
+	case "csv", "jsonl", "summary":
+	default:
+		fmt.Fprintf(eout, "Unknown format %q, expected csv, jsonl or summary\n", format)
+		os.Exit(1)
+	}
+
+	readers := []*csv.Reader{}
+	outComma := delimiter
+	for i, fName := range args {
+		var in io.Reader
+		if fName == "-" {
+			in = os.Stdin
+		} else {
+			fp, err := os.Open(fName)
+			if err != nil {
+				fmt.Fprintln(eout, err)
+				os.Exit(1)
+			}
+			defer fp.Close()
+			in = fp
+		}
+		src, comma, header, err := datatools.SniffInput(in, sniff, delimiter, headerRow, "header-row")
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", fName, err)
+			os.Exit(1)
+		}
+		if i == 0 {
+			// The first file decides the header row and output delimiter
+			headerRow, outComma = header, comma
+		}
+		r := csv.NewReader(src)
+		r.LazyQuotes = lazyQuotes
+		r.TrimLeadingSpace = trimLeadingSpace
+		// Rows may differ in width between the files
+		r.FieldsPerRecord = -1
+		if comma != "" {
+			r.Comma = datatools.NormalizeDelimiterRune(comma)
+		}
+		readers = append(readers, r)
+	}
+	w := csv.NewWriter(out)
+	w.UseCRLF = useCRLF
+	if outComma != "" {
+		w.Comma = datatools.NormalizeDelimiterRune(outComma)
+	}
+
+	differ := &datatools.CSVDiffer{
+		Key:       key,
+		HeaderRow: headerRow,
+		Sorted:    sorted,
+	}
+	wroteHeader := false
+	writeHeader := func() error {
+		if wroteHeader || !headerRow || format != "csv" {
+			return nil
+		}
+		wroteHeader = true
+		return w.Write(append([]string{"change"}, differ.Columns...))
+	}
+	emit := func(change *datatools.CSVRowChange) error {
+		switch format {
+		case "jsonl":
+			obj := &jsonChange{
+				Change: change.Change,
+				Key:    map[string]string{},
+				Row:    map[string]string{},
+			}
+			for i, val := range change.Key {
+				obj.Key[differ.KeyColumns[i]] = val
+			}
+			for i, val := range changeRow(change) {
+				obj.Row[differ.Columns[i]] = val
+			}
+			if len(change.Cells) > 0 {
+				obj.Cells = map[string]*cellValues{}
+				for _, cell := range change.Cells {
+					obj.Cells[cell.Column] = &cellValues{Old: cell.Old, New: cell.New}
+				}
+			}
+			src, err := datatools.JSONMarshal(obj)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(out, "%s\n", src)
+			return err
+		case "summary":
+			switch change.Change {
+			case datatools.CSVAdded:
+				fmt.Fprintf(out, "+ added %s\n", describeKey(differ.KeyColumns, change.Key))
+			case datatools.CSVRemoved:
+				fmt.Fprintf(out, "- removed %s\n", describeKey(differ.KeyColumns, change.Key))
+			default:
+				fmt.Fprintf(out, "~ modified %s\n", describeKey(differ.KeyColumns, change.Key))
+				for _, cell := range change.Cells {
+					fmt.Fprintf(out, "    %s: %q -> %q\n", cell.Column, cell.Old, cell.New)
+				}
+			}
+			return nil
+		}
+		if err := writeHeader(); err != nil {
+			return err
+		}
+		row := append([]string{change.Change}, changeRow(change)...)
+		for _, cell := range change.Cells {
+			row[cell.Index+1] = cell.Old + "->" + cell.New
+		}
+		return w.Write(row)
+	}
+	summary, err := differ.Diff(readers[0], readers[1], emit)
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	if err := writeHeader(); err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	if format == "summary" {
+		fmt.Fprintf(out, "%d added, %d removed, %d modified, %d unchanged\n", summary.Added, summary.Removed, summary.Modified, summary.Unchanged)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"strconv"
 	"strings"
 
 	// Caltech Library packages
@@ -47,7 +48,41 @@ var (
 
 {app_name} processes a CSV file as input returning rows that contain
 the column with matched text. Columns are counted from one instead of
-zero. Supports exact match as well as some Levenshtein matching.
+zero. The column can also be given by its name in the header row (e.g.
+-col Title) or counted back from the last column with a negative number
+(e.g. -col -1). Supports exact match as well as some Levenshtein matching.
+
+Levenshtein matching first checks the length and shared q-grams
+(short substrings) of each cell against the text to match, cells that
+can't be within the maximum edit distance are skipped without
+computing their edit distance. The -verbose option reports how many
+comparisons were made and how many were pruned.
+
+The -metric option selects a similarity metric scoring cells from 0
+(nothing in common) to 1 (the same), rows scoring at least -threshold
+are output.
+
+levenshtein
+: edit distance normalized by the length of the longer string
+
+jaro-winkler
+: Jaro-Winkler similarity, good for short strings and names
+
+token-sort
+: compares the words after sorting them, e.g. "Doiel, R. S." and
+"R. S. Doiel"
+
+token-set
+: compares the words in common with the words of each string
+
+jaccard
+: the Jaccard similarity of the sets of 3-grams
+
+soundex
+: the share of words with the same Soundex code
+
+metaphone
+: the share of words with a matching Double Metaphone key
 
 # OPTIONS
 
@@ -67,11 +102,14 @@ zero. Supports exact match as well as some Levenshtein matching.
 -append-edit-distance
 : append column with edit distance found (useful for tuning levenshtein)
 
+-append-score
+: append column with the similarity score found (useful for tuning -threshold)
+
 -case-sensitive
 : perform a case sensitive match (default is false)
 
 -col, -cols
-: column to search for match in the CSV file
+: column to search for match in the CSV file, a number or header name
 
 -contains
 : use contains phrase for matching
@@ -94,6 +132,10 @@ zero. Supports exact match as well as some Levenshtein matching.
 -max-edit-distance
 : set the edit distance thresh hold for match, default 0
 
+-metric
+: use a similarity metric for matching, levenshtein, jaro-winkler,
+token-sort, token-set, jaccard, soundex or metaphone
+
 -nl, -newline
 : include trailing newline from output for end of file (EOF)
 
@@ -115,6 +157,12 @@ zero. Supports exact match as well as some Levenshtein matching.
 -substitute-cost
 : set the substitution cost to use for levenshtein matching
 
+-threshold
+: the minimum similarity score (0 to 1) for a -metric match, default 0.85
+
+-sniff
+: guess the delimiter and character encoding of the input (see csvsniff)
+
 -trim-leading-space
 : trim leadings space in field(s) for CSV input
 
@@ -124,6 +172,9 @@ zero. Supports exact match as well as some Levenshtein matching.
 -use-lazy-quotes
 : use lazy quotes on CSV input
 
+-verbose
+: report the number of Levenshtein comparisons made and pruned to stderr
+
 
 # EXAMPLES
 
@@ -153,6 +204,19 @@ You can also search for phrases in columns.
     {app_name} -i books.csv -col=2 -contains "Red Book"
 ~~~
 
+Find names that match regardless of word order, showing the score.
+
+~~~
+    {app_name} -i authors.csv -col=Name -metric=token-sort \
+       -threshold=0.9 -append-score "R. S. Doiel"
+~~~
+
+The column can be named using the header row.
+
+~~~
+    {app_name} -i books.csv -col=Title -contains "Red Book"
+~~~
+
 {app_name} {version}
 `
 
@@ -171,7 +235,7 @@ You can also search for phrases in columns.
 
 	// App Options
 	skipHeaderRow      bool
-	col                int
+	colExpr            string
 	useContains        bool
 	useLevenshtein     bool
 	insertCost         int
@@ -185,8 +249,13 @@ You can also search for phrases in columns.
 	allowDuplicates    bool
 	delimiter          string
 	lazyQuotes         bool
+	sniff              bool
 	trimLeadingSpace   bool
 	useCRLF            bool
+	verbose            bool
+	metricName         string
+	threshold          float64
+	appendScore        bool
 )
 
 func main() {
@@ -211,8 +280,8 @@ func main() {
 	flag.BoolVar(&newLine, "newline", true, "include trailing newline from output")
 
 	// App Options
-	flag.IntVar(&col, "col", 0, "column to search for match in the CSV file")
-	flag.IntVar(&col, "cols", 0, "column to search for match in the CSV file")
+	flag.StringVar(&colExpr, "col", "", "column to search for match in the CSV file, a number or header name")
+	flag.StringVar(&colExpr, "cols", "", "column to search for match in the CSV file, a number or header name")
 	flag.BoolVar(&useContains, "contains", false, "use contains phrase for matching")
 	flag.StringVar(&delimiter, "d", "", "set delimiter character")
 	flag.StringVar(&delimiter, "delimiter", "", "set delimiter character")
@@ -229,8 +298,13 @@ func main() {
 	flag.BoolVar(&trimSpaces, "trimspace", false, "trim spaces around cell values before comparing")
 	flag.BoolVar(&trimSpaces, "trimspaces", false, "trim spaces around cell values before comparing")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes on CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leadings space in field(s) for CSV input")
 	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+	flag.StringVar(&metricName, "metric", "", "use a similarity metric for matching, levenshtein, jaro-winkler, token-sort, token-set, jaccard, soundex or metaphone")
+	flag.Float64Var(&threshold, "threshold", 0.85, "the minimum similarity score (0 to 1) for a -metric match")
+	flag.BoolVar(&appendScore, "append-score", false, "append column with the similarity score found (useful for tuning -threshold)")
+	flag.BoolVar(&verbose, "verbose", false, "report the number of Levenshtein comparisons made and pruned to stderr")
 
 	// Parse env and options
 	flag.Parse()
@@ -279,17 +353,20 @@ func main() {
 		eol = "\n"
 	}
 
-	if col <= 0 {
-		fmt.Fprintf(eout, "Cannot have a zero or negative column reference %d\n", col)
-		os.Exit(1)
-	}
-	col = col - 1
-
 	if len(args) == 0 {
 		fmt.Fprintf(eout, "Missing string to match, try %s --help\n", appName)
 		os.Exit(1)
 	}
 
+	var metric datatools.SimilarityFunc
+	if metricName != "" {
+		metric, err = datatools.SimilarityMetric(metricName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
+
 	target := args[0]
 	stopWords := []string{}
 
@@ -303,7 +380,14 @@ func main() {
 		target = strings.Join(datatools.ApplyStopWords(strings.Split(target, " "), stopWords), " ")
 	}
 
-	csvIn := csv.NewReader(in)
+	var src io.Reader
+	src, delimiter, _, err = datatools.SniffInput(in, sniff, delimiter, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	csvIn := csv.NewReader(src)
 	csvIn.LazyQuotes = lazyQuotes
 	csvIn.TrimLeadingSpace = trimLeadingSpace
 	csvOut := csv.NewWriter(out)
@@ -312,13 +396,41 @@ func main() {
 		csvIn.Comma = datatools.NormalizeDelimiterRune(delimiter)
 		csvOut.Comma = datatools.NormalizeDelimiterRune(delimiter)
 	}
-	lineNo := 0
+	if colExpr == "" {
+		fmt.Fprintf(eout, "Missing column to match, try %s --help\n", appName)
+		os.Exit(1)
+	}
+	// NOTE: The first row is used to resolve column names
+	firstRow, err := csvIn.Read()
+	if err != nil && err != io.EOF {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	col, err := datatools.ParseColumn(colExpr, firstRow)
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
 	if skipHeaderRow == true {
-		_, _ = csvIn.Read()
+		firstRow = nil
 	}
+	// The blocker holds the text to match, it prunes cells that can't be
+	// within the maximum edit distance before Levenshtein is computed.
+	var blocker *datatools.Blocker
+	if useLevenshtein == true {
+		blocker = datatools.NewBlocker(datatools.DefaultQGram, datatools.MaxEdits(maxEditDistance, insertCost, deleteCost, substituteCost))
+		blocker.Add(0, target)
+	}
+	lineNo := 0
 	for {
+		var record []string
 		lineNo++
-		record, err := csvIn.Read()
+		if firstRow != nil {
+			record, err = firstRow, nil
+			firstRow = nil
+		} else {
+			record, err = csvIn.Read()
+		}
 		if err == io.EOF {
 			break
 		}
@@ -345,6 +457,19 @@ func main() {
 					src = strings.Join(datatools.ApplyStopWords(fields, stopWords), " ")
 				}
 				switch {
+				case metric != nil:
+					score := metric(src, target)
+					if score >= threshold {
+						if appendScore == true {
+							record = append(record, strconv.FormatFloat(score, 'f', 4, 64))
+						}
+						err := csvOut.Write(record)
+						if err != nil {
+							if !quiet {
+								fmt.Fprintf(eout, "%d %s\n", lineNo, err)
+							}
+						}
+					}
 				case useContains:
 					if strings.Contains(src, target) {
 						err := csvOut.Write(record)
@@ -355,6 +480,9 @@ func main() {
 						}
 					}
 				case useLevenshtein == true:
+					if len(blocker.Candidates(src)) == 0 {
+						break
+					}
 					distance := datatools.Levenshtein(src, target, insertCost, deleteCost, substituteCost, caseSensitive)
 					if distance <= maxEditDistance {
 						if appendEditDistance == true {
@@ -382,11 +510,14 @@ func main() {
 				}
 			} else {
 				if !quiet {
-					fmt.Fprintf(eout, "%d line skipped, missing column %d\n", lineNo, col)
+					fmt.Fprintf(eout, "%d line skipped, missing column %d\n", lineNo, col+1)
 				}
 			}
 		}
 	}
+	if verbose == true && blocker != nil && !quiet {
+		fmt.Fprintf(eout, "%d comparisons, %d pruned by blocking\n", blocker.Comparisons, blocker.Pruned)
+	}
 	csvOut.Flush()
 	err = csvOut.Error()
 	if err != nil {
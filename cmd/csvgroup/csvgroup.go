@@ -0,0 +1,300 @@
+// csvgroup - is a command line that groups CSV rows by one or more columns
+// and writes aggregates (count, sum, avg, ...) for each group.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] [-by COLUMNS] -agg AGGREGATE [-agg AGGREGATE ...]
+
+# DESCRIPTION
+
+{app_name} groups CSV rows by the values of one or more columns and
+writes a CSV file with a header and one row per group. Each row holds
+the group's column values followed by the aggregates. Without -by all
+the rows form a single group. Without -agg the rows in each group are
+counted.
+
+-by takes column numbers (counting from one), header names or ranges
+as used by csvcols (e.g. -by 'Collection,Year'). An AGGREGATE is one of
+
+count
+: the number of rows in the group
+
+sum:COLUMN
+: the sum of the numbers in COLUMN
+
+avg:COLUMN
+: the average of the numbers in COLUMN
+
+min:COLUMN, max:COLUMN
+: the smallest or largest value in COLUMN, compared as numbers if
+all the values are numbers otherwise as strings
+
+distinct:COLUMN
+: the number of different values in COLUMN
+
+concat:COLUMN
+: the values of COLUMN joined with the -separator
+
+Empty cells are skipped by every aggregate except count. An aggregate
+can be given an output column name with NAME= (e.g. 'pages=sum:Pages'),
+otherwise it is named FUNC_COLUMN (e.g. sum_Pages).
+
+Groups are aggregated in memory in the order they are first seen. If
+there are too many groups to hold use -max-groups, rows of groups past
+that limit are written to temporary files and aggregated afterwards.
+Those groups follow the groups held in memory in the output.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-agg
+: an aggregate, may be repeated
+
+-by
+: the columns to group by
+
+-d, -delimiter
+: set the delimiter character
+
+-header-row
+: the first row is a header, columns can be referred to by name (default true)
+
+-i, -input
+: input filename
+
+-max-groups
+: the number of groups aggregated in memory before spilling to
+temporary files (default 0, no limit)
+
+-o, -output
+: output filename
+
+-separator
+: the separator used by concat (default "; ")
+
+-tmpdir
+: directory for temporary files (defaults to the system temp directory)
+
+-sniff
+: guess the delimiter, header row and character encoding of the input (see csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+-crlf
+: use CRLF for end of line (EOL) on write, defaults to true on Windows
+
+# EXAMPLES
+
+Count the items in each collection for each year.
+
+~~~
+    {app_name} -i items.csv -by 'Collection,Year'
+~~~
+
+Total and average pages with the number of different publishers
+for each year.
+
+~~~
+    {app_name} -i books.csv -by Year -agg count \
+        -agg 'pages=sum:Pages' -agg avg:Pages -agg distinct:Publisher
+~~~
+
+Group a large export holding at most a million groups in memory.
+
+~~~
+    {app_name} -i export.csv -o groups.csv -by 1:3 -max-groups 1000000
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	inputFName  string
+	outputFName string
+
+	// App Options
+	by               string
+	aggregates       aggList
+	headerRow        bool
+	separator        string
+	maxGroups        int
+	tmpDir           string
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+	useCRLF          bool
+)
+
+// aggList holds the aggregates from repeated -agg options
+type aggList []string
+
+func (a *aggList) String() string {
+	return strings.Join(*a, " ")
+}
+
+func (a *aggList) Set(val string) error {
+	*a = append(*a, val)
+	return nil
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+	useCRLF = (runtime.GOOS == "windows")
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&inputFName, "input", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.StringVar(&by, "by", "", "the columns to group by")
+	flag.Var(&aggregates, "agg", "an aggregate, count, sum:COLUMN, avg:COLUMN, min:COLUMN, max:COLUMN, distinct:COLUMN or concat:COLUMN, may be repeated")
+	flag.BoolVar(&headerRow, "header-row", true, "the first row is a header, columns can be referred to by name")
+	flag.StringVar(&separator, "separator", "; ", "the separator used by concat")
+	flag.IntVar(&maxGroups, "max-groups", 0, "the number of groups aggregated in memory before spilling to temporary files, 0 is no limit")
+	flag.StringVar(&tmpDir, "tmpdir", "", "directory for temporary files")
+	flag.StringVar(&delimiter, "d", "", "set the delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+
+	// Parse env and options
+	flag.Parse()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	var src io.Reader
+	src, delimiter, headerRow, err = datatools.SniffInput(in, sniff, delimiter, headerRow, "header-row")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+	w := csv.NewWriter(out)
+	w.UseCRLF = useCRLF
+	if delimiter != "" {
+		r.Comma = datatools.NormalizeDelimiterRune(delimiter)
+		w.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	}
+
+	grouper := &datatools.CSVGrouper{
+		Aggregates: aggregates,
+		HeaderRow:  headerRow,
+		Separator:  separator,
+		MaxGroups:  maxGroups,
+		TmpDir:     tmpDir,
+	}
+	if by != "" {
+		grouper.By = []string{by}
+	}
+	if err := grouper.Group(r, w); err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+}
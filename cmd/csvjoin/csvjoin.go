@@ -24,6 +24,7 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"strconv"
 	"strings"
 
 	// My packages
@@ -48,7 +49,65 @@ var (
 {app_name} outputs CSV content based on two CSV files with matching
 column values.  Each CSV input file has a designated column to match
 on. The values are compared as strings. Columns are counted from one
-rather than zero.
+rather than zero. A column may also be given by its name in the
+file's header row or by a negative number counting back from the
+last column. Several columns may be listed, separated by commas, in
+which case every listed column must match (e.g. -col1 2,5 -col2 1,3).
+
+When matching exact values {app_name} builds a hash index of the
+smaller of the two CSV files keyed on the join columns and streams
+the larger one so large files can be joined quickly. The -trimspaces,
+-case-sensitive and -stop-words options are applied when building the
+keys. If CSV1 is the smaller file the output follows the row order of
+CSV2 and the unmatched rows of CSV1 (-join left, full, anti or
+inverted) come last, -keep-order always indexes CSV2 and streams CSV1
+so the output follows the row order of CSV1. The -contains option compares each row of CSV1 against every
+row of CSV2. The -levenshtein option uses a q-gram index of CSV2's
+(first) join column to only compare rows that could be within the
+maximum edit distance, -verbose reports the number of comparisons
+made and pruned. Rows with an empty join column are not
+matched.
+
+The -metric option selects a similarity metric scoring values from
+0 (nothing in common) to 1 (the same), rows are joined when each join
+column scores at least -threshold. The metrics are levenshtein (edit
+distance normalized by length), jaro-winkler (good for short strings
+and names), token-sort (compares sorted words so "Doiel, R. S."
+matches "R. S. Doiel"), token-set (compares the words in common),
+jaccard (shared 3-grams), soundex and metaphone (words that sound
+alike). With -append-score the mean score of the join columns is
+added as the last column. Each row of CSV1 is compared against every
+row of CSV2 when using -metric.
+
+By default only the matching rows are joined (an inner join). The
+-join option selects other join types.
+
+inner
+: output the joined rows that match (the default)
+
+left
+: also output the rows of CSV1 without a match, padded with empty
+cells for the columns of CSV2
+
+right
+: also output the rows of CSV2 without a match, padded with empty
+cells for the columns of CSV1
+
+full
+: output the joined rows that match plus the rows of both CSV1 and
+CSV2 without a match
+
+anti
+: output only the rows of CSV1 without a match in CSV2
+
+inverted
+: output only the rows of CSV1 and CSV2 without a match, padded as
+for a full join
+
+The number of columns used for padding comes from the first row of
+each CSV file. If -header-row is set the first rows are treated as
+headers, they are combined into the first row of the output and
+are not matched.
 
 # OPTIONS
 
@@ -64,14 +123,20 @@ rather than zero.
 -allow-duplicates
 : allow duplicates when searching for matches
 
+-append-score
+: append column with the similarity score of the join columns
+(requires -metric)
+
 -case-sensitive
 : make a case sensitive match (default is case insensitive)
 
 -col1
-: column to on join on in first CSV file
+: column(s) to join on in first CSV file, numbers or header names
+separated by commas
 
 -col2
-: column to on join on in second CSV file
+: column(s) to join on in second CSV file, numbers or header names
+separated by commas
 
 -contains
 : match columns based on csv1/col1 contained in csv2/col2
@@ -82,6 +147,16 @@ rather than zero.
 -csv2
 : second CSV filename
 
+-header-row
+: treat the first row of each CSV file as a header
+
+-inverted
+: output only the rows without a match from both CSV files (same as
+-join inverted)
+
+-join
+: join type, inner, left, right, full, anti or inverted (default inner)
+
 -d, -delimiter
 : set delimiter character
 
@@ -91,6 +166,10 @@ rather than zero.
 -in-memory
 : if true read both CSV files
 
+-keep-order
+: keep the row order of CSV1 when matching exact values, CSV2 is read
+into memory even if it is the larger file
+
 -insert-cost
 : insertion cost to use when calculating Levenshtein edit distance
 
@@ -100,6 +179,10 @@ rather than zero.
 -max-edit-distance
 : maximum edit distance for match using Levenshtein distance
 
+-metric
+: use a similarity metric for matching, levenshtein, jaro-winkler,
+token-sort, token-set, jaccard, soundex or metaphone
+
 -o, -output
 : output filename
 
@@ -112,6 +195,13 @@ rather than zero.
 -substitute-cost
 : substitution cost to use when calculating Levenshtein edit distance
 
+-threshold
+: the minimum similarity score (0 to 1) for a -metric match (default 0.85)
+
+-sniff
+: guess the delimiter, header row and character encoding of each CSV
+file (see csvsniff), the header row and output delimiter follow CSV1
+
 -trim-leading-space
 : trim leading space in field(s) for CSV input
 
@@ -141,6 +231,41 @@ merged-data.csv..
        -output=merged-data.csv
 ~~~
 
+Join on two columns, the second and fifth of data1.csv matching the
+first and third of data2.csv, ignoring surrounding spaces.
+
+~~~
+    {app_name} -csv1=data1.csv -col1=2,5 \
+       -csv2=data2.csv -col2=1,3 \
+       -trimspaces -output=merged-data.csv
+~~~
+
+List the rows of data1.csv that have no match in data2.csv keeping
+the header row.
+
+~~~
+    {app_name} -csv1=data1.csv -col1=Title \
+       -csv2=data2.csv -col2="Book Title" \
+       -header-row -join anti
+~~~
+
+Join author names regardless of word order keeping the score.
+
+~~~
+    {app_name} -csv1=people.csv -col1=Name \
+       -csv2=authors.csv -col2=Author \
+       -metric=token-sort -threshold=0.9 -append-score \
+       -header-row -output=merged-data.csv
+~~~
+
+The same join using the names from each file's header row.
+
+~~~
+    {app_name} -csv1=data1.csv -col1=Title \
+       -csv2=data2.csv -col2="Book Title" \
+       -output=merged-data.csv
+~~~
+
 {app_name} {version}
 
 `
@@ -159,8 +284,8 @@ merged-data.csv..
 	verbose          bool
 	csv1FName        string
 	csv2FName        string
-	col1             int
-	col2             int
+	col1Expr         string
+	col2Expr         string
 	trimSpaces       bool
 	caseSensitive    bool
 	useContains      bool
@@ -174,22 +299,51 @@ merged-data.csv..
 	asInMemory       bool
 	delimiter        string
 	lazyQuotes       bool
+	sniff            bool
 	trimLeadingSpace bool
 	useCRLF          bool
+	joinType         string
+	inverted         bool
+	headerRow        bool
+	metricName       string
+	threshold        float64
+	appendScore      bool
+	keepOrder        bool
+
+	// metric is the similarity function selected by -metric
+	metric datatools.SimilarityFunc
+
+	// matched2 tracks which rows of the second CSV file found a match,
+	// width1 and width2 are the number of columns used to pad rows.
+	matched2 []bool
+	width1   int
+	width2   int
+
+	// tableRows holds the row numbers compared when there is no index,
+	// blocker limits Levenshtein comparisons to plausible rows.
+	tableRows []int
+	blocker   *datatools.Blocker
 )
 
 
-// cellsMatch checks if two cells' values match
-func cellsMatch(val1, val2 string, stopWords []string) bool {
+// normalizeCell applies the -trimspaces, case folding and stop word
+// options to a cell value before it is compared
+func normalizeCell(val string, stopWords []string) string {
 	if trimSpaces == true {
-		val2 = strings.TrimSpace(val2)
+		val = strings.TrimSpace(val)
 	}
 	if caseSensitive == false {
-		val2 = strings.ToLower(val2)
+		val = strings.ToLower(val)
 	}
 	if len(stopWords) > 0 {
-		val2 = strings.Join(datatools.ApplyStopWords(strings.Split(val2, " "), stopWords), " ")
+		val = strings.Join(datatools.ApplyStopWords(strings.Split(val, " "), stopWords), " ")
 	}
+	return val
+}
+
+// cellsMatch checks if two cells' values match
+func cellsMatch(val1, val2 string, stopWords []string) bool {
+	val2 = normalizeCell(val2, stopWords)
 	switch {
 	case useLevenshtein == true:
 		distance := datatools.Levenshtein(val2, val1, insertCost, deleteCost, substituteCost, caseSensitive)
@@ -208,46 +362,209 @@ func cellsMatch(val1, val2 string, stopWords []string) bool {
 	return false
 }
 
-func scanTable(eout io.Writer, w *csv.Writer, rowA []string, col1 int, table [][]string, col2 int, stopWords []string) error {
-	if col1 >= len(rowA) {
-		return nil
+// joinValues returns the normalized values of the join columns of row,
+// ok is false if a join column is missing or empty.
+func joinValues(row []string, cols []int, stopWords []string) ([]string, bool) {
+	vals := make([]string, len(cols))
+	for i, col := range cols {
+		if col >= len(row) || row[col] == "" {
+			return nil, false
+		}
+		vals[i] = normalizeCell(row[col], stopWords)
 	}
-	val1 := rowA[col1]
-	if trimSpaces == true {
-		val1 = strings.TrimSpace(val1)
+	return vals, true
+}
+
+// joinKey combines normalized join column values into a hash index key
+func joinKey(vals []string) string {
+	return strings.Join(vals, "\x1f")
+}
+
+// indexTable maps the join key of each row in table to its row numbers
+func indexTable(table [][]string, cols []int, stopWords []string) map[string][]int {
+	index := map[string][]int{}
+	for i, row := range table {
+		if vals, ok := joinValues(row, cols, stopWords); ok {
+			key := joinKey(vals)
+			index[key] = append(index[key], i)
+		}
 	}
-	if caseSensitive == false {
-		val1 = strings.ToLower(val1)
+	return index
+}
+
+// rowsMatch checks if each join column of rowB matches the normalized
+// values of rowA's join columns. For a -metric match it also returns
+// the mean similarity score of the join columns.
+func rowsMatch(vals1 []string, rowB []string, cols2 []int, stopWords []string) (bool, float64) {
+	total := 0.0
+	for i, col2 := range cols2 {
+		if col2 >= len(rowB) {
+			return false, 0
+		}
+		if metric != nil {
+			score := metric(vals1[i], normalizeCell(rowB[col2], stopWords))
+			if score < threshold {
+				return false, 0
+			}
+			total += score
+		} else if cellsMatch(vals1[i], rowB[col2], stopWords) == false {
+			return false, 0
+		}
 	}
-	if len(stopWords) > 0 {
-		val1 = strings.Join(datatools.ApplyStopWords(strings.Split(val1, " "), stopWords), " ")
+	return true, total / float64(len(cols2))
+}
+
+// scoreCell appends the score column to row if -append-score is set
+func scoreCell(row []string, score string) []string {
+	if appendScore {
+		return append(row, score)
+	}
+	return row
+}
+
+// padRow returns a copy of row with empty cells appended to make it width wide
+func padRow(row []string, width int) []string {
+	padded := append([]string{}, row...)
+	for len(padded) < width {
+		padded = append(padded, "")
+	}
+	return padded
+}
+
+// combineRows returns a new row joining rowA and rowB, rowA is padded
+// for the outer join types
+func combineRows(rowA []string, rowB []string) []string {
+	if joinType != "inner" {
+		return append(padRow(rowA, width1), rowB...)
+	}
+	row := make([]string, 0, len(rowA)+len(rowB))
+	row = append(row, rowA...)
+	return append(row, rowB...)
+}
+
+// emitMatches is true when the join type outputs the matched rows
+func emitMatches() bool {
+	return joinType != "anti" && joinType != "inverted"
+}
+
+// scanTable writes rowA joined with each matching row of table, it returns
+// true if a match was found. If index is not nil only the rows of table
+// sharing rowA's join key are compared otherwise every row is.
+func scanTable(eout io.Writer, w *csv.Writer, rowA []string, cols1 []int, table [][]string, cols2 []int, index map[string][]int, stopWords []string) (bool, error) {
+	matched := false
+	vals1, ok := joinValues(rowA, cols1, stopWords)
+	if !ok {
+		return matched, nil
+	}
+	candidates := tableRows
+	switch {
+	case index != nil:
+		candidates = index[joinKey(vals1)]
+	case blocker != nil:
+		candidates = blocker.Candidates(vals1[0])
 	}
-	for i, rowB := range table {
+	for _, i := range candidates {
+		rowB := table[i]
 		// Emit a joined row if we have a match
-		if col2 < len(rowB) {
-			val2 := rowB[col2]
-			if cellsMatch(val1, val2, stopWords) == true {
+		if ok, score := rowsMatch(vals1, rowB, cols2, stopWords); ok == true {
+			matched = true
+			matched2[i] = true
+			if emitMatches() {
 				// We have a match, join the two rows and output
-				combinedRows := append(rowA, rowB...)
-				if err := w.Write(combinedRows); err != nil {
-					return fmt.Errorf("Can't write csv row line %d of table 2, %s\n", i, err)
+				row := scoreCell(combineRows(rowA, rowB), strconv.FormatFloat(score, 'f', 4, 64))
+				if err := w.Write(row); err != nil {
+					return matched, fmt.Errorf("Can't write csv row line %d of table 2, %s\n", i, err)
 				}
 				w.Flush()
 				if verbose == true {
 					fmt.Fprint(eout, "*")
 				}
 				if err := w.Error(); err != nil {
+					return matched, err
+				}
+			}
+			if allowDuplicates == false {
+				return matched, nil
+			}
+		}
+	}
+	return matched, nil
+}
+
+// joinRow scans table for matches to rowA, depending on the join type
+// rowA is also written when no match is found.
+func joinRow(eout io.Writer, w *csv.Writer, rowA []string, cols1 []int, table [][]string, cols2 []int, index map[string][]int, stopWords []string) error {
+	// We are relying on the side effect of writing the CSV output in scanTable
+	matched, err := scanTable(eout, w, rowA, cols1, table, cols2, index, stopWords)
+	if err != nil || matched {
+		return err
+	}
+	switch joinType {
+	case "left", "full", "inverted":
+		return w.Write(scoreCell(append(padRow(rowA, width1), make([]string, width2)...), ""))
+	case "anti":
+		return w.Write(rowA)
+	}
+	return nil
+}
+
+// joinIndexedRow looks up rowB of the second CSV file in the index of
+// the first CSV file's table and writes the joined rows. It is used
+// when the first CSV file is the smaller of the two.
+func joinIndexedRow(eout io.Writer, w *csv.Writer, rowB []string, cols2 []int, table [][]string, index map[string][]int, matched1 []bool, stopWords []string) error {
+	matched := false
+	if vals2, ok := joinValues(rowB, cols2, stopWords); ok {
+		for _, i := range index[joinKey(vals2)] {
+			if matched1[i] && allowDuplicates == false {
+				continue
+			}
+			matched, matched1[i] = true, true
+			if emitMatches() {
+				if err := w.Write(combineRows(table[i], rowB)); err != nil {
 					return err
 				}
-				if allowDuplicates == false {
-					return nil
+				if verbose == true {
+					fmt.Fprint(eout, "*")
 				}
 			}
 		}
 	}
+	if !matched && (joinType == "right" || joinType == "full" || joinType == "inverted") {
+		return w.Write(append(make([]string, width1), rowB...))
+	}
 	return nil
 }
 
+// readTable reads the rest of a CSV file into memory, first is the row
+// already read (if not nil).
+func readTable(eout io.Writer, r *csv.Reader, fName string, first []string) [][]string {
+	table := [][]string{}
+	if first != nil {
+		table = append(table, first)
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !quiet {
+				fmt.Fprintf(eout, "%s, %s (%T %+v)\n", fName, err, record, record)
+			}
+		}
+		table = append(table, record)
+	}
+	return table
+}
+
+// fileSize returns the size of a file in bytes or zero if unknown
+func fileSize(fName string) int64 {
+	if info, err := os.Stat(fName); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
 func main() {
 	appName := path.Base(os.Args[0])
 	version := datatools.Version
@@ -269,8 +586,8 @@ func main() {
 	flag.BoolVar(&verbose, "verbose", false, "output processing count to stderr")
 	flag.StringVar(&csv1FName, "csv1", "", "first CSV filename")
 	flag.StringVar(&csv2FName, "csv2", "", "second CSV filename")
-	flag.IntVar(&col1, "col1", 0, "column to on join on in first CSV file")
-	flag.IntVar(&col2, "col2", 0, "column to on join on in second CSV file")
+	flag.StringVar(&col1Expr, "col1", "", "column(s) to join on in first CSV file, numbers or header names separated by commas")
+	flag.StringVar(&col2Expr, "col2", "", "column(s) to join on in second CSV file, numbers or header names separated by commas")
 	flag.BoolVar(&caseSensitive, "case-sensitive", false, "make a case sensitive match (default is case insensitive)")
 	flag.BoolVar(&useContains, "contains", false, "match columns based on csv1/col1 contained in csv2/col2")
 	flag.BoolVar(&useLevenshtein, "levenshtein", false, "match columns using Levensthein edit distance")
@@ -282,11 +599,19 @@ func main() {
 	flag.BoolVar(&allowDuplicates, "allow-duplicates", true, "allow duplicates when searching for matches")
 	flag.BoolVar(&trimSpaces, "trimspaces", false, "trim spaces around cell values before comparing")
 	flag.BoolVar(&asInMemory, "in-memory", false, "if true read both CSV files")
+	flag.BoolVar(&keepOrder, "keep-order", false, "keep the row order of CSV1, CSV2 is read into memory")
 	flag.StringVar(&delimiter, "d", "", "set delimiter character")
 	flag.StringVar(&delimiter, "delimiter", "", "set delimiter character")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of each CSV file")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
 	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+	flag.StringVar(&joinType, "join", "inner", "join type, inner, left, right, full, anti or inverted")
+	flag.BoolVar(&inverted, "inverted", false, "output only the rows without a match from both CSV files (same as -join inverted)")
+	flag.BoolVar(&headerRow, "header-row", false, "treat the first row of each CSV file as a header")
+	flag.StringVar(&metricName, "metric", "", "use a similarity metric for matching, levenshtein, jaro-winkler, token-sort, token-set, jaccard, soundex or metaphone")
+	flag.Float64Var(&threshold, "threshold", 0.85, "the minimum similarity score (0 to 1) for a -metric match")
+	flag.BoolVar(&appendScore, "append-score", false, "append column with the similarity score of the join columns (requires -metric)")
 
 	// Parse env and options
 	flag.Parse()
@@ -320,49 +645,67 @@ func main() {
 		os.Exit(0)
 	}
 
-	// NOTE: We are counting columns for humans from 1 rather than zero.
-	if col1 <= 0 {
-		fmt.Fprintf(eout, "col1 must be one or greater, %d\n", col1)
+	if col1Expr == "" {
+		fmt.Fprintln(eout, "Missing col1")
 		os.Exit(1)
 	}
-	if col2 <= 0 {
-		fmt.Fprintf(eout, "col2 must be one or greater, %d\n", col2)
+	if col2Expr == "" {
+		fmt.Fprintln(eout, "Missing col2")
 		os.Exit(1)
 	}
-	col1--
-	col2--
 
-	if len(csv1FName) == 0 {
-		fmt.Fprintln(eout, "Missing first CSV filename")
+	if inverted {
+		joinType = "inverted"
+	}
+	joinType = strings.ToLower(joinType)
+	switch joinType {
+	case "inner", "left", "right", "full", "anti", "inverted":
+	default:
+		fmt.Fprintf(eout, "Unknown join type %q\n", joinType)
 		os.Exit(1)
 	}
 
-	if len(csv2FName) == 0 {
-		fmt.Fprintln(eout, "Missing second CSV filename")
+	if metricName != "" {
+		metric, err = datatools.SimilarityMetric(metricName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
+	if appendScore && metric == nil {
+		fmt.Fprintln(eout, "-append-score requires -metric")
 		os.Exit(1)
 	}
 
-	if col1 < 0 {
-		fmt.Fprintf(eout, "Cannot use a negative column index %d\n", col1)
+	if len(csv1FName) == 0 {
+		fmt.Fprintln(eout, "Missing first CSV filename")
 		os.Exit(1)
 	}
-	if col2 < 0 {
-		fmt.Fprintf(eout, "Cannot use a negative column index %d\n", col2)
+
+	if len(csv2FName) == 0 {
+		fmt.Fprintln(eout, "Missing second CSV filename")
 		os.Exit(1)
 	}
 
-	// FIXME: Should only read the smaller of two files into memory
-	// then interate through the other file for matches. This would let you work with larger files.
-
-	// Read in CSV2 to memory then iterate over CSV1 output rows that have
-	// matching column's value
+	// Exact matches are found with a hash index of the smaller CSV file
+	// while the other file is streamed. Fuzzy matches compare each row of
+	// CSV1 against every row of CSV2 which is read into memory.
 	fp1, err := os.Open(csv1FName)
 	if err != nil {
 		fmt.Fprintln(eout, err)
 		os.Exit(1)
 	}
 	defer fp1.Close()
-	csv1 := csv.NewReader(fp1)
+	var (
+		src1   io.Reader
+		comma1 string
+	)
+	src1, comma1, headerRow, err = datatools.SniffInput(fp1, sniff, delimiter, headerRow, "header-row")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", csv1FName, err)
+		os.Exit(1)
+	}
+	csv1 := csv.NewReader(src1)
 	csv1.LazyQuotes = lazyQuotes
 	csv1.TrimLeadingSpace = trimLeadingSpace
 
@@ -372,38 +715,80 @@ func main() {
 		os.Exit(1)
 	}
 	defer fp2.Close()
-	csv2 := csv.NewReader(fp2)
+	src2, comma2, _, err := datatools.SniffInput(fp2, sniff, delimiter, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", csv2FName, err)
+		os.Exit(1)
+	}
+	csv2 := csv.NewReader(src2)
 	csv2.LazyQuotes = lazyQuotes
 	csv2.TrimLeadingSpace = trimLeadingSpace
 
 	w := csv.NewWriter(out)
 	w.UseCRLF = useCRLF
-	if delimiter != "" {
-		csv1.Comma = datatools.NormalizeDelimiterRune(delimiter)
-		csv2.Comma = datatools.NormalizeDelimiterRune(delimiter)
-		w.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	if comma1 != "" {
+		csv1.Comma = datatools.NormalizeDelimiterRune(comma1)
+		w.Comma = datatools.NormalizeDelimiterRune(comma1)
+	}
+	if comma2 != "" {
+		csv2.Comma = datatools.NormalizeDelimiterRune(comma2)
 	}
 
-	// Note: we read one of the tables into memory to speed things up and limit disc reads
-	csv2Table := [][]string{}
-	for {
-		record, err := csv2.Read()
-		if err == io.EOF {
-			break
+	// NOTE: The first row of each CSV file is used to resolve column names
+	header1, err := csv1.Read()
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(eout, "%s, %s\n", csv1FName, err)
+		os.Exit(1)
+	}
+	header2, err := csv2.Read()
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(eout, "%s, %s\n", csv2FName, err)
+		os.Exit(1)
+	}
+	cols1, err := datatools.ParseColumns(col1Expr, header1)
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", csv1FName, err)
+		os.Exit(1)
+	}
+	cols2, err := datatools.ParseColumns(col2Expr, header2)
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", csv2FName, err)
+		os.Exit(1)
+	}
+	if len(cols1) != len(cols2) {
+		fmt.Fprintf(eout, "col1 has %d columns, col2 has %d, they must be the same\n", len(cols1), len(cols2))
+		os.Exit(1)
+	}
+	width1, width2 = len(header1), len(header2)
+	if headerRow {
+		// The header rows are combined and not matched
+		row := header1
+		if joinType != "anti" {
+			row = scoreCell(append(padRow(header1, width1), header2...), "score")
 		}
-		if err != nil {
-			if !quiet {
-				fmt.Fprintf(eout, "%s, %s (%T %+v)\n", csv2FName, err, record, record)
-			}
+		if err := w.Write(row); err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
 		}
-		csv2Table = append(csv2Table, record)
+		header1, header2 = nil, nil
 	}
 
 	stopWords := strings.Split(stopWordsOption, ":")
-	lineNo := 0 // line number of csv 1 table
-	if asInMemory == false {
+	useIndex := (useLevenshtein == false && useContains == false && metric == nil)
+	lineNo := 0 // line number of the streamed csv table
+	if useIndex && !keepOrder && fileSize(csv1FName) < fileSize(csv2FName) {
+		// Index CSV1 and stream CSV2
+		csv1Table := readTable(eout, csv1, csv1FName, header1)
+		index := indexTable(csv1Table, cols1, stopWords)
+		matched1 := make([]bool, len(csv1Table))
 		for {
-			rowA, err := csv1.Read()
+			var rowB []string
+			if header2 != nil {
+				rowB, err = header2, nil
+				header2 = nil
+			} else {
+				rowB, err = csv2.Read()
+			}
 			if err == io.EOF {
 				break
 			}
@@ -412,60 +797,129 @@ func main() {
 					fmt.Fprintf(eout, "%d %s\n", lineNo, err)
 				}
 			} else {
-				if col1 < len(rowA) && rowA[col1] != "" {
-					// We are relying on the side effect of writing the CSV output in scanTable
-					if err := scanTable(eout, w, rowA, col1, csv2Table, col2, stopWords); err != nil {
-						if !quiet {
-							fmt.Fprintf(eout, "Can't write CSV at line %d of csv table 1, %s\n", lineNo, err)
-						}
+				if err := joinIndexedRow(eout, w, rowB, cols2, csv1Table, index, matched1, stopWords); err != nil {
+					if !quiet {
+						fmt.Fprintf(eout, "Can't write CSV at line %d of csv table 2, %s\n", lineNo, err)
 					}
 				}
 				if verbose == true {
 					if (lineNo%100) == 0 && lineNo > 0 {
 						if !quiet {
-							fmt.Fprintf(eout, "\n%d rows of %s processed\n", lineNo, csv1FName)
+							fmt.Fprintf(eout, "\n%d rows of %s processed\n", lineNo, csv2FName)
 						}
 					}
 				}
 			}
 			lineNo++
 		}
-	} else {
-		csv1Table := [][]string{}
-
-		// Read table 1 into memory
-		for {
-			record, err := csv1.Read()
-			if err == io.EOF {
-				break
+		// Output the rows of the first CSV file that didn't match
+		if joinType == "left" || joinType == "full" || joinType == "anti" || joinType == "inverted" {
+			for i, rowA := range csv1Table {
+				if matched1[i] {
+					continue
+				}
+				row := rowA
+				if joinType != "anti" {
+					row = append(padRow(rowA, width1), make([]string, width2)...)
+				}
+				if err := w.Write(row); err != nil {
+					if !quiet {
+						fmt.Fprintf(eout, "Can't write CSV at line %d of csv table 1, %s\n", i, err)
+					}
+				}
 			}
-			if err != nil {
-				if !quiet {
-					fmt.Fprintf(eout, "%s, %s (%T %+v)\n", csv1FName, err, record, record)
+		}
+	} else {
+		// Note: we read one of the tables into memory to speed things up and limit disc reads
+		csv2Table := readTable(eout, csv2, csv2FName, header2)
+		matched2 = make([]bool, len(csv2Table))
+		var index map[string][]int
+		switch {
+		case useIndex:
+			index = indexTable(csv2Table, cols2, stopWords)
+		case useLevenshtein && metric == nil && datatools.MaxEdits(maxEditDistance, insertCost, deleteCost, substituteCost) >= 0:
+			// Block on the first join column, a match requires all columns to match
+			blocker = datatools.NewBlocker(datatools.DefaultQGram, datatools.MaxEdits(maxEditDistance, insertCost, deleteCost, substituteCost))
+			for i, rowB := range csv2Table {
+				if cols2[0] < len(rowB) {
+					blocker.Add(i, normalizeCell(rowB[cols2[0]], stopWords))
 				}
 			}
-			csv1Table = append(csv1Table, record)
+		default:
+			tableRows = make([]int, len(csv2Table))
+			for i := range tableRows {
+				tableRows[i] = i
+			}
 		}
-		// For each row in table one scan table two.
-		for i, rowA := range csv1Table {
-			if col1 < len(rowA) && rowA[col1] != "" {
-				// We are relying on the side effect of writing the CSV output in scanTable
-				if err := scanTable(eout, w, rowA, col1, csv2Table, col2, stopWords); err != nil {
+		if asInMemory == false {
+			for {
+				var rowA []string
+				if header1 != nil {
+					rowA, err = header1, nil
+					header1 = nil
+				} else {
+					rowA, err = csv1.Read()
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if !quiet {
+						fmt.Fprintf(eout, "%d %s\n", lineNo, err)
+					}
+				} else {
+					if err := joinRow(eout, w, rowA, cols1, csv2Table, cols2, index, stopWords); err != nil {
+						if !quiet {
+							fmt.Fprintf(eout, "Can't write CSV at line %d of csv table 1, %s\n", lineNo, err)
+						}
+					}
+					if verbose == true {
+						if (lineNo%100) == 0 && lineNo > 0 {
+							if !quiet {
+								fmt.Fprintf(eout, "\n%d rows of %s processed\n", lineNo, csv1FName)
+							}
+						}
+					}
+				}
+				lineNo++
+			}
+		} else {
+			// Read table 1 into memory
+			csv1Table := readTable(eout, csv1, csv1FName, header1)
+			// For each row in table one scan table two.
+			for i, rowA := range csv1Table {
+				if err := joinRow(eout, w, rowA, cols1, csv2Table, cols2, index, stopWords); err != nil {
 					if !quiet {
 						fmt.Fprintf(eout, "Can't write CSV at line %d of csv table 1, %s\n", lineNo, err)
 					}
 				}
+				if verbose == true {
+					if (lineNo%100) == 0 && lineNo > 0 {
+						if !quiet {
+							fmt.Fprintf(eout, "%d rows of %s processed\n", lineNo, csv1FName)
+						}
+					}
+				}
+				lineNo = i
 			}
-			if verbose == true {
-				if (lineNo%100) == 0 && lineNo > 0 {
+		}
+		// Output the rows of the second CSV file that didn't match
+		if joinType == "right" || joinType == "full" || joinType == "inverted" {
+			for i, rowB := range csv2Table {
+				if matched2[i] {
+					continue
+				}
+				if err := w.Write(scoreCell(append(make([]string, width1), rowB...), "")); err != nil {
 					if !quiet {
-						fmt.Fprintf(eout, "%d rows of %s processed\n", lineNo, csv1FName)
+						fmt.Fprintf(eout, "Can't write CSV at line %d of csv table 2, %s\n", i, err)
 					}
 				}
 			}
-			lineNo = i
 		}
 	}
+	if verbose == true && blocker != nil && !quiet {
+		fmt.Fprintf(eout, "\n%d comparisons, %d pruned by blocking\n", blocker.Comparisons, blocker.Pruned)
+	}
 	w.Flush()
 	err = w.Error()
 	if err != nil {
@@ -0,0 +1,325 @@
+// csvpivot - is a command line that pivots key/value rows of CSV content
+// into columns or melts columns into key/value rows.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] -id COLUMNS -key COLUMN -value COLUMN
+
+{app_name} [OPTIONS] -melt -id COLUMNS [-cols COLUMNS]
+
+# DESCRIPTION
+
+{app_name} reshapes CSV content between long and wide forms.
+
+By default it pivots a long table of key/value rows into a wide one.
+Rows with the same -id column values are combined into one row. The
+values of the -key column become new columns holding the values of
+the -value column. Rows and columns are in the order first seen. If a
+row has more than one value for a key -agg chooses how they are
+combined, one of first, last, count, sum, avg, min, max, distinct or
+concat. Without -agg more than one value is an error.
+
+With -melt it does the reverse, each of the -cols columns (by default
+the columns not in -id) becomes a row holding the -id columns, the
+column's name (-key-name) and its value (-value-name).
+
+Columns are given as numbers (counting from one), header names or
+ranges as used by csvcols. Use csvrotate to transpose a CSV file.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-agg
+: combine more than one value for a key, first, last, count, sum, avg, min, max, distinct or concat
+
+-cols
+: the columns to melt (defaults to the columns not in -id)
+
+-d, -delimiter
+: set the delimiter character
+
+-header-row
+: the first row is a header, columns can be referred to by name (default true)
+
+-i, -input
+: input filename
+
+-id
+: the columns identifying a row
+
+-key
+: the column holding the new column names when pivoting
+
+-key-name
+: the name of the key column when melting (default "key")
+
+-melt
+: melt columns into key/value rows
+
+-o, -output
+: output filename
+
+-separator
+: the separator used by concat (default "; ")
+
+-skip-empty
+: leave out empty cells when melting
+
+-sniff
+: guess the delimiter, header row and character encoding of the input (see csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+-value
+: the column holding the values when pivoting
+
+-value-name
+: the name of the value column when melting (default "value")
+
+-crlf
+: use CRLF for end of line (EOL) on write, defaults to true on Windows
+
+# EXAMPLES
+
+Given items.csv
+
+~~~
+    collection,year,items
+    books,2017,10
+    books,2018,12
+    maps,2017,3
+~~~
+
+Pivot to a column per year
+
+~~~
+    {app_name} -i items.csv -id collection -key year -value items
+~~~
+
+Yields
+
+~~~
+    collection,2017,2018
+    books,10,12
+    maps,3,
+~~~
+
+Melt it back into rows skipping the empty cell
+
+~~~
+    {app_name} -i wide.csv -melt -id collection \
+        -key-name year -value-name items -skip-empty
+~~~
+
+Total the items of each collection per year when there is more
+than one row for a year
+
+~~~
+    {app_name} -i items.csv -id collection -key year -value items -agg sum
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	inputFName  string
+	outputFName string
+
+	// App Options
+	melt             bool
+	ids              string
+	columns          string
+	key              string
+	value            string
+	keyName          string
+	valueName        string
+	skipEmpty        bool
+	aggregate        string
+	separator        string
+	headerRow        bool
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+	useCRLF          bool
+)
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+	useCRLF = (runtime.GOOS == "windows")
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&inputFName, "input", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.BoolVar(&melt, "melt", false, "melt columns into key/value rows")
+	flag.StringVar(&ids, "id", "", "the columns identifying a row")
+	flag.StringVar(&columns, "cols", "", "the columns to melt (defaults to the columns not in -id)")
+	flag.StringVar(&key, "key", "", "the column holding the new column names when pivoting")
+	flag.StringVar(&value, "value", "", "the column holding the values when pivoting")
+	flag.StringVar(&keyName, "key-name", "key", "the name of the key column when melting")
+	flag.StringVar(&valueName, "value-name", "value", "the name of the value column when melting")
+	flag.BoolVar(&skipEmpty, "skip-empty", false, "leave out empty cells when melting")
+	flag.StringVar(&aggregate, "agg", "", "combine more than one value for a key, first, last, count, sum, avg, min, max, distinct or concat")
+	flag.StringVar(&separator, "separator", "; ", "the separator used by concat")
+	flag.BoolVar(&headerRow, "header-row", true, "the first row is a header, columns can be referred to by name")
+	flag.StringVar(&delimiter, "d", "", "set the delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+
+	// Parse env and options
+	flag.Parse()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if !melt && (key == "" || value == "") {
+		fmt.Fprintf(eout, "Missing -key or -value, try %s -help\n", appName)
+		os.Exit(1)
+	}
+
+	var src io.Reader
+	src, delimiter, headerRow, err = datatools.SniffInput(in, sniff, delimiter, headerRow, "header-row")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+	w := csv.NewWriter(out)
+	w.UseCRLF = useCRLF
+	if delimiter != "" {
+		r.Comma = datatools.NormalizeDelimiterRune(delimiter)
+		w.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	}
+
+	if melt {
+		melter := &datatools.CSVMelter{
+			IDs:       ids,
+			Columns:   columns,
+			HeaderRow: headerRow,
+			KeyName:   keyName,
+			ValueName: valueName,
+			SkipEmpty: skipEmpty,
+		}
+		err = melter.Melt(r, w)
+	} else {
+		pivoter := &datatools.CSVPivoter{
+			IDs:       ids,
+			Key:       key,
+			Value:     value,
+			HeaderRow: headerRow,
+			Aggregate: aggregate,
+			Separator: separator,
+		}
+		err = pivoter.Pivot(r, w)
+	}
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+}
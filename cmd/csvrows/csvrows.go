@@ -21,6 +21,7 @@ import (
 	"encoding/csv"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"runtime"
@@ -28,6 +29,7 @@ import (
 
 	// Caltech Library packages
 	"github.com/caltechlibrary/datatools"
+	"github.com/caltechlibrary/datatools/filter"
 )
 
 const (
@@ -85,10 +87,13 @@ and {app_name} makes it easy to output only the data rows.
 : return N randomly selected rows
 
 -row, -rows
-: output specified rows in order (e.g. -row 1,5,2-4))
+: output specified rows in order (e.g. -row 1,5,2:4), see RANGES
 
 -skip-header-row
-: skip the header row (alias for -row 2-
+: skip the header row (alias for -row 2:)
+
+-sniff
+: guess the delimiter and character encoding of the input (see csvsniff)
 
 -trim-leading-space
 : trim leading space in field(s) for CSV input
@@ -96,9 +101,79 @@ and {app_name} makes it easy to output only the data rows.
 -use-lazy-quotes
 : use lazy quotes for CSV input
 
+-where
+: only output rows matching a filter expression, see FILTERS
+
 -crlf
 : use a CRLF for end of line (EOL) on write, defaults to true on Windows
 
+# RANGES
+
+Rows are counted from one. A range expression is a comma separated
+list of row numbers and ranges. Zero is not a row number, earlier
+versions treated -row 0 as the first row, it is now an error.
+
+5
+: the fifth row
+
+-1
+: the last row
+
+2:10
+: the second through the tenth row (2-10 also works)
+
+2:
+: the second through the last row
+
+:10
+: the first ten rows
+
+-20:
+: the last twenty rows
+
+1:100:5
+: every fifth row from the first through the hundredth
+
+all
+: every row
+
+Rows are read as a stream, when a range counts back from the end
+only that many rows are held in memory.
+
+# FILTERS
+
+A filter expression uses a prefix notation to test the cells of each
+row. The first row is treated as the header row, columns can be
+referred to by number or name.
+
+~~~
+    (and (eq (cols 1) "x") (gt (cols "Published") "2017-06-12"))
+~~~
+
+(cols N ...)
+: the value of a column (or a list for more than one), N counts from one, negative counts back from the last, or is a column name
+
+(colNo NAME)
+: the number of a named column
+
+(eq A B), (ne A B), (lt A B), (le A B), (gt A B), (ge A B)
+: compare numbers numerically, dates chronologically and other values as strings
+
+(in A B ...)
+: true if A equals any of the other values
+
+(and ...), (or ...), (not A)
+: combine conditions
+
+(match A REGEXP)
+: true if A matches the regular expression
+
+(contains A B), (starts-with A B), (ends-with A B), (empty A)
+: test strings
+
+(lower A), (upper A), (trim A), (length A), (join LIST SEP), (concat A B ...)
+: string functions
+
 # EXAMPLES
 
 Simple usage of building a CSV file one rows at a time.
@@ -136,6 +211,20 @@ a header row from 10row.csv.
 	{app_name} -i 10row.csv -header=true -random=3
 ~~~
 
+Show the header row and the rows of books.csv published after
+2017-06-12 with more than 100 pages
+
+~~~
+	{app_name} -i books.csv -header=true \
+	    -where '(and (gt (cols "Published") "2017-06-12") (gt (cols "Pages") 100))'
+~~~
+
+Show the header row and the last 20 rows of data.csv
+
+~~~
+	{app_name} -i data.csv -header=true -row -20:
+~~~
+
 {app_name} {version}
 
 `
@@ -158,9 +247,11 @@ a header row from 10row.csv.
 	showHeader       bool
 	skipHeaderRow    bool
 	outputRows       string
+	where            string
 	delimiter        string
 	randomRows       int
 	lazyQuotes       bool
+	sniff            bool
 	trimLeadingSpace bool
 	useCRLF          bool
 )
@@ -187,12 +278,14 @@ func main() {
 	// Application specific options
 	flag.StringVar(&delimiter, "d", "", "set delimiter character")
 	flag.StringVar(&delimiter, "delimiter", "", "set delimiter character")
-	flag.StringVar(&outputRows, "row", "", "output specified rows in order (e.g. -row 1,5,2-4))")
-	flag.StringVar(&outputRows, "rows", "", "output specified rows in order (e.g. -row 1,5,2-4))")
-	flag.BoolVar(&skipHeaderRow, "skip-header-row", false, "skip the header row (alias for -row 2-")
+	flag.StringVar(&outputRows, "row", "", "output specified rows in order (e.g. -row 1,5,2:4)")
+	flag.StringVar(&outputRows, "rows", "", "output specified rows in order (e.g. -row 1,5,2:4)")
+	flag.BoolVar(&skipHeaderRow, "skip-header-row", false, "skip the header row (alias for -row 2:)")
 	flag.BoolVar(&showHeader, "header", false, "display the header row (alias for '-rows 1')")
 	flag.IntVar(&randomRows, "random", 0, "return N randomly selected rows")
+	flag.StringVar(&where, "where", "", "only output rows matching a filter expression")
 	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
 	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
 	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use a CRLF for end of line (EOL) on write")
 
@@ -239,36 +332,50 @@ func main() {
 		os.Exit(0)
 	}
 
+	var src io.Reader
+	src, delimiter, _, err = datatools.SniffInput(in, sniff, delimiter, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
 	if randomRows > 0 {
-		if err := datatools.CSVRandomRows(in, out, showHeader, randomRows, delimiter, lazyQuotes, trimLeadingSpace); err != nil {
+		if err := datatools.CSVRandomRows(src, out, showHeader, randomRows, delimiter, lazyQuotes, trimLeadingSpace); err != nil {
 			fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
 			os.Exit(1)
 		}
 		os.Exit(0)
 	}
 
+	if skipHeaderRow && outputRows == "" {
+		outputRows = "2:"
+	}
+	var rowFilter datatools.RowFilter
+	if where != "" {
+		f, err := filter.Parse(where)
+		if err != nil {
+			fmt.Fprintf(eout, "-where %s\n", err)
+			os.Exit(1)
+		}
+		rowFilter = f
+		if outputRows == "" {
+			outputRows = "all"
+		}
+	}
 	if outputRows != "" {
-		rowNos, err := datatools.ParseRange(outputRows)
+		expr, err := datatools.ParseRangeExpr(outputRows)
 		if err != nil {
 			fmt.Fprintln(eout, err)
 			os.Exit(1)
 		}
-
-		// NOTE: We need to adjust from humans counting from 1 to counting from zero
-		for i := 0; i < len(rowNos); i++ {
-			rowNos[i] = rowNos[i] - 1
-			if rowNos[i] < 0 {
-				rowNos[i] = 0
-			}
-		}
-		if err := datatools.CSVRows(in, out, showHeader, rowNos, delimiter, lazyQuotes, trimLeadingSpace); err != nil {
+		if err := datatools.CSVRowsRange(src, out, showHeader, expr, rowFilter, delimiter, lazyQuotes, trimLeadingSpace); err != nil {
 			fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
 			os.Exit(1)
 		}
 		os.Exit(0)
 	}
 	if inputFName != "" {
-		if err := datatools.CSVRowsAll(in, out, showHeader, delimiter, lazyQuotes, trimLeadingSpace); err != nil {
+		if err := datatools.CSVRowsAll(src, out, showHeader, delimiter, lazyQuotes, trimLeadingSpace); err != nil {
 			fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
 			os.Exit(1)
 		}
@@ -0,0 +1,285 @@
+// csvsort - is a command line that sorts CSV content by one or more columns.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] -key KEY [-key KEY ...]
+
+# DESCRIPTION
+
+{app_name} sorts CSV content by one or more columns. The keys are
+applied from left to right, the first -key is the primary sort
+order, the second breaks ties in the first and so on. Rows with
+equal keys keep their original order.
+
+A KEY has the form
+
+~~~
+    [+|-]COLUMN[:TYPE[:LAYOUT]]
+~~~
+
+A leading "-" sorts the column in descending order, "+" (or nothing)
+sorts in ascending order. COLUMN is a column number (counting from
+one) or the column's name in the header row. TYPE is one of
+
+string
+: compare the cells as strings (the default)
+
+nocase
+: compare the cells as strings ignoring case
+
+numeric
+: compare the cells as decimal numbers
+
+natural
+: compare strings treating runs of digits as numbers (e.g. "v2" before "v10")
+
+date
+: compare the cells as dates parsed with the Go time LAYOUT
+(defaults to "2006-01-02")
+
+Cells that can't be parsed as a number or date are sorted after those
+that can.
+
+Content larger than -max-memory is sorted in runs which are written
+to temporary files then merged. This makes it possible to sort files
+larger than available RAM. Cells containing quoted line breaks are
+kept intact.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-d, -delimiter
+: set the delimiter character
+
+-header-row
+: the first row is a header, keep it at the top (default true)
+
+-i, -input
+: input filename
+
+-key
+: a sort key, may be repeated
+
+-max-memory
+: megabytes of CSV content to sort in memory before using temporary
+files (default 64)
+
+-o, -output
+: output filename
+
+-tmpdir
+: directory for temporary files (defaults to the system temp directory)
+
+-sniff
+: guess the delimiter, header row and character encoding of the input (see csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+-crlf
+: use CRLF for end of line (EOL) on write, defaults to true on Windows
+
+# EXAMPLES
+
+Sort books.csv by "Year" with the most recent first then by "Title"
+ignoring case.
+
+~~~
+    {app_name} -i books.csv -key '-Year:numeric' -key 'Title:nocase'
+~~~
+
+Sort a large export by the date in column 3 written as "Jan 2, 2006".
+
+~~~
+    {app_name} -i export.csv -o sorted.csv -max-memory 512 \
+       -key '3:date:Jan 2, 2006'
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	inputFName  string
+	outputFName string
+
+	// App Options
+	keys             keyList
+	headerRow        bool
+	maxMemory        int
+	tmpDir           string
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+	useCRLF          bool
+)
+
+// keyList holds the sort keys from repeated -key options
+type keyList []string
+
+func (k *keyList) String() string {
+	return strings.Join(*k, " ")
+}
+
+func (k *keyList) Set(val string) error {
+	*k = append(*k, val)
+	return nil
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+	useCRLF = (runtime.GOOS == "windows")
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&inputFName, "input", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.Var(&keys, "key", "a sort key, [+|-]COLUMN[:TYPE[:LAYOUT]], may be repeated")
+	flag.BoolVar(&headerRow, "header-row", true, "the first row is a header, keep it at the top")
+	flag.IntVar(&maxMemory, "max-memory", 64, "megabytes of CSV content to sort in memory before using temporary files")
+	flag.StringVar(&tmpDir, "tmpdir", "", "directory for temporary files")
+	flag.StringVar(&delimiter, "d", "", "set the delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+
+	// Parse env and options
+	flag.Parse()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if len(keys) == 0 {
+		fmt.Fprintf(eout, "Missing sort key, try %s -help\n", appName)
+		os.Exit(1)
+	}
+
+	var src io.Reader
+	src, delimiter, headerRow, err = datatools.SniffInput(in, sniff, delimiter, headerRow, "header-row")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+	w := csv.NewWriter(out)
+	w.UseCRLF = useCRLF
+	if delimiter != "" {
+		r.Comma = datatools.NormalizeDelimiterRune(delimiter)
+		w.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	}
+
+	sorter := &datatools.CSVSorter{
+		Keys:      keys,
+		HeaderRow: headerRow,
+		MaxMemory: int64(maxMemory) * 1024 * 1024,
+		TmpDir:    tmpDir,
+	}
+	if err := sorter.Sort(r, w); err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,294 @@
+// csvsplit - is a command line that splits a CSV file into several files
+// by a number of rows, a size or the values of a column.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] -rows N|-bytes SIZE|-col COLUMN
+
+# DESCRIPTION
+
+{app_name} splits CSV content into several files, each starting with
+the header row. It splits by
+
+-rows N
+: N rows (not counting the header) per file, named PREFIX-0001.csv,
+PREFIX-0002.csv, ...
+
+-bytes SIZE
+: files of at most SIZE bytes (e.g. 500K, 10M or 1G), a row larger
+than SIZE is written to a file of its own
+
+-col COLUMN
+: one file for each value of COLUMN named PREFIX-VALUE.csv, characters
+other than letters, digits, "-", "_" and "." in the value are replaced
+by "_" and empty values go to PREFIX-empty.csv
+
+COLUMN is a column number (counting from one) or a header name. The
+PREFIX defaults to the input filename without its extension (or
+"split" when reading standard input) and may include a directory.
+Existing files are replaced. The names of the files written are
+listed on standard output.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-bytes
+: the largest size of a file (e.g. 500K, 10M, 1G)
+
+-col
+: split into a file for each value of this column
+
+-d, -delimiter
+: set the delimiter character
+
+-header-row
+: repeat the first row at the top of each file (default true)
+
+-i, -input
+: input filename
+
+-max-open
+: the number of files kept open splitting by column (default 128)
+
+-prefix
+: the prefix of the file names
+
+-quiet
+: don't list the files written
+
+-rows
+: the number of rows in each file
+
+-sniff
+: guess the delimiter, header row and character encoding of the input (see csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+-crlf
+: use CRLF for end of line (EOL) on write, defaults to true on Windows
+
+# EXAMPLES
+
+Split export.csv into files of 10,000 rows named export-0001.csv, ...
+
+~~~
+    {app_name} -i export.csv -rows 10000
+~~~
+
+Split export.csv into files small enough to email.
+
+~~~
+    {app_name} -i export.csv -bytes 10M -prefix parts/export
+~~~
+
+Write a file for each year, e.g. by-year-2017.csv.
+
+~~~
+    {app_name} -i export.csv -col Year -prefix by-year
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	inputFName  string
+	quiet       bool
+
+	// App Options
+	rows             int
+	size             string
+	column           string
+	prefix           string
+	headerRow        bool
+	maxOpen          int
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+	useCRLF          bool
+)
+
+// parseSize parses a size in bytes with an optional K, M or G suffix
+func parseSize(s string) (int64, error) {
+	val := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	scale := int64(1)
+	switch {
+	case strings.HasSuffix(val, "K"):
+		scale = 1 << 10
+	case strings.HasSuffix(val, "M"):
+		scale = 1 << 20
+	case strings.HasSuffix(val, "G"):
+		scale = 1 << 30
+	}
+	if scale > 1 {
+		val = val[:len(val)-1]
+	}
+	i, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+	if err != nil || i <= 0 {
+		return 0, fmt.Errorf("%q is not a size like 500K, 10M or 1G", s)
+	}
+	return i * scale, nil
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+	useCRLF = (runtime.GOOS == "windows")
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&inputFName, "input", "", "input filename")
+	flag.BoolVar(&quiet, "quiet", false, "don't list the files written")
+
+	// App Options
+	flag.IntVar(&rows, "rows", 0, "the number of rows in each file")
+	flag.StringVar(&size, "bytes", "", "the largest size of a file (e.g. 500K, 10M, 1G)")
+	flag.StringVar(&column, "col", "", "split into a file for each value of this column")
+	flag.StringVar(&prefix, "prefix", "", "the prefix of the file names")
+	flag.BoolVar(&headerRow, "header-row", true, "repeat the first row at the top of each file")
+	flag.IntVar(&maxOpen, "max-open", datatools.DefaultMaxOpen, "the number of files kept open splitting by column")
+	flag.StringVar(&delimiter, "d", "", "set the delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+
+	// Parse env and options
+	flag.Parse()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	splitter := &datatools.CSVSplitter{
+		Rows:      rows,
+		Column:    column,
+		HeaderRow: headerRow,
+		Prefix:    prefix,
+		UseCRLF:   useCRLF,
+		MaxOpen:   maxOpen,
+	}
+	if size != "" {
+		if splitter.Bytes, err = parseSize(size); err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
+	if splitter.Prefix == "" && inputFName != "" && inputFName != "-" {
+		splitter.Prefix = strings.TrimSuffix(inputFName, path.Ext(inputFName))
+	}
+
+	var src io.Reader
+	src, delimiter, headerRow, err = datatools.SniffInput(in, sniff, delimiter, headerRow, "header-row")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+	if delimiter != "" {
+		r.Comma = datatools.NormalizeDelimiterRune(delimiter)
+		splitter.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	}
+	err = splitter.Split(r)
+	if !quiet {
+		for _, fName := range splitter.Files {
+			fmt.Fprintln(out, fName)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,321 @@
+// csvsql - is a command line that loads CSV files into an in-memory SQLite3
+// database and renders the results of a SQL statement as CSV.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] SQL_STATEMENT [CSV_FILE ...]
+
+# DESCRIPTION
+
+{app_name} loads one or more CSV files into temporary tables of an
+in-memory SQLite3 database then runs SQL_STATEMENT against them.
+The results are rendered as CSV to standard out in the same way
+as sql2csv.
+
+Each CSV file becomes a table named after the file without its
+extension (e.g. "books.csv" becomes the table "books"). Characters
+that are not letters, numbers or underscores are replaced by an
+underscore. Use the -table option to choose the table name explicitly.
+A CSV filename of "-" reads from standard input into a table named
+"stdin".
+
+The first row of each CSV file is used for the column names. Cells
+that look like integers or decimal numbers are stored as numbers so
+comparisons and aggregate functions work as expected, everything
+else is stored as text. Empty cells are stored as NULL, so aggregate
+functions skip them, and NULL values are written as empty cells.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-d, -delimiter
+: set the input delimiter character
+
+-od, -output-delimiter
+: set the output delimiter character
+
+-header-row
+: write a header row if true (default true)
+
+-o, -output
+: output filename
+
+-sql FILENAME
+: read the SQL statement from a file instead of the command line
+
+-table NAME=CSV_FILE
+: load CSV_FILE into the table NAME, may be repeated
+
+-sniff
+: guess the delimiter and character encoding of each CSV file (see
+csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+-crlf
+: use CRLF for end of line (EOL) on write, defaults to true on Windows
+
+# EXAMPLES
+
+Count the books per year in books.csv.
+
+~~~
+    {app_name} 'SELECT year, COUNT(*) AS total FROM books GROUP BY year' \
+        books.csv
+~~~
+
+Join two CSV files giving each an explicit table name.
+
+~~~
+    {app_name} -table a=data1.csv -table b=data2.csv \
+        'SELECT a.*, b.email FROM a JOIN b ON a.id = b.id'
+~~~
+
+Query CSV content read from standard input.
+
+~~~
+    cat books.csv | {app_name} 'SELECT title FROM stdin WHERE year > 2000' -
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	outputFName string
+
+	// App Options
+	tables           tableList
+	sqlFName         string
+	writeHeaderRow   bool
+	delimiter        string
+	outputDelimiter  string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+	useCRLF          bool
+)
+
+// tableList holds the NAME=CSV_FILE pairs from repeated -table options
+type tableList []string
+
+func (t *tableList) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tableList) Set(val string) error {
+	if !strings.Contains(val, "=") {
+		return fmt.Errorf("expected NAME=CSV_FILE, got %q", val)
+	}
+	*t = append(*t, val)
+	return nil
+}
+
+// loadTable reads a CSV file (or standard input for "-") into tableName
+func loadTable(store *datatools.SQLStore, tableName string, fName string) error {
+	var in io.Reader
+	if fName == "-" {
+		in = os.Stdin
+	} else {
+		fp, err := os.Open(fName)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		in = fp
+	}
+	src, comma, _, err := datatools.SniffInput(in, sniff, delimiter, false, "")
+	if err != nil {
+		return fmt.Errorf("%s, %s", fName, err)
+	}
+	r := csv.NewReader(src)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+	if comma != "" {
+		r.Comma = datatools.NormalizeDelimiterRune(comma)
+	}
+	if err := store.LoadCSV(tableName, r); err != nil {
+		return fmt.Errorf("%s, %s", fName, err)
+	}
+	return nil
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+	useCRLF = (runtime.GOOS == "windows")
+	writeHeaderRow = true
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.Var(&tables, "table", "load a CSV file into a named table, NAME=CSV_FILE")
+	flag.StringVar(&sqlFName, "sql", "", "read the SQL statement from a file")
+	flag.BoolVar(&writeHeaderRow, "header-row", writeHeaderRow, "write a header row if true")
+	flag.StringVar(&delimiter, "d", "", "set the input delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the input delimiter character")
+	flag.StringVar(&outputDelimiter, "od", "", "set the output delimiter character")
+	flag.StringVar(&outputDelimiter, "output-delimiter", "", "set the output delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of each CSV file")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+
+	// Parse env and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup IO
+	var err error
+
+	out := os.Stdout
+	eout := os.Stderr
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	// Figure out the SQL statement and the CSV files to load
+	stmt := ""
+	if sqlFName != "" {
+		src, err := os.ReadFile(sqlFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		stmt = fmt.Sprintf("%s", src)
+	} else {
+		if len(args) == 0 {
+			fmt.Fprintf(eout, "Missing SQL statement, try %s -help\n", appName)
+			os.Exit(1)
+		}
+		stmt, args = args[0], args[1:]
+	}
+	if len(args) == 0 && len(tables) == 0 {
+		fmt.Fprintf(eout, "Missing CSV file(s) to query, try %s -help\n", appName)
+		os.Exit(1)
+	}
+
+	store, err := datatools.OpenCSVStore()
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	for _, val := range tables {
+		tableName, fName, _ := strings.Cut(val, "=")
+		if err := loadTable(store, tableName, fName); err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
+	for _, fName := range args {
+		tableName := "stdin"
+		if fName != "-" {
+			tableName = datatools.CSVTableName(fName)
+		}
+		if err := loadTable(store, tableName, fName); err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+	}
+
+	w := csv.NewWriter(out)
+	w.UseCRLF = useCRLF
+	if outputDelimiter != "" {
+		w.Comma = datatools.NormalizeDelimiterRune(outputDelimiter)
+	}
+	store.WriteHeaderRow = writeHeaderRow
+	if err := store.QueryToCSV(w, stmt); err != nil {
+		w.Flush()
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+}
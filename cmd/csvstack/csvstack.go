@@ -0,0 +1,230 @@
+// csvstack - is a command line that concatenates CSV files aligning their
+// columns by header name.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] CSV_FILE [CSV_FILE ...]
+
+# DESCRIPTION
+
+{app_name} concatenates CSV files into one. The columns are aligned
+by their header names so the files can have their columns in a
+different order or have columns the others lack. The output header
+holds every column in the order they are first seen, cells of columns
+a file doesn't have are left empty. Names are matched ignoring case
+and surrounding spaces unless -exact-names is used, a name repeated
+in a header is matched by its occurrence. Use "-" to read standard
+input.
+
+-source adds a first column holding the name of the file each row
+came from.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-d, -delimiter
+: set the delimiter character
+
+-exact-names
+: header names must match exactly
+
+-header-row
+: the first row of each file is a header (default true), if false
+the rows are concatenated as is
+
+-o, -output
+: output filename
+
+-source
+: add a first column with this name holding the source filename
+
+-sniff
+: guess the delimiter and character encoding of each file (see csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+-crlf
+: use CRLF for end of line (EOL) on write, defaults to true on Windows
+
+# EXAMPLES
+
+Combine the monthly exports into one file noting where each row came
+from.
+
+~~~
+    {app_name} -source filename -o 2017.csv export-2017-*.csv
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	outputFName string
+
+	// App Options
+	headerRow        bool
+	exactNames       bool
+	sourceColumn     string
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+	useCRLF          bool
+)
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+	useCRLF = (runtime.GOOS == "windows")
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.BoolVar(&headerRow, "header-row", true, "the first row of each file is a header")
+	flag.BoolVar(&exactNames, "exact-names", false, "header names must match exactly")
+	flag.StringVar(&sourceColumn, "source", "", "add a first column with this name holding the source filename")
+	flag.StringVar(&delimiter, "d", "", "set the delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of each file")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+	flag.BoolVar(&useCRLF, "crlf", useCRLF, "use CRLF for end of line (EOL) on write")
+
+	// Parse env and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup IO
+	var err error
+
+	out := os.Stdout
+	eout := os.Stderr
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if len(args) == 0 {
+		fmt.Fprintf(eout, "Missing CSV files, try %s -help\n", appName)
+		os.Exit(1)
+	}
+
+	readers := []*csv.Reader{}
+	for _, fName := range args {
+		in := os.Stdin
+		if fName != "-" {
+			in, err = os.Open(fName)
+			if err != nil {
+				fmt.Fprintln(eout, err)
+				os.Exit(1)
+			}
+			defer in.Close()
+		}
+		src, comma, _, err := datatools.SniffInput(in, sniff, delimiter, false, "")
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", fName, err)
+			os.Exit(1)
+		}
+		r := csv.NewReader(src)
+		r.LazyQuotes = lazyQuotes
+		r.TrimLeadingSpace = trimLeadingSpace
+		if comma != "" {
+			r.Comma = datatools.NormalizeDelimiterRune(comma)
+		}
+		readers = append(readers, r)
+	}
+	w := csv.NewWriter(out)
+	w.UseCRLF = useCRLF
+	if delimiter != "" {
+		w.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	}
+
+	stacker := &datatools.CSVStacker{
+		HeaderRow:    headerRow,
+		ExactNames:   exactNames,
+		SourceColumn: sourceColumn,
+	}
+	if err := stacker.Stack(readers, args, w); err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+}
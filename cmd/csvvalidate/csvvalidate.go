@@ -0,0 +1,304 @@
+// csvvalidate - is a command line that validates CSV content against a
+// Frictionless Data Table Schema.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] -schema SCHEMA_JSON
+
+{app_name} [OPTIONS] -infer
+
+# DESCRIPTION
+
+{app_name} checks CSV content against a Frictionless Data Table Schema,
+https://specs.frictionlessdata.io/table-schema/. The schema is a JSON
+document describing each field's name, type, format and constraints
+(required, unique, enum, pattern, minLength, maxLength, minimum and
+maximum) along with the table's primary key and foreign keys.
+
+The CSV content must start with a header row, fields are matched to
+columns by name. The rows are streamed and every violation is written
+as a line of JSON (JSON Lines) with the row, column, field, value, the
+kind of error and a message. Row numbers count the header as row 1.
+{app_name} exits with a non-zero status if any violation was found.
+
+Foreign keys referring to the table itself (an empty resource) are
+checked after the last row. Foreign keys referring to another resource
+need the -resource option to name the CSV file holding it.
+
+The -infer option reads a sample of the CSV content and writes a draft
+schema to be edited, e.g. to add constraints.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-d, -delimiter
+: set the delimiter character
+
+-i, -input
+: input filename
+
+-infer
+: write a draft schema inferred from the input instead of validating
+
+-max-violations N
+: stop after N violations (default 0, no limit)
+
+-o, -output
+: output filename
+
+-resource NAME=CSV_FILE
+: the CSV file for a foreign key's resource, may be repeated
+
+-sample N
+: the number of rows sampled by -infer (default 1000)
+
+-schema SCHEMA_JSON
+: the Table Schema to validate against
+
+-sniff
+: guess the delimiter and character encoding of the input (see csvsniff)
+
+-trim-leading-space
+: trim leading space in field(s) for CSV input
+
+-use-lazy-quotes
+: use lazy quotes for CSV input
+
+# EXAMPLES
+
+Draft a schema for a dataset, edit it then validate the dataset.
+
+~~~
+    {app_name} -infer -i books.csv -o books-schema.json
+    {app_name} -schema books-schema.json -i books.csv
+~~~
+
+A violation looks like
+
+~~~
+    {"row":12,"column":3,"field":"year","value":"19O4","error":"type","message":"expected integer, invalid syntax"}
+~~~
+
+Check the author_id foreign key against a second file.
+
+~~~
+    {app_name} -schema books-schema.json -resource authors=authors.csv \
+        -i books.csv
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	inputFName  string
+	outputFName string
+
+	// App Options
+	schemaFName      string
+	infer            bool
+	sampleRows       int
+	resources        resourceList
+	maxViolations    int
+	delimiter        string
+	lazyQuotes       bool
+	sniff            bool
+	trimLeadingSpace bool
+)
+
+type resourceList []string
+
+func (r *resourceList) String() string {
+	return strings.Join(*r, " ")
+}
+
+func (r *resourceList) Set(val string) error {
+	if !strings.Contains(val, "=") {
+		return fmt.Errorf("expected NAME=CSV_FILE, got %q", val)
+	}
+	*r = append(*r, val)
+	return nil
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&inputFName, "input", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.StringVar(&schemaFName, "schema", "", "the Table Schema to validate against")
+	flag.BoolVar(&infer, "infer", false, "write a draft schema inferred from the input")
+	flag.IntVar(&sampleRows, "sample", datatools.DefaultInferRows, "the number of rows sampled by -infer")
+	flag.Var(&resources, "resource", "the CSV file for a foreign key's resource, NAME=CSV_FILE, may be repeated")
+	flag.IntVar(&maxViolations, "max-violations", 0, "stop after this many violations, 0 is no limit")
+	flag.StringVar(&delimiter, "d", "", "set the delimiter character")
+	flag.StringVar(&delimiter, "delimiter", "", "set the delimiter character")
+	flag.BoolVar(&lazyQuotes, "use-lazy-quotes", false, "use lazy quotes for CSV input")
+	flag.BoolVar(&sniff, "sniff", false, "guess the dialect and encoding of the input")
+	flag.BoolVar(&trimLeadingSpace, "trim-leading-space", false, "trim leading space in field(s) for CSV input")
+
+	// Parse env and options
+	flag.Parse()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if schemaFName == "" && !infer {
+		fmt.Fprintf(eout, "Missing -schema or -infer, try %s -help\n", appName)
+		os.Exit(1)
+	}
+
+	var src io.Reader
+	src, delimiter, _, err = datatools.SniffInput(in, sniff, delimiter, false, "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	r := csv.NewReader(src)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+	if delimiter != "" {
+		r.Comma = datatools.NormalizeDelimiterRune(delimiter)
+	}
+
+	if infer {
+		schema, err := datatools.InferTableSchema(r, sampleRows)
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+			os.Exit(1)
+		}
+		src, err := datatools.JSONMarshalIndent(schema, "", "    ")
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(out, "%s", src)
+		os.Exit(0)
+	}
+
+	schema, err := datatools.ReadTableSchema(schemaFName)
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	validator := &datatools.TableValidator{
+		Schema:        schema,
+		Resources:     map[string]string{},
+		MaxViolations: maxViolations,
+	}
+	for _, resource := range resources {
+		name, fName, _ := strings.Cut(resource, "=")
+		validator.Resources[name] = fName
+	}
+	n, err := validator.Validate(r, func(violation *datatools.SchemaViolation) error {
+		src, err := datatools.JSONMarshal(violation)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", src)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+	if n > 0 {
+		os.Exit(1)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"strings"
 
 	// Caltech Library Packages
 	"github.com/caltechlibrary/datatools"
@@ -41,7 +42,8 @@ returning the results in columns.  This is helpful in flattening content
 extracted from JSON blobs.  The default delimiter for each value
 extracted is a comma. This can be overridden with an option.
 
-- EXPRESSION can be an empty string or dot notation for an object's path
+- EXPRESSION can be an empty string or dot notation for an object's path,
+  a JSONPath or a JSON Pointer (see QUERIES)
 - INPUT_FILENAME is the filename to read or a dash "-" if you want to
   explicitly read from stdin
 	- if not provided then {app_name} reads from stdin
@@ -49,6 +51,26 @@ extracted is a comma. This can be overridden with an option.
   explicitly write to stdout
 	- if not provided then {app_name} write to stdout
 
+# QUERIES
+
+Each EXPRESSION is evaluated with the syntax its prefix indicates.
+
+.
+: dot notation (dotpath), e.g. .authors[0].orcid
+
+$
+: JSONPath (RFC 9535), e.g. $.authors[?@.orcid].orcid, supporting
+wildcards, slices, recursive descent (..) and filter expressions with
+the length(), count(), match(), search() and value() functions. The
+values matched are returned as a JSON array.
+
+/
+: JSON Pointer (RFC 6901), e.g. /authors/0/orcid
+
+An expression may also name its syntax explicitly with "dotpath:",
+"jsonpath:" or "pointer:", e.g. "pointer:" is the JSON Pointer
+to the whole document.
+
 # OPTIONS
 
 -help
@@ -137,6 +159,20 @@ Would yield
    "Doe, Jane","jane.doe@xample.org",42
 ~~~
 
+Pull every ORCID from a deposit record, wherever it appears, and the
+ORCIDs of the authors with an affiliation.
+
+~~~
+    {app_name} -i record.json '$..orcid' \
+        '$.authors[?@.affiliation].orcid'
+~~~
+
+Get the title of the first version using a JSON Pointer
+
+~~~
+    {app_name} -i record.json /versions/0/title
+~~~
+
 {app_name} {version}
 
 `
@@ -163,6 +199,24 @@ Would yield
 	useCRLF        bool
 )
 
+// evalExpression evaluates a dotpath, JSONPath or JSON Pointer
+// expression depending on its prefix. JSONPath returns the list of
+// values selected.
+func evalExpression(qry string, data interface{}) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(qry, "dotpath:"):
+		return dotpath.Eval(strings.TrimPrefix(qry, "dotpath:"), data)
+	case strings.HasPrefix(qry, "jsonpath:"):
+		return datatools.JSONPathEval(strings.TrimPrefix(qry, "jsonpath:"), data)
+	case strings.HasPrefix(qry, "pointer:"):
+		return datatools.JSONPointerEval(strings.TrimPrefix(qry, "pointer:"), data)
+	case strings.HasPrefix(qry, "$"):
+		return datatools.JSONPathEval(qry, data)
+	case strings.HasPrefix(qry, "/"):
+		return datatools.JSONPointerEval(qry, data)
+	}
+	return dotpath.Eval(qry, data)
+}
 
 func main() {
 	var (
@@ -275,7 +329,7 @@ func main() {
 		// For each dotpath expression return a result
 		row := []string{}
 		for _, qry := range expressions {
-			result, err := dotpath.Eval(qry, data)
+			result, err := evalExpression(qry, data)
 			if err != nil {
 				fmt.Fprintln(eout, err)
 				os.Exit(1)
@@ -328,7 +382,7 @@ func main() {
 		if qry == "." {
 			fmt.Fprintf(out, "%s", buf)
 		} else {
-			result, err := dotpath.Eval(qry, data)
+			result, err := evalExpression(qry, data)
 			if err != nil {
 				fmt.Fprintln(eout, err)
 				os.Exit(1)
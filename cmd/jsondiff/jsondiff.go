@@ -0,0 +1,326 @@
+// jsondiff - is a command line that compares two JSON documents and reports
+// the differences as a JSON Patch, a JSON Merge Patch or a list of changes.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] OLD_JSON NEW_JSON
+
+# DESCRIPTION
+
+{app_name} compares two JSON documents structurally and reports what
+changed. The order of object keys is ignored and numbers are compared
+by value (e.g. 1 and 1.0 are the same). Either file may be "-" to read
+standard input.
+
+Arrays are compared by position. With -array-key arrays whose elements
+are all objects with a unique value for the key are compared by
+matching elements on that value, so an element added to the start of a
+list is reported as one addition rather than a change to every element.
+
+The -format option controls the output.
+
+patch
+: a JSON Patch (RFC 6902) which turns OLD_JSON into NEW_JSON, it can
+be applied with jsonpatch
+
+merge
+: a JSON Merge Patch (RFC 7396), a merge patch can't set a value to null
+and replaces changed arrays whole. If NEW_JSON has a null value the
+patch would remove, the patch is written with a warning for each of
+these values and {app_name} exits with 1
+
+report
+: a human readable list of the values added, removed, replaced or moved
+by JSON Pointer followed by counts of each
+
+With -jsonl each file is read as JSON lines and compared as an array of
+records, use -array-key to match the records on an identifier.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-array-key
+: match the elements of arrays of objects on this key
+
+-format
+: output format, patch, merge or report (default patch)
+
+-jsonl
+: read OLD_JSON and NEW_JSON as JSON lines
+
+-o, -output
+: output filename
+
+-p, -pretty
+: pretty print JSON output
+
+# EXAMPLES
+
+Create a patch from the corrections made to a codemeta.json file
+
+~~~
+    {app_name} -p codemeta.json codemeta-fixed.json >fixes.json
+    jsonpatch -i codemeta.json fixes.json
+~~~
+
+Review what a template change did to records exported as JSON lines,
+matching records on their "id".
+
+~~~
+    {app_name} -jsonl -array-key id -format report \
+       before.jsonl after.jsonl
+~~~
+
+Would produce something like
+
+~~~
+    ~ replaced /12/title: "A Title " -> "A Title"
+    - removed /40/note: "draft"
+    2 changes, 0 added, 1 removed, 1 replaced, 0 moved
+~~~
+
+The JSON Pointers give the position of each record after the changes
+before it.
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	outputFName string
+
+	// App Options
+	arrayKey    string
+	format      string
+	jsonLines   bool
+	prettyPrint bool
+)
+
+// readJSON reads a JSON document, or JSON lines as an array
+func readJSON(in io.Reader, asLines bool) (interface{}, error) {
+	if !asLines {
+		src, err := ioutil.ReadAll(in)
+		if err != nil {
+			return nil, err
+		}
+		var doc interface{}
+		err = datatools.JSONUnmarshal(src, &doc)
+		return doc, err
+	}
+	records := []interface{}{}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record interface{}
+		if err := datatools.JSONUnmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("line %d, %s", lineNo, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// jsonValue renders a value on one line for the report
+func jsonValue(val interface{}) string {
+	src, err := datatools.JSONMarshal(val)
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+	return string(src)
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.StringVar(&arrayKey, "array-key", "", "match the elements of arrays of objects on this key")
+	flag.StringVar(&format, "format", "patch", "output format, patch, merge or report")
+	flag.BoolVar(&jsonLines, "jsonl", false, "read OLD_JSON and NEW_JSON as JSON lines")
+	flag.BoolVar(&prettyPrint, "p", false, "pretty print JSON output")
+	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print JSON output")
+
+	// Parse env and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup IO
+	var err error
+
+	out := os.Stdout
+	eout := os.Stderr
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if len(args) != 2 {
+		fmt.Fprintf(eout, "Expected OLD_JSON and NEW_JSON, try %s -help\n", appName)
+		os.Exit(1)
+	}
+	switch format {
+	case "patch", "merge", "report":
+	default:
+		fmt.Fprintf(eout, "Unknown format %q, expected patch, merge or report\n", format)
+		os.Exit(1)
+	}
+
+	docs := []interface{}{}
+	for _, fName := range args {
+		var in io.Reader
+		if fName == "-" {
+			in = os.Stdin
+		} else {
+			fp, err := os.Open(fName)
+			if err != nil {
+				fmt.Fprintln(eout, err)
+				os.Exit(1)
+			}
+			defer fp.Close()
+			in = fp
+		}
+		doc, err := readJSON(in, jsonLines)
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", fName, err)
+			os.Exit(1)
+		}
+		docs = append(docs, doc)
+	}
+
+	differ := &datatools.JSONDiffer{
+		ArrayKey: arrayKey,
+	}
+	if format == "report" {
+		counts := map[string]int{}
+		changes := differ.Diff(docs[0], docs[1])
+		for _, change := range changes {
+			counts[change.Op]++
+			switch change.Op {
+			case "add":
+				fmt.Fprintf(out, "+ added %s: %s\n", change.Path, jsonValue(change.New))
+			case "remove":
+				fmt.Fprintf(out, "- removed %s: %s\n", change.Path, jsonValue(change.Old))
+			case "replace":
+				fmt.Fprintf(out, "~ replaced %s: %s -> %s\n", change.Path, jsonValue(change.Old), jsonValue(change.New))
+			case "move":
+				fmt.Fprintf(out, "> moved %s -> %s\n", change.From, change.Path)
+			}
+		}
+		fmt.Fprintf(out, "%d changes, %d added, %d removed, %d replaced, %d moved\n", len(changes), counts["add"], counts["remove"], counts["replace"], counts["move"])
+		os.Exit(0)
+	}
+
+	var result interface{}
+	// lost holds the changes a merge patch can't express
+	lost := []*datatools.JSONChange{}
+	if format == "merge" {
+		result = datatools.JSONMergeDiff(docs[0], docs[1])
+		// A null in a merge patch removes the member so values set to
+		// null in NEW_JSON are lost, check by applying the patch
+		lost = (&datatools.JSONDiffer{}).Diff(datatools.ApplyMergePatch(docs[0], result), docs[1])
+	} else {
+		result = differ.Patch(docs[0], docs[1])
+	}
+	var src []byte
+	eol := "\n"
+	if prettyPrint {
+		// JSONMarshalIndent ends with a newline already
+		src, err = datatools.JSONMarshalIndent(result, "", "    ")
+		eol = ""
+	} else {
+		src, err = datatools.JSONMarshal(result)
+	}
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(out, "%s%s", src, eol)
+	if len(lost) > 0 {
+		for _, change := range lost {
+			fmt.Fprintf(eout, "warning: %s is null in NEW_JSON, the merge patch removes it\n", change.Path)
+		}
+		os.Exit(1)
+	}
+}
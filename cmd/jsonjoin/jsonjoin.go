@@ -40,6 +40,32 @@ If you use the update or overwrite options you will create a merged
 object. The update option keeps the attribute value first encountered
 and overwrite takes the last attribute value encountered.
 
+The update and overwrite options only look at the top level attributes,
+a nested object is replaced whole. The deep option merges objects at
+every level attribute by attribute. When two values can't be merged
+(e.g. two different strings) the last value is kept, or the first if
+update is also used. The arrays option sets how two arrays are merged,
+
+replace
+: the array is treated like any other value (the default)
+
+concat
+: the later array's elements are appended
+
+union
+: the later array's elements not already present are appended
+
+index
+: elements at the same position are merged
+
+key
+: elements are objects matched and merged on the value of the attribute
+named by array-key, unmatched elements are appended
+
+The conflicts option writes each conflict to standard error as a line
+of JSON giving the source file, JSON Pointer path, the old and new
+values and which was kept.
+
 # OPTIONS
 
 -help
@@ -72,6 +98,18 @@ display version
 -overwrite
 : update first object with the second object, overwriting existing attributes
 
+-deep
+: merge nested objects attribute by attribute
+
+-arrays
+: how -deep merges arrays, replace, concat, union, index or key (default replace)
+
+-array-key
+: the identity attribute of array elements merged with -arrays key
+
+-conflicts
+: write the conflicts found by -deep to standard error as JSON lines
+
 # EXAMPLES
 
 This is an example of take "my1.json" and "my2.json"
@@ -98,6 +136,14 @@ as replacement values.
     jsonjoin -overwrite my1.json my2.json >my.json
 ~~~
 
+Combine partial metadata records from several sources, merging the
+authors lists on their "id" and reporting any conflicting values.
+
+~~~
+    jsonjoin -deep -arrays key -array-key id -conflicts \
+        crossref.json datacite.json local.json >record.json
+~~~
+
 
 
 
@@ -122,8 +168,18 @@ as replacement values.
 	update     bool
 	overwrite  bool
 	createRoot bool
+	deep       bool
+	arrays     string
+	arrayKey   string
+	conflicts  bool
 )
 
+// conflictReport is a merge conflict written to standard error
+type conflictReport struct {
+	Source string `json:"source"`
+	*datatools.JSONMergeConflict
+}
+
 func main() {
 	appName := path.Base(os.Args[0])
 	version := datatools.Version
@@ -148,6 +204,10 @@ func main() {
 	flag.BoolVar(&createRoot, "create", false, "for each object joined each under their own attribute.")
 	flag.BoolVar(&update, "update", false, "copy new key/values pairs into root object")
 	flag.BoolVar(&overwrite, "overwrite", false, "copy all key/values into root object")
+	flag.BoolVar(&deep, "deep", false, "merge nested objects attribute by attribute")
+	flag.StringVar(&arrays, "arrays", "", "how -deep merges arrays, replace, concat, union, index or key")
+	flag.StringVar(&arrayKey, "array-key", "", "the identity attribute of array elements merged with -arrays key")
+	flag.BoolVar(&conflicts, "conflicts", false, "write the conflicts found by -deep to standard error as JSON lines")
 
 	// Parse env amd options
 	flag.Parse()
@@ -185,11 +245,39 @@ func main() {
 		}
 	}
 
+	if arrays == "" && arrayKey != "" {
+		arrays = datatools.MergeKey
+	}
+	source := ""
+	merger := &datatools.JSONMerger{
+		Arrays:    arrays,
+		ArrayKey:  arrayKey,
+		KeepFirst: update,
+	}
+	if err := merger.Check(); err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	if conflicts {
+		merger.OnConflict = func(conflict *datatools.JSONMergeConflict) {
+			src, err := datatools.JSONMarshal(&conflictReport{Source: source, JSONMergeConflict: conflict})
+			if err == nil {
+				fmt.Fprintf(eout, "%s\n", src)
+			}
+		}
+	}
+
 	outObject := map[string]interface{}{}
 	newObject := map[string]interface{}{}
 
 	for _, arg := range args {
 		var src []byte
+		// Each object is decoded fresh so objects joined don't share a map
+		newObject = map[string]interface{}{}
+		source = arg
+		if source == "" {
+			source = "-"
+		}
 		if arg != "" && arg != "-" {
 			src, err = ioutil.ReadFile(arg)
 			if err != nil {
@@ -205,6 +293,18 @@ func main() {
 			os.Exit(1)
 		}
 		switch {
+		case deep && createRoot:
+			key := strings.TrimSuffix(path.Base(arg), ".json")
+			if key == "" || key == "-" {
+				key = "_"
+			}
+			if existing, conflict := outObject[key]; conflict {
+				outObject[key] = merger.Merge(existing, newObject)
+			} else {
+				outObject[key] = newObject
+			}
+		case deep == true:
+			outObject, _ = merger.Merge(outObject, newObject).(map[string]interface{})
 		case createRoot == true:
 			// Take the filename, use as a property name and add it to the out object.
 			key := strings.TrimSuffix(path.Base(arg), ".json")
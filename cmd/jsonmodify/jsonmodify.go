@@ -0,0 +1,268 @@
+// jsonmodify - is a command line that applies a sequence of create, update,
+// insert, delete and transform operations to the dotpaths of a JSON document.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+	"github.com/caltechlibrary/datatools/jsonmodify"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] OPERATION [OPERATION ...]
+
+# DESCRIPTION
+
+{app_name} reads a JSON document, applies a sequence of operations to
+values found by dotpath and writes the modified document. Operations are
+applied in the order given, if one fails nothing is written and
+{app_name} exits with an error.
+
+Operations are written as prefix expressions,
+
+(create DOTPATH VALUE)
+: add a value, it is an error if the value already exists
+
+(update DOTPATH VALUE)
+: replace a value, it is an error if the value does not exist
+
+(set DOTPATH VALUE)
+: create or replace a value
+
+(insert DOTPATH VALUE)
+: insert a value into an array before the index ending DOTPATH
+
+(append DOTPATH VALUE)
+: add a value to the end of an array, creating the array if needed
+
+(delete DOTPATH)
+: remove a value, later array elements move down
+
+(join DOTPATH SEPARATOR)
+: replace an array with its elements joined into a string
+
+(split DOTPATH SEPARATOR)
+: replace a string with an array of its parts
+
+Objects (or arrays when the next step is a number) missing along the
+DOTPATH are created by create, set, insert and append.
+
+A VALUE is a double quoted string, a number, true, false, null, a JSON
+object or array, a DOTPATH (copying the value found there) or one of
+
+(join VALUE SEPARATOR)
+: join the elements of an array into a string
+
+(split VALUE SEPARATOR)
+: split a string into an array
+
+(concat VALUE [VALUE ...] SEPARATOR)
+: join strings and numbers into a string
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-f, -file
+: read operations from a file
+
+-i, -input
+: input filename
+
+-o, -output
+: output filename
+
+-p, -pretty
+: pretty print JSON output
+
+# EXAMPLES
+
+Combine the family and given names of a person into a single name.
+
+~~~
+    echo '{"family": "Doe", "given": "Jane"}' | \
+      {app_name} '(create .name (concat .family .given ", "))' \
+        '(delete .family)' '(delete .given)'
+~~~
+
+Would yield
+
+~~~
+    {"name":"Doe, Jane"}
+~~~
+
+Add a keyword to the start of a list and record a version.
+
+~~~
+    {app_name} -i codemeta.json -p \
+      '(insert .keywords[0] "csv")' '(set .version "1.2.0")'
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	inputFName  string
+	outputFName string
+
+	// App Options
+	opsFName    string
+	prettyPrint bool
+)
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&inputFName, "input", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.StringVar(&opsFName, "f", "", "read operations from a file")
+	flag.StringVar(&opsFName, "file", "", "read operations from a file")
+	flag.BoolVar(&prettyPrint, "p", false, "pretty print JSON output")
+	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print JSON output")
+
+	// Parse env and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	// Operations from a file run before those on the command line
+	if opsFName != "" {
+		src, err := ioutil.ReadFile(opsFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		args = append([]string{string(src)}, args...)
+	}
+	if len(args) == 0 {
+		fmt.Fprintf(eout, "Missing operations, see %s -help\n", appName)
+		os.Exit(1)
+	}
+	m, err := jsonmodify.Parse(strings.Join(args, "\n"))
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+	var data interface{}
+	if err := datatools.JSONUnmarshal(src, &data); err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+	data, err = m.Apply(data)
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	eol := "\n"
+	if prettyPrint {
+		// JSONMarshalIndent ends with a newline already
+		src, err = datatools.JSONMarshalIndent(data, "", "    ")
+		eol = ""
+	} else {
+		src, err = datatools.JSONMarshal(data)
+	}
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(out, "%s%s", src, eol)
+}
@@ -0,0 +1,282 @@
+// jsonpatch - is a command line that applies a JSON Patch (RFC 6902) or a
+// JSON Merge Patch (RFC 7396) to a JSON document.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+)
+
+var (
+	helpText = `%{app_name}(1) user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] PATCH_FILE
+
+# DESCRIPTION
+
+{app_name} reads a JSON document and applies the patch in PATCH_FILE
+to it, writing the patched document. By default PATCH_FILE holds a
+JSON Patch (RFC 6902), an array of "add", "remove", "replace", "move",
+"copy" and "test" operations whose paths are JSON Pointers (RFC 6901).
+The operations are applied in order, if any fails nothing is written
+and {app_name} exits with an error naming the operation.
+
+With the -merge option PATCH_FILE holds a JSON Merge Patch (RFC 7396),
+an object whose members replace those of the document. Nested objects
+are merged, members set to null are removed and arrays are replaced
+whole.
+
+The -dry-run option checks the patch without writing the document. Each
+operation which would fail is written as a line of JSON giving its
+index (counting from zero), op, path and a message. Operations are
+checked in order, later operations see the changes of those before them
+which succeeded. {app_name} exits with an error if any would fail.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-dry-run
+: report the operations which would fail instead of patching
+
+-i, -input
+: input filename
+
+-merge
+: PATCH_FILE is a JSON Merge Patch
+
+-o, -output
+: output filename
+
+-p, -pretty
+: pretty print JSON output
+
+# EXAMPLES
+
+Correct the version and add a keyword to a codemeta.json file using
+the patch in fix-version.json
+
+~~~
+    [
+        {"op": "test", "path": "/version", "value": "1.2.0"},
+        {"op": "replace", "path": "/version", "value": "1.2.1"},
+        {"op": "add", "path": "/keywords/-", "value": "csv"}
+    ]
+~~~
+
+~~~
+    {app_name} -i codemeta.json -p fix-version.json
+~~~
+
+Check which operations would fail
+
+~~~
+    {app_name} -i codemeta.json -dry-run fix-version.json
+~~~
+
+If the version were already "1.2.1" this would report
+
+~~~
+    {"index":0,"op":"test","path":"/version","message":"test failed, value is not equal"}
+~~~
+
+Remove the funder and set the license with a merge patch
+
+~~~
+    echo '{"funder": null, "license": "BSD-3-Clause"}' >license.json
+    {app_name} -i codemeta.json -merge license.json
+~~~
+
+{app_name} {version}
+
+`
+
+	// Standard Options
+	showHelp    bool
+	showLicense bool
+	showVersion bool
+	inputFName  string
+	outputFName string
+
+	// App Options
+	mergePatch  bool
+	dryRun      bool
+	prettyPrint bool
+)
+
+func main() {
+	appName := path.Base(os.Args[0])
+	version := datatools.Version
+	license := datatools.LicenseText
+	releaseDate := datatools.ReleaseDate
+	releaseHash := datatools.ReleaseHash
+
+	// Standard Options
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&inputFName, "input", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.StringVar(&outputFName, "output", "", "output filename")
+
+	// App Options
+	flag.BoolVar(&mergePatch, "merge", false, "PATCH_FILE is a JSON Merge Patch")
+	flag.BoolVar(&dryRun, "dry-run", false, "report the operations which would fail instead of patching")
+	flag.BoolVar(&prettyPrint, "p", false, "pretty print JSON output")
+	flag.BoolVar(&prettyPrint, "pretty", false, "pretty print JSON output")
+
+	// Parse env and options
+	flag.Parse()
+	args := flag.Args()
+
+	// Setup IO
+	var err error
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if inputFName != "" && inputFName != "-" {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	if outputFName != "" && outputFName != "-" {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	// Process options
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", datatools.FmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", license)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "datatools, %s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+
+	if len(args) != 1 {
+		fmt.Fprintf(eout, "Expected a PATCH_FILE, see %s -help\n", appName)
+		os.Exit(1)
+	}
+	patchFName := args[0]
+	patchSrc, err := ioutil.ReadFile(patchFName)
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+	var doc interface{}
+	if err := datatools.JSONUnmarshal(src, &doc); err != nil {
+		fmt.Fprintf(eout, "%s, %s\n", inputFName, err)
+		os.Exit(1)
+	}
+
+	if mergePatch {
+		var patch interface{}
+		if err := datatools.JSONUnmarshal(patchSrc, &patch); err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", patchFName, err)
+			os.Exit(1)
+		}
+		// A merge patch can always be applied
+		if dryRun {
+			os.Exit(0)
+		}
+		doc = datatools.ApplyMergePatch(doc, patch)
+	} else {
+		patch, err := datatools.ParseJSONPatch(patchSrc)
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", patchFName, err)
+			os.Exit(1)
+		}
+		if dryRun {
+			errs := datatools.CheckJSONPatch(doc, patch)
+			for _, patchErr := range errs {
+				src, err := datatools.JSONMarshal(patchErr)
+				if err != nil {
+					fmt.Fprintln(eout, err)
+					os.Exit(1)
+				}
+				fmt.Fprintf(out, "%s\n", src)
+			}
+			if len(errs) > 0 {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		doc, err = datatools.ApplyJSONPatch(doc, patch)
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", patchFName, err)
+			os.Exit(1)
+		}
+	}
+
+	eol := "\n"
+	if prettyPrint {
+		// JSONMarshalIndent ends with a newline already
+		src, err = datatools.JSONMarshalIndent(doc, "", "    ")
+		eol = ""
+	} else {
+		src, err = datatools.JSONMarshal(doc)
+	}
+	if err != nil {
+		fmt.Fprintln(eout, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(out, "%s%s", src, eol)
+}
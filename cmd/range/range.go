@@ -45,6 +45,8 @@ var (
 
 {app_name} [OPTIONS] START_INTEGER END_INTEGER [INCREMENT_INTEGER]
 
+{app_name} [OPTIONS] RANGE_EXPRESSION
+
 # DESCRIPTION
 
 {app_name} is a simple utility for shell scripts that emits a list of 
@@ -55,6 +57,13 @@ subset of functionality found in the Unix seq command.
 If the first argument is greater than the last then it counts 
 down otherwise it counts up.
 
+Given a single argument it is read as a range expression, the same
+one used by csvrows and csvcols. A range expression is a comma
+separated list of integers and ranges like 2:10 (two through ten),
+1:100:5 (every fifth from one through one hundred), 10:1:-1 (ten down
+to one). Ranges counting back from the end (e.g. -3: the last three)
+or left open (e.g. 2: or all) need the -length option.
+
 # OPTIONS
 
 -help
@@ -72,6 +81,9 @@ down otherwise it counts up.
 -inc, -increment
 : The non-zero integer increment value.
 
+-length
+: The length used to resolve open ended and negative ranges in a range expression.
+
 -nl, -newline
 : if true add a trailing newline
 
@@ -120,6 +132,22 @@ Create a descending range of integers ten down to one
 Yields 10 9 8 7 6 5 4 3 2 1
 
 
+Every third integer from one to ten then twenty
+
+~~~
+	{app_name} 1:10:3,20
+~~~
+
+Yields 1 4 7 10 20
+
+The last three integers of one through ten
+
+~~~
+	{app_name} -length 10 -- -3:
+~~~
+
+Yields 8 9 10
+
 Pick a random integer between zero and ten
 
 ~~~
@@ -148,6 +176,7 @@ Yields a random integer from 0 to 10
 	end           int
 	increment     int
 	randomElement bool
+	length        int
 )
 
 
@@ -168,6 +197,28 @@ func inRange(i, start, end int) bool {
 	return false
 }
 
+// writeRange writes the values or a random value from them if
+// randomElement is set
+func writeRange(out io.Writer, values []int) {
+	// if randomElement we should an array we can pick the elements from
+	if randomElement == true {
+		if len(values) == 0 {
+			return
+		}
+		rand.Seed(time.Now().Unix())
+		ith := rand.Intn(len(values))
+		fmt.Fprintf(out, "%d%s", values[ith], eol)
+		return
+	}
+	for i, val := range values {
+		if i == 0 {
+			fmt.Fprintf(out, "%d%s", val, eol)
+		} else {
+			fmt.Fprintf(out, " %d%s", val, eol)
+		}
+	}
+}
+
 func main() {
 	const (
 		startUsage = "The starting integer."
@@ -198,6 +249,7 @@ func main() {
 	flag.IntVar(&increment, "inc", 1, incUsage)
 	flag.IntVar(&increment, "increment", 1, incUsage)
 	flag.BoolVar(&randomElement, "random", false, "Pick a range value from range")
+	flag.IntVar(&length, "length", 0, "The length used to resolve open ended and negative ranges in a range expression.")
 
 	// Parse env and options
 	flag.Parse()
@@ -237,6 +289,15 @@ func main() {
 
 	argc := len(args)
 
+	if argc == 1 {
+		expr, err := datatools.ParseRangeExpr(args[0])
+		assertOk(eout, err, "Not a range expression.")
+		if !expr.Bounded() && length <= 0 {
+			assertOk(eout, fmt.Errorf("%q", args[0]), "Range expression needs a -length.")
+		}
+		writeRange(out, expr.Resolve(length))
+		os.Exit(0)
+	}
 	if argc < 2 {
 		fmt.Fprintln(eout, "Must include start and end integers.")
 		os.Exit(1)
@@ -269,28 +330,10 @@ func main() {
 		increment = increment * -1
 	}
 
-	// If randonElement than generate range and pick the ith random element from range
-	var (
-		ithArray []int
-		ith      = 0
-	)
-
 	// Now count up or down as appropriate.
+	values := []int{}
 	for i := start; inRange(i, start, end) == true; i = i + increment {
-		if randomElement == true {
-			ithArray = append(ithArray, i)
-		} else {
-			if i == start {
-				fmt.Fprintf(out, "%d%s", i, eol)
-			} else {
-				fmt.Fprintf(out, " %d%s", i, eol)
-			}
-		}
-	}
-	// if randomElement we should an array we can pick the elements from
-	if randomElement == true {
-		rand.Seed(time.Now().Unix())
-		ith = rand.Intn(len(ithArray))
-		fmt.Fprintf(out, "%d%s", ithArray[ith], eol)
+		values = append(values, i)
 	}
+	writeRange(out, values)
 }
@@ -1,11 +1,14 @@
 package codemeta
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
 	// Caltech Library package
+	"github.com/caltechlibrary/datatools/jsonmodify"
 	"github.com/caltechlibrary/doitools"
 
 	// 3rd Party Package
@@ -172,19 +175,86 @@ date-released: %s`, dt))...)
 	return src, nil
 }
 
-// Show returns a the value as a string for the json path described by
-// dataPaths. If dataPath is "." return the whole object as a string.
-// if the dataPath is not valid return an empty string.
+// modify applies fn to a generic copy of cm then decodes the result
+// back into cm. Decoding fails if fn added an attribute Codemeta has no
+// field for, rather than silently dropping it. cm is unchanged if fn or
+// decoding fails.
+func (cm *Codemeta) modify(fn func(data interface{}) (interface{}, error)) error {
+	src, err := JSONMarshal(cm)
+	if err != nil {
+		return err
+	}
+	var data interface{}
+	if err := JSONUnmarshal(src, &data); err != nil {
+		return err
+	}
+	if data, err = fn(data); err != nil {
+		return err
+	}
+	if src, err = JSONMarshal(data); err != nil {
+		return err
+	}
+	obj := new(Codemeta)
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(obj); err != nil {
+		return err
+	}
+	*cm = *obj
+	return nil
+}
+
+// Show returns the value as a string for the json path described by
+// dataPath, e.g. ".name" or ".author[0].familyName". Paths start with
+// a "." and "." returns the whole object. An error is returned if the
+// path is not valid or holds no value.
 func (cm *Codemeta) Show(dataPath string) (string, error) {
-	return "", fmt.Errorf("Show() not implemented.")
+	src, err := JSONMarshal(cm)
+	if err != nil {
+		return "", err
+	}
+	var data interface{}
+	if err := JSONUnmarshal(src, &data); err != nil {
+		return "", err
+	}
+	val, err := jsonmodify.Get(data, dataPath)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := val.(string); ok {
+		return s, nil
+	}
+	src, err = JSONMarshalIndent(val, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(src)), nil
 }
 
 // Set sets the value in the codemeta.json file for a provided json path.
+// A value holding JSON (e.g. an object for an author) is decoded first,
+// otherwise it is set as a string. An error is returned if the path
+// names an attribute codemeta.json doesn't have (e.g. ".nmae").
 func (cm *Codemeta) Set(dataPath string, value string) error {
-	return fmt.Errorf("Set() not implemented.")
+	var val interface{}
+	if json.Valid([]byte(value)) && JSONUnmarshal([]byte(value), &val) == nil {
+		err := cm.modify(func(data interface{}) (interface{}, error) {
+			return jsonmodify.Set(data, dataPath, val)
+		})
+		if err == nil {
+			return nil
+		}
+	}
+	// Values like 1.0 for a version are valid JSON but hold a string
+	return cm.modify(func(data interface{}) (interface{}, error) {
+		return jsonmodify.Set(data, dataPath, value)
+	})
 }
 
 // Delete removes the value in the codemeta.json file for a provided json path.
 func (cm *Codemeta) Delete(dataPath string) error {
-	return fmt.Errorf("Delete() not implemented.")
+	return cm.modify(func(data interface{}) (interface{}, error) {
+		return jsonmodify.Delete(data, dataPath)
+	})
 }
@@ -1,6 +1,7 @@
 package codemeta
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -25,14 +26,95 @@ func TestCodemeta(t *testing.T) {
 	t.Errorf("TestCodemeta() not implemented.")
 }
 
+func testCodemeta() *Codemeta {
+	return &Codemeta{
+		Context: "https://doi.org/10.5063/schema/codemeta-2.0",
+		Type:    "SoftwareSourceCode",
+		Name:    "datatools",
+		Version: "1.2.0",
+		Author: []*PersonOrOrganization{
+			&PersonOrOrganization{
+				Id:         "https://orcid.org/0000-0003-0900-6903",
+				Type:       "Person",
+				GivenName:  "Robert",
+				FamilyName: "Doiel",
+			},
+		},
+		Keywords: []string{"csv", "json"},
+	}
+}
+
 func TestCodemetaShow(t *testing.T) {
-	t.Errorf("TestCodemetaShow() not implemented.")
+	cm := testCodemeta()
+	tests := map[string]string{
+		".name":                 "datatools",
+		".author[0].familyName": "Doiel",
+		".keywords":             "[\n\t\"csv\",\n\t\"json\"\n]",
+	}
+	for dataPath, expected := range tests {
+		got, err := cm.Show(dataPath)
+		if err != nil {
+			t.Errorf("Show(%q) failed, %s", dataPath, err)
+		} else if got != expected {
+			t.Errorf("Show(%q) expected %q, got %q", dataPath, expected, got)
+		}
+	}
+	if got, err := cm.Show("."); err != nil || !strings.Contains(got, `"name": "datatools"`) {
+		t.Errorf("Show(\".\") expected the whole object, got %q, %v", got, err)
+	}
+	if got, err := cm.Show(".funder.name"); err == nil {
+		t.Errorf("Show(\".funder.name\") expected an error, got %q", got)
+	}
 }
 
 func TestCodemetaSet(t *testing.T) {
-	t.Errorf("TestCodemetaSet() not implemented.")
+	cm := testCodemeta()
+	if err := cm.Set(".version", "1.3.0"); err != nil || cm.Version != "1.3.0" {
+		t.Errorf("expected version 1.3.0, got %q, %v", cm.Version, err)
+	}
+	if err := cm.Set(".version", "2.0"); err != nil || cm.Version != "2.0" {
+		t.Errorf("expected version 2.0, got %q, %v", cm.Version, err)
+	}
+	if err := cm.Set(".copyrightYear", "2023"); err != nil || cm.CopyrightYear != 2023 {
+		t.Errorf("expected copyrightYear 2023, got %d, %v", cm.CopyrightYear, err)
+	}
+	if err := cm.Set(".keywords[2]", "xlsx"); err != nil || len(cm.Keywords) != 3 || cm.Keywords[2] != "xlsx" {
+		t.Errorf("expected keywords to end with xlsx, got %+v, %v", cm.Keywords, err)
+	}
+	if err := cm.Set(".funder", `{"@type": "Organization", "name": "Caltech Library"}`); err != nil || cm.Funder == nil || cm.Funder.Name != "Caltech Library" {
+		t.Errorf("expected funder Caltech Library, got %+v, %v", cm.Funder, err)
+	}
+	if err := cm.Set(".author[0].familyName", "Doe"); err != nil || cm.Author[0].FamilyName != "Doe" {
+		t.Errorf("expected author family name Doe, %v", err)
+	}
+	if err := cm.Set(".author", `{"name": "not a list"}`); err == nil {
+		t.Errorf("expected an error setting author to an object")
+	}
+	if len(cm.Author) != 1 || cm.Author[0].GivenName != "Robert" {
+		t.Errorf("expected a failed Set to leave author unchanged, got %+v", cm.Author)
+	}
+	for _, dataPath := range []string{".nmae", ".author[0].nmae"} {
+		if err := cm.Set(dataPath, "y"); err == nil {
+			t.Errorf("expected an error setting %s, codemeta has no such attribute", dataPath)
+		}
+	}
+	if cm.Name != "datatools" || cm.Author[0].FamilyName != "Doe" {
+		t.Errorf("expected a failed Set to leave cm unchanged, got %q, %+v", cm.Name, cm.Author[0])
+	}
 }
 
 func TestCodemetaDelete(t *testing.T) {
-	t.Errorf("TestCodemetaDelete() not implemented.")
+	cm := testCodemeta()
+	if err := cm.Delete(".keywords[0]"); err != nil || len(cm.Keywords) != 1 || cm.Keywords[0] != "json" {
+		t.Errorf("expected keywords [json], got %+v, %v", cm.Keywords, err)
+	}
+	if err := cm.Delete(".version"); err != nil || cm.Version != "" {
+		t.Errorf("expected version to be removed, got %q, %v", cm.Version, err)
+	}
+	if err := cm.Delete(".author[0].givenName"); err != nil || cm.Author[0].GivenName != "" {
+		t.Errorf("expected given name to be removed, got %+v, %v", cm.Author[0], err)
+	}
+	if err := cm.Delete(".funder"); err == nil {
+		t.Errorf("expected an error deleting a missing funder")
+	}
 }
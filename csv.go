@@ -23,6 +23,7 @@ import (
 	"io"
 	"math/rand"
 	"sort"
+	"strings"
 	"time"
 
 	// 3rd Party Libraries
@@ -175,6 +176,84 @@ func CSVRows(in io.Reader, out io.Writer, showHeader bool, rowNos []int, delimit
 	return nil
 }
 
+// RowFilter selects rows of CSV data by their values, e.g. an
+// expression from the filter package. SetHeader is given the first row.
+type RowFilter interface {
+	SetHeader(header []string) error
+	Match(row []string) (bool, error)
+}
+
+// CSVRowsRange renders the rows selected by a range expression (see
+// ParseRangeExpr) using the delimiter to out. Rows are streamed, only
+// the tail of the input is buffered when the expression counts back
+// from the last row. If where is not nil the first row is the header
+// and the other rows must also match where.
+func CSVRowsRange(in io.Reader, out io.Writer, showHeader bool, expr RangeExpr, where RowFilter, delimiter string, lazyQuotes, trimLeadingSpace bool) error {
+	r := csv.NewReader(in)
+	r.LazyQuotes = lazyQuotes
+	r.TrimLeadingSpace = trimLeadingSpace
+
+	w := csv.NewWriter(out)
+	w.UseCRLF = UseCRLF()
+	if delimiter != "" {
+		r.Comma = NormalizeDelimiterRune(delimiter)
+		w.Comma = NormalizeDelimiterRune(delimiter)
+	}
+	writeRows := func(rows []interface{}) error {
+		for _, row := range rows {
+			// The header row and rows not matching where are held as nil
+			if rec, ok := row.([]string); ok && rec != nil {
+				if err := w.Write(rec); err != nil {
+					return fmt.Errorf("Error writing record to csv: %s (Row %T %+v)", err, rec, rec)
+				}
+			}
+		}
+		return nil
+	}
+	selector := NewRangeSelector(expr)
+	for i := 0; ; i++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s (%T %+v)", err, rec, rec)
+		}
+		if i == 0 && where != nil {
+			if err := where.SetHeader(rec); err != nil {
+				return err
+			}
+		}
+		if i == 0 && (showHeader || where != nil) {
+			if showHeader {
+				if err = w.Write(rec); err != nil {
+					return fmt.Errorf("Error writing record to csv: %s (Row %T %+v)", err, rec, rec)
+				}
+			}
+			rec = nil
+		} else if where != nil {
+			ok, err := where.Match(rec)
+			if err != nil {
+				return fmt.Errorf("row %d, %s", i+1, err)
+			}
+			if !ok {
+				rec = nil
+			}
+		}
+		if err := writeRows(selector.Push(rec)); err != nil {
+			return err
+		}
+	}
+	if err := writeRows(selector.Flush()); err != nil {
+		return err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("%s\n", err)
+	}
+	return nil
+}
+
 // CSVRowsAll renders the all rows in rowNos using the delimiter to out
 func CSVRowsAll(in io.Reader, out io.Writer, showHeader bool, delimiter string, lazyQuotes bool, trimLeadingSpace bool) error {
 	var err error
@@ -216,6 +295,47 @@ func CSVRowsAll(in io.Reader, out io.Writer, showHeader bool, delimiter string,
 	return nil
 }
 
+// markdownTableRow writes a row of a Github Flavored Markdown table,
+// if isHeader is true the separator row is written after it.
+func markdownTableRow(out io.Writer, record []string, isHeader bool) {
+	fmt.Fprintf(out, "| %s |%s", strings.Join(record, " | "), "\n")
+	if isHeader {
+		headerRow := []string{}
+		for _, rec := range record {
+			if len(rec) < 3 {
+				headerRow = append(headerRow, "---")
+			} else {
+				headerRow = append(headerRow, strings.Repeat("-", len(rec)))
+			}
+		}
+		fmt.Fprintf(out, "| %s |%s", strings.Join(headerRow, " | "), "\n")
+	}
+}
+
+// CSVToMarkdownTable reads CSV content from r and writes it to out as a
+// Github Flavored Markdown table. The first row is used as the table header.
+func CSVToMarkdownTable(r *csv.Reader, out io.Writer) error {
+	for i := 0; ; i++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		markdownTableRow(out, record, i == 0)
+	}
+	return nil
+}
+
+// WriteMarkdownTable writes rows to out as a Github Flavored Markdown
+// table. The first row is used as the table header.
+func WriteMarkdownTable(out io.Writer, rows [][]string) {
+	for i, record := range rows {
+		markdownTableRow(out, record, i == 0)
+	}
+}
+
 // JSONObjectsToCSV takes an JSON array of objects mapping to CSV colum/rows. This works a little
 // like Python csv.DictWriter. In Go a `map[string]interface{}{}` is used to represent the object.
 // If the value is complex then it is rendered as YAML into the cell.
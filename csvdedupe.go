@@ -0,0 +1,103 @@
+// csvdedupe.go provides the grouping of rows with matching keys used to
+// find duplicates in a CSV file.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"strings"
+)
+
+// findCluster returns the root of i's cluster compressing the path
+func findCluster(parent []int, i int) int {
+	for parent[i] != i {
+		parent[i] = parent[parent[i]]
+		i = parent[i]
+	}
+	return i
+}
+
+// ClusterKeys groups rows by their keys returning a cluster number for
+// each row, rows whose keys match share a number. Clusters are numbered
+// from one in the order of their first row. A key containing an empty
+// cell never matches another key.
+//
+// If match is nil keys match when they are equal, otherwise match is
+// called to compare keys and clusters are formed from the chains of
+// matching keys (if a matches b and b matches c all three are in one
+// cluster). If blocker is not nil it holds the first cell of each key,
+// indexed by row number, and only its candidates are compared.
+func ClusterKeys(keys [][]string, match func(a []string, b []string) bool, blocker *Blocker) []int {
+	parent := make([]int, len(keys))
+	for i := range parent {
+		parent[i] = i
+	}
+	usable := func(key []string) bool {
+		for _, cell := range key {
+			if cell == "" {
+				return false
+			}
+		}
+		return len(key) > 0
+	}
+	if match == nil {
+		seen := map[string]int{}
+		for i, key := range keys {
+			if !usable(key) {
+				continue
+			}
+			k := strings.Join(key, "\x1f")
+			if j, ok := seen[k]; ok {
+				parent[i] = j
+			} else {
+				seen[k] = i
+			}
+		}
+	} else {
+		for i, key := range keys {
+			if !usable(key) {
+				continue
+			}
+			compare := func(j int) {
+				if j < i && usable(keys[j]) && findCluster(parent, i) != findCluster(parent, j) && match(keys[j], key) {
+					// The earlier row becomes the root so roots are first rows
+					ri, rj := findCluster(parent, i), findCluster(parent, j)
+					if ri < rj {
+						parent[rj] = ri
+					} else {
+						parent[ri] = rj
+					}
+				}
+			}
+			if blocker != nil {
+				for _, j := range blocker.Candidates(key[0]) {
+					compare(j)
+				}
+			} else {
+				for j := 0; j < i; j++ {
+					compare(j)
+				}
+			}
+		}
+	}
+	clusters, numbers := make([]int, len(keys)), map[int]int{}
+	for i := range keys {
+		root := findCluster(parent, i)
+		if _, ok := numbers[root]; !ok {
+			numbers[root] = len(numbers) + 1
+		}
+		clusters[i] = numbers[root]
+	}
+	return clusters
+}
@@ -0,0 +1,42 @@
+package datatools
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClusterKeys(t *testing.T) {
+	keys := [][]string{
+		{"the hobbit"},
+		{"dune"},
+		{"the hobbitt"},
+		{""},
+		{"the hobbit"},
+		{""},
+		{"dune messiah"},
+	}
+	clusters := ClusterKeys(keys, nil, nil)
+	if s := fmt.Sprintf("%v", clusters); s != "[1 2 3 4 1 5 6]" {
+		t.Errorf("exact clusters, got %s", s)
+	}
+
+	// Fuzzy matches are chained, "a" ~ "ab" ~ "abc"
+	match := func(a []string, b []string) bool {
+		return Levenshtein(a[0], b[0], 1, 1, 1, true) <= 1
+	}
+	expected := "[1 2 1 3 1 4 5]"
+	if s := fmt.Sprintf("%v", ClusterKeys(keys, match, nil)); s != expected {
+		t.Errorf("fuzzy clusters, expected %s, got %s", expected, s)
+	}
+	blocker := NewBlocker(DefaultQGram, 1)
+	for i, key := range keys {
+		blocker.Add(i, key[0])
+	}
+	if s := fmt.Sprintf("%v", ClusterKeys(keys, match, blocker)); s != expected {
+		t.Errorf("blocked fuzzy clusters, expected %s, got %s", expected, s)
+	}
+	chain := [][]string{{"abc"}, {"xyz"}, {"a"}, {"ab"}}
+	if s := fmt.Sprintf("%v", ClusterKeys(chain, match, nil)); s != "[1 2 1 1]" {
+		t.Errorf("chained clusters, got %s", s)
+	}
+}
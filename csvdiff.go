@@ -0,0 +1,378 @@
+// csvdiff.go provides a row level comparison of two CSV files keyed on
+// one or more columns.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// CSVAdded is the change type of a row only in the new CSV file
+	CSVAdded = "added"
+	// CSVRemoved is the change type of a row only in the old CSV file
+	CSVRemoved = "removed"
+	// CSVModified is the change type of a row whose cells changed
+	CSVModified = "modified"
+)
+
+// CSVCellChange is the old and new value of a changed cell, Index is
+// the position of the column in CSVDiffer.Columns.
+type CSVCellChange struct {
+	Index  int    `json:"-"`
+	Column string `json:"column"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// CSVRowChange describes a row added, removed or modified between two
+// CSV files. Old and New are aligned with CSVDiffer.Columns.
+type CSVRowChange struct {
+	Change string           `json:"change"`
+	Key    []string         `json:"key"`
+	Old    []string         `json:"old,omitempty"`
+	New    []string         `json:"new,omitempty"`
+	Cells  []*CSVCellChange `json:"cells,omitempty"`
+}
+
+// CSVDiffSummary counts the rows by change type
+type CSVDiffSummary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+}
+
+// CSVDiffer compares two CSV files row by row matching rows on a key.
+// When HeaderRow is true columns are matched by name so columns may
+// be added, removed or reordered between the files (a column only in
+// one file doesn't make a row modified). If both files are
+// sorted by key (e.g. with csvsort) set Sorted and they are compared
+// in a single pass without holding either in memory, otherwise the
+// old file is held in memory.
+type CSVDiffer struct {
+	// Key is a column selector (e.g. "id" or "1,3") for the key columns
+	Key string
+	// HeaderRow is true if the first row of each file is a header
+	HeaderRow bool
+	// Sorted is true if both files are sorted by the key columns
+	Sorted bool
+
+	// Columns holds the column names of the compared rows (the old
+	// file's columns followed by any new ones), KeyColumns the names
+	// of the key columns. They are set by Diff.
+	Columns    []string
+	KeyColumns []string
+
+	oldCols []int
+	newCols []int
+	oldKey  []int
+	newKey  []int
+}
+
+// diffSource reads the rows of one of the files being compared
+type diffSource struct {
+	name     string
+	r        *csv.Reader
+	first    []string
+	cols     []int
+	key      []int
+	sorted   bool
+	lastKey  []string
+	lineNo   int
+	finished bool
+}
+
+// next returns the next row, aligned to the differ's columns, and its
+// key. It returns nil at the end of the file.
+func (src *diffSource) next() ([]string, []string, error) {
+	var (
+		row []string
+		err error
+	)
+	if src.first != nil {
+		row, src.first = src.first, nil
+	} else if !src.finished {
+		row, err = src.r.Read()
+		if err == io.EOF {
+			src.finished = true
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s, %s", src.name, err)
+		}
+	} else {
+		return nil, nil, nil
+	}
+	src.lineNo++
+	aligned := make([]string, len(src.cols))
+	for i, col := range src.cols {
+		if col >= 0 && col < len(row) {
+			aligned[i] = row[col]
+		}
+	}
+	key := make([]string, len(src.key))
+	for i, col := range src.key {
+		if col < len(row) {
+			key[i] = row[col]
+		}
+	}
+	if src.sorted && src.lastKey != nil {
+		switch c := compareKeys(src.lastKey, key); {
+		case c == 0:
+			return nil, nil, fmt.Errorf("%s, duplicate key %q at row %d", src.name, strings.Join(key, ","), src.lineNo)
+		case c > 0:
+			return nil, nil, fmt.Errorf("%s, not sorted by key at row %d (%q follows %q)", src.name, src.lineNo, strings.Join(key, ","), strings.Join(src.lastKey, ","))
+		}
+	}
+	src.lastKey = key
+	return aligned, key, nil
+}
+
+// compareKeys compares two keys cell by cell
+func compareKeys(a []string, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// alignColumns works out the union of the old and new columns. Names
+// repeated in a header are matched by their occurrence.
+func (d *CSVDiffer) alignColumns(oldHeader []string, newHeader []string) {
+	d.Columns, d.oldCols, d.newCols = []string{}, []int{}, []int{}
+	if d.HeaderRow == false {
+		width := len(oldHeader)
+		if len(newHeader) > width {
+			width = len(newHeader)
+		}
+		for i := 0; i < width; i++ {
+			d.Columns = append(d.Columns, fmt.Sprintf("%d", i+1))
+			d.oldCols = append(d.oldCols, i)
+			d.newCols = append(d.newCols, i)
+		}
+		return
+	}
+	occurrence := func(header []string) map[string]int {
+		positions, seen := map[string]int{}, map[string]int{}
+		for i, name := range header {
+			positions[fmt.Sprintf("%s\x1f%d", name, seen[name])] = i
+			seen[name]++
+		}
+		return positions
+	}
+	newPositions := occurrence(newHeader)
+	seen, used := map[string]int{}, map[int]bool{}
+	for i, name := range oldHeader {
+		d.Columns = append(d.Columns, name)
+		d.oldCols = append(d.oldCols, i)
+		if j, ok := newPositions[fmt.Sprintf("%s\x1f%d", name, seen[name])]; ok {
+			d.newCols = append(d.newCols, j)
+			used[j] = true
+		} else {
+			d.newCols = append(d.newCols, -1)
+		}
+		seen[name]++
+	}
+	for j, name := range newHeader {
+		if !used[j] {
+			d.Columns = append(d.Columns, name)
+			d.oldCols = append(d.oldCols, -1)
+			d.newCols = append(d.newCols, j)
+		}
+	}
+}
+
+// compareRows returns the changed cells of two aligned rows, columns
+// only found in one of the files are not compared.
+func (d *CSVDiffer) compareRows(oldRow []string, newRow []string) []*CSVCellChange {
+	cells := []*CSVCellChange{}
+	for i, name := range d.Columns {
+		if d.oldCols[i] < 0 || d.newCols[i] < 0 {
+			continue
+		}
+		if oldRow[i] != newRow[i] {
+			cells = append(cells, &CSVCellChange{Index: i, Column: name, Old: oldRow[i], New: newRow[i]})
+		}
+	}
+	return cells
+}
+
+// Diff compares the rows of oldCSV and newCSV calling emit for each row
+// added, removed or modified. It returns a count of the rows by change
+// type. Unless Sorted is set changes are emitted in the order of the
+// new file followed by the removed rows in the order of the old file.
+func (d *CSVDiffer) Diff(oldCSV *csv.Reader, newCSV *csv.Reader, emit func(*CSVRowChange) error) (*CSVDiffSummary, error) {
+	if d.Key == "" {
+		return nil, fmt.Errorf("missing key column")
+	}
+	oldHeader, err := oldCSV.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("old, %s", err)
+	}
+	newHeader, err := newCSV.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("new, %s", err)
+	}
+	if d.oldKey, err = ParseColumns(d.Key, oldHeader); err != nil {
+		return nil, fmt.Errorf("old, %s", err)
+	}
+	if d.newKey, err = ParseColumns(d.Key, newHeader); err != nil {
+		return nil, fmt.Errorf("new, %s", err)
+	}
+	if len(d.oldKey) != len(d.newKey) {
+		return nil, fmt.Errorf("key %q selects %d columns in old and %d in new", d.Key, len(d.oldKey), len(d.newKey))
+	}
+	d.alignColumns(oldHeader, newHeader)
+	d.KeyColumns = []string{}
+	for _, col := range d.oldKey {
+		for i, oldCol := range d.oldCols {
+			if oldCol == col {
+				d.KeyColumns = append(d.KeyColumns, d.Columns[i])
+				break
+			}
+		}
+	}
+	oldSrc := &diffSource{name: "old", r: oldCSV, cols: d.oldCols, key: d.oldKey, sorted: d.Sorted}
+	newSrc := &diffSource{name: "new", r: newCSV, cols: d.newCols, key: d.newKey, sorted: d.Sorted}
+	if d.HeaderRow == false {
+		oldSrc.first, newSrc.first = oldHeader, newHeader
+	}
+	summary := new(CSVDiffSummary)
+	// compare emits the change (if any) between matching rows
+	compare := func(key []string, oldRow []string, newRow []string) error {
+		cells := d.compareRows(oldRow, newRow)
+		if len(cells) == 0 {
+			summary.Unchanged++
+			return nil
+		}
+		summary.Modified++
+		return emit(&CSVRowChange{Change: CSVModified, Key: key, Old: oldRow, New: newRow, Cells: cells})
+	}
+	if d.Sorted {
+		err = d.mergeSorted(oldSrc, newSrc, summary, compare, emit)
+	} else {
+		err = d.mergeIndexed(oldSrc, newSrc, summary, compare, emit)
+	}
+	return summary, err
+}
+
+// mergeSorted compares files sorted by key in a single pass
+func (d *CSVDiffer) mergeSorted(oldSrc *diffSource, newSrc *diffSource, summary *CSVDiffSummary, compare func([]string, []string, []string) error, emit func(*CSVRowChange) error) error {
+	oldRow, oldKey, err := oldSrc.next()
+	if err != nil {
+		return err
+	}
+	newRow, newKey, err := newSrc.next()
+	if err != nil {
+		return err
+	}
+	for oldRow != nil || newRow != nil {
+		c := 0
+		switch {
+		case newRow == nil:
+			c = -1
+		case oldRow == nil:
+			c = 1
+		default:
+			c = compareKeys(oldKey, newKey)
+		}
+		if c < 0 {
+			summary.Removed++
+			if err := emit(&CSVRowChange{Change: CSVRemoved, Key: oldKey, Old: oldRow}); err != nil {
+				return err
+			}
+		} else if c > 0 {
+			summary.Added++
+			if err := emit(&CSVRowChange{Change: CSVAdded, Key: newKey, New: newRow}); err != nil {
+				return err
+			}
+		} else if err := compare(newKey, oldRow, newRow); err != nil {
+			return err
+		}
+		if c <= 0 {
+			if oldRow, oldKey, err = oldSrc.next(); err != nil {
+				return err
+			}
+		}
+		if c >= 0 {
+			if newRow, newKey, err = newSrc.next(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeIndexed holds the old file in memory indexed by key and streams
+// the new file
+func (d *CSVDiffer) mergeIndexed(oldSrc *diffSource, newSrc *diffSource, summary *CSVDiffSummary, compare func([]string, []string, []string) error, emit func(*CSVRowChange) error) error {
+	oldRows, oldKeys, index := [][]string{}, [][]string{}, map[string]int{}
+	for {
+		row, key, err := oldSrc.next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			break
+		}
+		k := strings.Join(key, "\x1f")
+		if _, ok := index[k]; ok {
+			return fmt.Errorf("old, duplicate key %q at row %d", strings.Join(key, ","), oldSrc.lineNo)
+		}
+		index[k] = len(oldRows)
+		oldRows, oldKeys = append(oldRows, row), append(oldKeys, key)
+	}
+	matched, seen := make([]bool, len(oldRows)), map[string]bool{}
+	for {
+		row, key, err := newSrc.next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			break
+		}
+		k := strings.Join(key, "\x1f")
+		if seen[k] {
+			return fmt.Errorf("new, duplicate key %q at row %d", strings.Join(key, ","), newSrc.lineNo)
+		}
+		seen[k] = true
+		if i, ok := index[k]; ok {
+			matched[i] = true
+			if err := compare(key, oldRows[i], row); err != nil {
+				return err
+			}
+		} else {
+			summary.Added++
+			if err := emit(&CSVRowChange{Change: CSVAdded, Key: key, New: row}); err != nil {
+				return err
+			}
+		}
+	}
+	for i, row := range oldRows {
+		if !matched[i] {
+			summary.Removed++
+			if err := emit(&CSVRowChange{Change: CSVRemoved, Key: oldKeys[i], Old: row}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package datatools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCSVDiffer(t *testing.T) {
+	oldSrc := `id,title,price
+1,The Hobbit,9.50
+2,Canary Row,10
+3,Thud!,12.50
+`
+	newSrc := `price,id,title,year
+9.50,1,The Hobbit,1937
+12.99,3,Thud!,2005
+15,4,Dune,1965
+`
+	for _, sorted := range []bool{false, true} {
+		changes := []string{}
+		differ := &CSVDiffer{Key: "id", HeaderRow: true, Sorted: sorted}
+		summary, err := differ.Diff(csv.NewReader(strings.NewReader(oldSrc)), csv.NewReader(strings.NewReader(newSrc)), func(change *CSVRowChange) error {
+			cells := []string{}
+			for _, cell := range change.Cells {
+				cells = append(cells, fmt.Sprintf("%s:%s->%s", cell.Column, cell.Old, cell.New))
+			}
+			changes = append(changes, fmt.Sprintf("%s %s %s", change.Change, strings.Join(change.Key, ","), strings.Join(cells, " ")))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("sorted %t, %s", sorted, err)
+			continue
+		}
+		if strings.Join(differ.Columns, ",") != "id,title,price,year" {
+			t.Errorf("sorted %t, unexpected columns %v", sorted, differ.Columns)
+		}
+		if strings.Join(differ.KeyColumns, ",") != "id" {
+			t.Errorf("sorted %t, unexpected key columns %v", sorted, differ.KeyColumns)
+		}
+		if summary.Added != 1 || summary.Removed != 1 || summary.Modified != 1 || summary.Unchanged != 1 {
+			t.Errorf("sorted %t, unexpected summary %+v", sorted, summary)
+		}
+		expected := []string{
+			"modified 3 price:12.50->12.99",
+			"added 4 ",
+			"removed 2 ",
+		}
+		if sorted {
+			expected = []string{expected[2], expected[0], expected[1]}
+		}
+		if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("sorted %t, expected\n%s\ngot\n%s", sorted, strings.Join(expected, "\n"), strings.Join(changes, "\n"))
+		}
+	}
+
+	// Sorted input must be in key order without duplicates
+	unsorted := "id,title\n2,b\n1,a\n"
+	differ := &CSVDiffer{Key: "id", HeaderRow: true, Sorted: true}
+	if _, err := differ.Diff(csv.NewReader(strings.NewReader(unsorted)), csv.NewReader(strings.NewReader(unsorted)), func(*CSVRowChange) error { return nil }); err == nil {
+		t.Errorf("expected an error for unsorted input")
+	}
+	duplicate := "id,title\n1,a\n1,b\n"
+	differ = &CSVDiffer{Key: "id", HeaderRow: true}
+	if _, err := differ.Diff(csv.NewReader(strings.NewReader(duplicate)), csv.NewReader(strings.NewReader(unsorted)), func(*CSVRowChange) error { return nil }); err == nil {
+		t.Errorf("expected an error for a duplicate key")
+	}
+
+	// Without a header row columns are compared by position
+	differ = &CSVDiffer{Key: "1", HeaderRow: false}
+	summary, err := differ.Diff(csv.NewReader(strings.NewReader("1,a\n2,b\n")), csv.NewReader(strings.NewReader("1,a\n2,c\n")), func(*CSVRowChange) error { return nil })
+	if err != nil {
+		t.Error(err)
+	} else if summary.Modified != 1 || summary.Unchanged != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
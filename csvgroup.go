@@ -0,0 +1,395 @@
+// csvgroup.go provides group by and aggregation of CSV content, groups
+// are aggregated in memory with rows of new groups spilled to temporary
+// files when there are too many to hold.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// spillPartitions is the number of temporary files the rows of
+	// spilled groups are divided between
+	spillPartitions = 16
+
+	// maxSpillLevel limits how many times a partition can spill again
+	maxSpillLevel = 4
+)
+
+// CSVAggregate is an aggregation computed for each group of rows
+type CSVAggregate struct {
+	// Name of the column in the output
+	Name string
+	// Func is count, sum, avg, min, max, distinct or concat
+	Func string
+	// Column is the zero based column aggregated, -1 for count
+	Column int
+}
+
+// ParseAggregate parses an aggregate like "count", "sum:COLUMN" or
+// "total=sum:COLUMN". COLUMN is a column number (counting from one) or
+// a name from the header. Without a NAME= prefix the output column is
+// named FUNC_COLUMN (e.g. sum_Pages).
+func ParseAggregate(s string, header []string) (*CSVAggregate, error) {
+	agg := &CSVAggregate{Column: -1}
+	spec := strings.TrimSpace(s)
+	if i := strings.Index(spec, "="); i > 0 && !strings.Contains(spec[:i], ":") {
+		agg.Name, spec = strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+	}
+	fn, col, hasCol := strings.Cut(spec, ":")
+	agg.Func = strings.ToLower(strings.TrimSpace(fn))
+	switch agg.Func {
+	case "count":
+		if hasCol {
+			return nil, fmt.Errorf("%q, count does not take a column", s)
+		}
+		if agg.Name == "" {
+			agg.Name = "count"
+		}
+		return agg, nil
+	case "sum", "avg", "min", "max", "distinct", "concat":
+	default:
+		return nil, fmt.Errorf("%q, unknown aggregate %q", s, fn)
+	}
+	if strings.TrimSpace(col) == "" {
+		return nil, fmt.Errorf("%q, %s needs a column (e.g. %s:1)", s, agg.Func, agg.Func)
+	}
+	i, err := ParseColumn(col, header)
+	if err != nil {
+		return nil, err
+	}
+	agg.Column = i
+	if agg.Name == "" {
+		agg.Name = agg.Func + "_" + columnName(i, header)
+	}
+	return agg, nil
+}
+
+// columnName returns the header name of a column or its number
+func columnName(i int, header []string) string {
+	if i < len(header) && strings.TrimSpace(header[i]) != "" {
+		return header[i]
+	}
+	return strconv.Itoa(i + 1)
+}
+
+// aggState accumulates an aggregate for one group
+type aggState struct {
+	count          int
+	sum            float64
+	numbers        int
+	minStr, maxStr string
+	minNum, maxNum string
+	minF, maxF     float64
+	notNumeric     bool
+	distinct       map[string]bool
+	values         []string
+}
+
+// add accumulates a cell value, empty cells are ignored except by count
+func (state *aggState) add(agg *CSVAggregate, row []string) {
+	state.count++
+	if agg.Column < 0 || agg.Column >= len(row) {
+		return
+	}
+	val := strings.TrimSpace(row[agg.Column])
+	if val == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	isNumber := err == nil
+	switch agg.Func {
+	case "sum", "avg":
+		if isNumber {
+			state.sum += f
+			state.numbers++
+		}
+	case "min", "max":
+		if state.minStr == "" || val < state.minStr {
+			state.minStr = val
+		}
+		if state.maxStr == "" || val > state.maxStr {
+			state.maxStr = val
+		}
+		if !isNumber {
+			state.notNumeric = true
+		} else {
+			if state.numbers == 0 || f < state.minF {
+				state.minF, state.minNum = f, val
+			}
+			if state.numbers == 0 || f > state.maxF {
+				state.maxF, state.maxNum = f, val
+			}
+			state.numbers++
+		}
+	case "distinct":
+		if state.distinct == nil {
+			state.distinct = map[string]bool{}
+		}
+		state.distinct[val] = true
+	case "concat":
+		state.values = append(state.values, val)
+	case "first":
+		if len(state.values) == 0 {
+			state.values = []string{val}
+		}
+	case "", "last":
+		state.values = []string{val}
+	}
+}
+
+// result returns the aggregate value, min and max compare numbers
+// numerically when all the values are numbers
+func (state *aggState) result(agg *CSVAggregate, separator string) string {
+	switch agg.Func {
+	case "count":
+		return strconv.Itoa(state.count)
+	case "sum":
+		if state.numbers > 0 {
+			return strconv.FormatFloat(state.sum, 'f', -1, 64)
+		}
+	case "avg":
+		if state.numbers > 0 {
+			return strconv.FormatFloat(state.sum/float64(state.numbers), 'f', -1, 64)
+		}
+	case "min":
+		if !state.notNumeric {
+			return state.minNum
+		}
+		return state.minStr
+	case "max":
+		if !state.notNumeric {
+			return state.maxNum
+		}
+		return state.maxStr
+	case "distinct":
+		return strconv.Itoa(len(state.distinct))
+	case "concat":
+		return strings.Join(state.values, separator)
+	case "", "first", "last":
+		if len(state.values) > 0 {
+			return state.values[0]
+		}
+	}
+	return ""
+}
+
+// csvGroup holds the key and aggregates of a group
+type csvGroup struct {
+	key    []string
+	states []*aggState
+}
+
+// CSVGrouper groups CSV rows by the values of one or more columns and
+// writes a row for each group with its aggregates.
+type CSVGrouper struct {
+	// By holds column selectors (see ParseColumns) of the group key,
+	// if empty all rows are one group
+	By []string
+	// Aggregates holds the aggregates, see ParseAggregate. Defaults
+	// to count.
+	Aggregates []string
+	// HeaderRow, if true, allows columns to be referred to by name
+	HeaderRow bool
+	// Separator joins concat values, defaults to "; "
+	Separator string
+	// MaxGroups is the number of groups aggregated in memory, rows
+	// of new groups past this are spilled to temporary files and
+	// aggregated afterwards. Zero (or less) keeps all groups in memory.
+	MaxGroups int
+	// TmpDir is where rows are spilled, defaults to os.TempDir()
+	TmpDir string
+
+	by     []int
+	aggs   []*CSVAggregate
+	spills []string
+}
+
+// Group reads CSV content from r and writes a header and a row for
+// each group to w. Groups are written in the order they are first
+// seen, groups that were spilled follow those held in memory.
+func (grouper *CSVGrouper) Group(r *csv.Reader, w *csv.Writer) error {
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = false
+	defer grouper.removeSpills()
+
+	var header []string
+	if grouper.HeaderRow {
+		row, err := r.Read()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		header = row
+	}
+	grouper.by = []int{}
+	for _, expr := range grouper.By {
+		cols, err := ParseColumns(expr, header)
+		if err != nil {
+			return err
+		}
+		grouper.by = append(grouper.by, cols...)
+	}
+	grouper.aggs = []*CSVAggregate{}
+	aggregates := grouper.Aggregates
+	if len(aggregates) == 0 {
+		aggregates = []string{"count"}
+	}
+	for _, expr := range aggregates {
+		agg, err := ParseAggregate(expr, header)
+		if err != nil {
+			return err
+		}
+		grouper.aggs = append(grouper.aggs, agg)
+	}
+	if grouper.Separator == "" {
+		grouper.Separator = "; "
+	}
+	names := []string{}
+	for _, col := range grouper.by {
+		names = append(names, columnName(col, header))
+	}
+	for _, agg := range grouper.aggs {
+		names = append(names, agg.Name)
+	}
+	if err := w.Write(names); err != nil {
+		return err
+	}
+	if err := grouper.aggregate(r, w, 0); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// groupKey returns the cells of the group columns, missing cells are empty
+func (grouper *CSVGrouper) groupKey(row []string) []string {
+	key := make([]string, len(grouper.by))
+	for i, col := range grouper.by {
+		if col < len(row) {
+			key[i] = row[col]
+		}
+	}
+	return key
+}
+
+// partition picks the spill file for a key, the level varies the hash
+// so a partition that spills again is divided differently
+func partition(key string, level int) int {
+	h := fnv.New32a()
+	h.Write([]byte{byte(level)})
+	h.Write([]byte(key))
+	return int(h.Sum32() % spillPartitions)
+}
+
+// aggregate groups the rows read from r writing a row for each group
+// to w. Once MaxGroups are held the rows of groups not already held are
+// written to spill files by their key's hash so each group is either
+// aggregated in memory or entirely within one spill file. Each spill
+// file is then aggregated in turn.
+func (grouper *CSVGrouper) aggregate(r *csv.Reader, w *csv.Writer, level int) error {
+	groups := map[string]*csvGroup{}
+	order := []*csvGroup{}
+	var (
+		spillFiles   []*os.File
+		spillWriters []*csv.Writer
+	)
+	defer func() {
+		for _, fp := range spillFiles {
+			fp.Close()
+		}
+	}()
+	for lineNo := 1; ; lineNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("row %d, %s", lineNo, err)
+		}
+		key := grouper.groupKey(row)
+		id := strings.Join(key, "\x1f")
+		group, ok := groups[id]
+		if !ok {
+			if grouper.MaxGroups > 0 && len(order) >= grouper.MaxGroups && level < maxSpillLevel {
+				if spillWriters == nil {
+					for i := 0; i < spillPartitions; i++ {
+						fp, err := os.CreateTemp(grouper.TmpDir, "csvgroup-*.csv")
+						if err != nil {
+							return err
+						}
+						grouper.spills = append(grouper.spills, fp.Name())
+						spillFiles = append(spillFiles, fp)
+						spillWriters = append(spillWriters, csv.NewWriter(fp))
+					}
+				}
+				if err := spillWriters[partition(id, level)].Write(row); err != nil {
+					return err
+				}
+				continue
+			}
+			group = &csvGroup{key: key}
+			for range grouper.aggs {
+				group.states = append(group.states, &aggState{})
+			}
+			groups[id] = group
+			order = append(order, group)
+		}
+		for i, agg := range grouper.aggs {
+			group.states[i].add(agg, row)
+		}
+	}
+	for _, group := range order {
+		row := append([]string{}, group.key...)
+		for i, agg := range grouper.aggs {
+			row = append(row, group.states[i].result(agg, grouper.Separator))
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	// Release the groups before aggregating the spilled rows
+	groups, order = nil, nil
+	for i, fp := range spillFiles {
+		spillWriters[i].Flush()
+		if err := spillWriters[i].Error(); err != nil {
+			return err
+		}
+		if _, err := fp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		spill := csv.NewReader(fp)
+		spill.FieldsPerRecord = -1
+		if err := grouper.aggregate(spill, w, level+1); err != nil {
+			return err
+		}
+		fp.Close()
+		os.Remove(fp.Name())
+	}
+	return nil
+}
+
+func (grouper *CSVGrouper) removeSpills() {
+	for _, fName := range grouper.spills {
+		os.Remove(fName)
+	}
+	grouper.spills = nil
+}
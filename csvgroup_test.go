@@ -0,0 +1,108 @@
+package datatools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseAggregate(t *testing.T) {
+	header := []string{"collection", "year", "pages"}
+	tests := map[string]CSVAggregate{
+		"count":               {Name: "count", Func: "count", Column: -1},
+		"sum:pages":           {Name: "sum_pages", Func: "sum", Column: 2},
+		"AVG:3":               {Name: "avg_pages", Func: "avg", Column: 2},
+		"years=distinct:year": {Name: "years", Func: "distinct", Column: 1},
+	}
+	for src, expected := range tests {
+		agg, err := ParseAggregate(src, header)
+		if err != nil {
+			t.Errorf("expected (%s) no errors, got %s", src, err)
+			continue
+		}
+		if *agg != expected {
+			t.Errorf("expected (%s) %+v, got %+v", src, expected, agg)
+		}
+	}
+	for _, src := range []string{"median:pages", "sum", "sum:title", "count:1"} {
+		if agg, err := ParseAggregate(src, header); err == nil {
+			t.Errorf("expected (%s) an error, got %+v", src, agg)
+		}
+	}
+}
+
+func TestCSVGrouper(t *testing.T) {
+	src := `collection,year,pages,title
+books,2017,100,A
+books,2017,250,B
+maps,2017,,C
+books,2018,90,D
+maps,2018,2,E
+maps,2017,30,C
+theses,2018,x,F
+`
+	expected := [][]string{
+		{"books", "2017", "2", "350", "175", "100", "250", "2", "A; B"},
+		{"books", "2018", "1", "90", "90", "90", "90", "1", "D"},
+		{"maps", "2017", "2", "30", "30", "30", "30", "1", "C; C"},
+		{"maps", "2018", "1", "2", "2", "2", "2", "1", "E"},
+		{"theses", "2018", "1", "", "", "x", "x", "1", "F"},
+	}
+	for _, maxGroups := range []int{0, 1, 2} {
+		grouper := &CSVGrouper{
+			By:         []string{"collection,year"},
+			Aggregates: []string{"count", "sum:pages", "avg:pages", "min:pages", "max:pages", "distinct:title", "concat:title"},
+			HeaderRow:  true,
+			MaxGroups:  maxGroups,
+			TmpDir:     t.TempDir(),
+		}
+		buf := bytes.NewBuffer([]byte{})
+		if err := grouper.Group(csv.NewReader(strings.NewReader(src)), csv.NewWriter(buf)); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		rows, err := csv.NewReader(buf).ReadAll()
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if strings.Join(rows[0], ",") != "collection,year,count,sum_pages,avg_pages,min_pages,max_pages,distinct_title,concat_title" {
+			t.Errorf("unexpected header %+v", rows[0])
+		}
+		rows = rows[1:]
+		if maxGroups == 0 {
+			// Without spilling groups are in the order first seen
+			if rows[1][0] != "maps" || rows[2][0] != "books" {
+				t.Errorf("expected groups in the order first seen, got %+v", rows)
+			}
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return strings.Join(rows[i], ",") < strings.Join(rows[j], ",")
+		})
+		if len(rows) != len(expected) {
+			t.Errorf("(max groups %d) expected %d groups, got %+v", maxGroups, len(expected), rows)
+			continue
+		}
+		for i, row := range rows {
+			if strings.Join(row, ",") != strings.Join(expected[i], ",") {
+				t.Errorf("(max groups %d) expected %+v, got %+v", maxGroups, expected[i], row)
+			}
+		}
+		if len(grouper.spills) != 0 {
+			t.Errorf("expected spill files to be removed, %+v", grouper.spills)
+		}
+	}
+
+	// Without -by all the rows are one group
+	grouper := &CSVGrouper{HeaderRow: true}
+	buf := bytes.NewBuffer([]byte{})
+	if err := grouper.Group(csv.NewReader(strings.NewReader(src)), csv.NewWriter(buf)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if buf.String() != "count\n7\n" {
+		t.Errorf("expected a count of 7, got %q", buf.String())
+	}
+}
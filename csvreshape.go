@@ -0,0 +1,301 @@
+// csvreshape.go provides transposing, melting (wide to long) and
+// pivoting (long to wide) of CSV content.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVTranspose turns the columns of rows into rows, short rows are
+// padded with empty cells.
+func CSVTranspose(rows [][]string) [][]string {
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	result := make([][]string, width)
+	for i := range result {
+		result[i] = make([]string, len(rows))
+		for j, row := range rows {
+			if i < len(row) {
+				result[i][j] = row[i]
+			}
+		}
+	}
+	return result
+}
+
+// CSVMelter turns the columns of wide CSV content into key/value rows,
+// one for each melted column of each row.
+type CSVMelter struct {
+	// IDs are column selectors (see ParseColumns) of the columns kept
+	// on each output row
+	IDs string
+	// Columns are the column selectors melted into key/value rows,
+	// defaults to the columns not in IDs
+	Columns string
+	// HeaderRow, if true, uses the header names as keys otherwise
+	// the column numbers are used
+	HeaderRow bool
+	// KeyName and ValueName name the key and value columns of the
+	// output, default "key" and "value"
+	KeyName   string
+	ValueName string
+	// SkipEmpty leaves out rows for empty cells
+	SkipEmpty bool
+}
+
+// Melt reads CSV content from r writing key/value rows to w
+func (melter *CSVMelter) Melt(r *csv.Reader, w *csv.Writer) error {
+	r.FieldsPerRecord = -1
+	var header []string
+	if melter.HeaderRow {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		header = row
+	}
+	ids := []int{}
+	if melter.IDs != "" {
+		cols, err := ParseColumns(melter.IDs, header)
+		if err != nil {
+			return err
+		}
+		ids = cols
+	}
+	var (
+		columns []int
+		err     error
+	)
+	if melter.Columns != "" {
+		if columns, err = ParseColumns(melter.Columns, header); err != nil {
+			return err
+		}
+	}
+	keyName, valueName := melter.KeyName, melter.ValueName
+	if keyName == "" {
+		keyName = "key"
+	}
+	if valueName == "" {
+		valueName = "value"
+	}
+	names := []string{}
+	for _, col := range ids {
+		names = append(names, columnName(col, header))
+	}
+	if err := w.Write(append(names, keyName, valueName)); err != nil {
+		return err
+	}
+	for lineNo := 1; ; lineNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("row %d, %s", lineNo, err)
+		}
+		// Without a header the columns not in IDs depend on the row
+		melt := columns
+		if melter.Columns == "" {
+			melt = otherColumns(ids, max(len(header), len(row)))
+		}
+		idCells := []string{}
+		for _, col := range ids {
+			idCells = append(idCells, cellAt(row, col))
+		}
+		for _, col := range melt {
+			val := cellAt(row, col)
+			if melter.SkipEmpty && strings.TrimSpace(val) == "" {
+				continue
+			}
+			out := append(append([]string{}, idCells...), columnName(col, header), val)
+			if err := w.Write(out); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// otherColumns returns the columns up to width not in cols
+func otherColumns(cols []int, width int) []int {
+	others := []int{}
+	for i := 0; i < width; i++ {
+		found := false
+		for _, col := range cols {
+			if col == i {
+				found = true
+				break
+			}
+		}
+		if !found {
+			others = append(others, i)
+		}
+	}
+	return others
+}
+
+// cellAt returns the cell at col, missing cells are empty
+func cellAt(row []string, col int) string {
+	if col >= 0 && col < len(row) {
+		return row[col]
+	}
+	return ""
+}
+
+// CSVPivoter turns key/value rows of CSV content into wide columns, the
+// values of the key column become the column names. It is the reverse
+// of CSVMelter.
+type CSVPivoter struct {
+	// IDs are column selectors of the columns identifying an output
+	// row, rows with the same values are combined
+	IDs string
+	// Key is the column holding the names of the new columns
+	Key string
+	// Value is the column holding the cell values of the new columns
+	Value string
+	// HeaderRow, if true, allows columns to be referred to by name
+	HeaderRow bool
+	// Aggregate combines more than one value for the same row and key,
+	// one of first, last, count, sum, avg, min, max, distinct or concat.
+	// If empty more than one value is an error.
+	Aggregate string
+	// Separator joins concat values, defaults to "; "
+	Separator string
+}
+
+// pivotRow holds the id cells and aggregates of an output row
+type pivotRow struct {
+	ids    []string
+	states map[string]*aggState
+}
+
+// Pivot reads CSV content from r writing the wide rows to w. Rows and
+// columns are in the order first seen, all the rows are held in memory.
+func (pivoter *CSVPivoter) Pivot(r *csv.Reader, w *csv.Writer) error {
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = false
+	var header []string
+	if pivoter.HeaderRow {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		header = row
+	}
+	ids := []int{}
+	if pivoter.IDs != "" {
+		cols, err := ParseColumns(pivoter.IDs, header)
+		if err != nil {
+			return err
+		}
+		ids = cols
+	}
+	if pivoter.Key == "" || pivoter.Value == "" {
+		return fmt.Errorf("missing key or value column")
+	}
+	keyCol, err := ParseColumn(pivoter.Key, header)
+	if err != nil {
+		return err
+	}
+	valueCol, err := ParseColumn(pivoter.Value, header)
+	if err != nil {
+		return err
+	}
+	agg := &CSVAggregate{Func: strings.ToLower(pivoter.Aggregate), Column: valueCol}
+	switch agg.Func {
+	case "", "first", "last", "count", "sum", "avg", "min", "max", "distinct", "concat":
+	default:
+		return fmt.Errorf("unknown aggregate %q", pivoter.Aggregate)
+	}
+	separator := pivoter.Separator
+	if separator == "" {
+		separator = "; "
+	}
+
+	rows := map[string]*pivotRow{}
+	order := []*pivotRow{}
+	keys := []string{}
+	seen := map[string]bool{}
+	for lineNo := 1; ; lineNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("row %d, %s", lineNo, err)
+		}
+		idCells := []string{}
+		for _, col := range ids {
+			idCells = append(idCells, cellAt(row, col))
+		}
+		id := strings.Join(idCells, "\x1f")
+		pRow, ok := rows[id]
+		if !ok {
+			pRow = &pivotRow{ids: idCells, states: map[string]*aggState{}}
+			rows[id] = pRow
+			order = append(order, pRow)
+		}
+		key := cellAt(row, keyCol)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+		state, ok := pRow.states[key]
+		if !ok {
+			state = &aggState{}
+			pRow.states[key] = state
+		} else if agg.Func == "" {
+			return fmt.Errorf("row %d, more than one value for %q (%s), choose an aggregate", lineNo, key, strings.Join(idCells, ", "))
+		}
+		state.add(agg, row)
+	}
+	names := []string{}
+	for _, col := range ids {
+		names = append(names, columnName(col, header))
+	}
+	if err := w.Write(append(names, keys...)); err != nil {
+		return err
+	}
+	for _, pRow := range order {
+		out := append([]string{}, pRow.ids...)
+		for _, key := range keys {
+			val := ""
+			if state, ok := pRow.states[key]; ok {
+				val = state.result(agg, separator)
+			}
+			out = append(out, val)
+		}
+		if err := w.Write(out); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,74 @@
+package datatools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestCSVTranspose(t *testing.T) {
+	rows := [][]string{{"id", "a", "b"}, {"1", "x"}, {"2", "y", "z"}}
+	expected := [][]string{{"id", "1", "2"}, {"a", "x", "y"}, {"b", "", "z"}}
+	result := CSVTranspose(rows)
+	if len(result) != len(expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+		t.FailNow()
+	}
+	for i, row := range result {
+		if strings.Join(row, ",") != strings.Join(expected[i], ",") {
+			t.Errorf("expected %+v, got %+v", expected[i], row)
+		}
+	}
+}
+
+func TestCSVMeltPivot(t *testing.T) {
+	src := `collection,2017,2018
+books,10,12
+maps,3,
+`
+	melted := `collection,year,items
+books,2017,10
+books,2018,12
+maps,2017,3
+`
+	melter := &CSVMelter{IDs: "collection", HeaderRow: true, KeyName: "year", ValueName: "items", SkipEmpty: true}
+	buf := bytes.NewBuffer([]byte{})
+	if err := melter.Melt(csv.NewReader(strings.NewReader(src)), csv.NewWriter(buf)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if buf.String() != melted {
+		t.Errorf("expected\n%s\ngot\n%s", melted, buf.String())
+	}
+
+	// Pivoting the melted rows gives back the original
+	pivoter := &CSVPivoter{IDs: "collection", Key: "year", Value: "items", HeaderRow: true}
+	buf = bytes.NewBuffer([]byte{})
+	if err := pivoter.Pivot(csv.NewReader(strings.NewReader(melted)), csv.NewWriter(buf)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if buf.String() != src {
+		t.Errorf("expected\n%s\ngot\n%s", src, buf.String())
+	}
+
+	// Collisions need an aggregate
+	long := melted + "books,2017,5\n"
+	buf = bytes.NewBuffer([]byte{})
+	if err := pivoter.Pivot(csv.NewReader(strings.NewReader(long)), csv.NewWriter(buf)); err == nil {
+		t.Errorf("expected an error for more than one value")
+	}
+	for agg, expected := range map[string]string{"sum": "15", "first": "10", "last": "5", "count": "2", "concat": "10; 5"} {
+		pivoter.Aggregate = agg
+		buf = bytes.NewBuffer([]byte{})
+		if err := pivoter.Pivot(csv.NewReader(strings.NewReader(long)), csv.NewWriter(buf)); err != nil {
+			t.Error(err)
+			continue
+		}
+		rows, _ := csv.NewReader(buf).ReadAll()
+		if len(rows) != 3 || rows[1][1] != expected {
+			t.Errorf("(%s) expected %s, got %+v", agg, expected, rows)
+		}
+	}
+}
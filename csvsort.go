@@ -0,0 +1,421 @@
+// csvsort.go provides a multi-column, typed sort for CSV content which
+// spills sorted runs to temporary files when the content is too large
+// to sort in memory.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// Sort types used by CSVSortKey
+	SortString  = "string"
+	SortNoCase  = "nocase"
+	SortNumeric = "numeric"
+	SortNatural = "natural"
+	SortDate    = "date"
+
+	// DefaultSortMemory is the number of bytes of CSV content held in
+	// memory before a sorted run is written to a temporary file.
+	DefaultSortMemory = 64 * 1024 * 1024
+)
+
+// CSVSortKey describes one column of a multi-column sort.
+type CSVSortKey struct {
+	// Col is the zero based column number
+	Col int
+	// Descending reverses the order for this column
+	Descending bool
+	// Type is one of "string", "nocase", "numeric", "natural" or "date"
+	Type string
+	// Layout is the Go time layout used to parse "date" columns
+	Layout string
+}
+
+// ParseSortKey parses a sort key expression of the form
+// [+|-]COLUMN[:TYPE[:LAYOUT]]. A leading minus sorts the column in
+// descending order, COLUMN is a column number or header name and TYPE
+// defaults to "string". LAYOUT is a Go time layout for the "date" type
+// and defaults to "2006-01-02".
+func ParseSortKey(s string, header []string) (*CSVSortKey, error) {
+	key := new(CSVSortKey)
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		key.Descending = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	colExpr, rest, _ := cutSelector(s)
+	sortType, layout, _ := strings.Cut(rest, ":")
+	col, err := ParseColumn(colExpr, header)
+	if err != nil {
+		return nil, err
+	}
+	key.Col = col
+	key.Type = strings.ToLower(strings.TrimSpace(sortType))
+	switch key.Type {
+	case "":
+		key.Type = SortString
+	case SortString, SortNoCase, SortNumeric, SortNatural:
+	case SortDate:
+		key.Layout = layout
+		if key.Layout == "" {
+			key.Layout = "2006-01-02"
+		}
+	default:
+		return nil, fmt.Errorf("%q, unknown sort type %q", s, sortType)
+	}
+	return key, nil
+}
+
+// sortValue holds a cell prepared for comparison. ok is false when a
+// numeric or date cell could not be parsed.
+type sortValue struct {
+	s  string
+	f  float64
+	ok bool
+}
+
+// sortRow is a CSV row with its prepared sort values
+type sortRow struct {
+	cells []string
+	vals  []sortValue
+}
+
+func makeSortRow(cells []string, keys []*CSVSortKey) *sortRow {
+	row := &sortRow{cells: cells, vals: make([]sortValue, len(keys))}
+	for i, key := range keys {
+		cell := ""
+		if key.Col < len(cells) {
+			cell = cells[key.Col]
+		}
+		val := sortValue{s: cell}
+		switch key.Type {
+		case SortNoCase:
+			val.s = strings.ToLower(cell)
+		case SortNumeric:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(cell), 64); err == nil {
+				val.f, val.ok = f, true
+			}
+		case SortDate:
+			if t, err := time.Parse(key.Layout, strings.TrimSpace(cell)); err == nil {
+				val.f, val.ok = float64(t.UnixNano()), true
+			}
+		}
+		row.vals[i] = val
+	}
+	return row
+}
+
+// NaturalCompare compares two strings treating runs of digits as
+// numbers so "file2" sorts before "file10". It returns -1, 0 or 1.
+func NaturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := unicode.IsDigit(rune(a[0])), unicode.IsDigit(rune(b[0]))
+		i, j := chunkEnd(a, aDigits), chunkEnd(b, bDigits)
+		x, y := a[:i], b[:j]
+		a, b = a[i:], b[j:]
+		if aDigits && bDigits {
+			x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			if len(x) != len(y) {
+				if len(x) < len(y) {
+					return -1
+				}
+				return 1
+			}
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// chunkEnd returns the length of the leading run of digits (or non-digits)
+func chunkEnd(s string, digits bool) int {
+	for i := 0; i < len(s); i++ {
+		if unicode.IsDigit(rune(s[i])) != digits {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// compareSortRows compares two rows key by key, returning -1, 0 or 1.
+// Cells that can't be parsed as numbers or dates sort after those that
+// can regardless of the direction of the sort.
+func compareSortRows(a, b *sortRow, keys []*CSVSortKey) int {
+	for i, key := range keys {
+		x, y := a.vals[i], b.vals[i]
+		c := 0
+		switch key.Type {
+		case SortNumeric, SortDate:
+			// NOTE: unparsed cells sort last in either direction
+			switch {
+			case x.ok && !y.ok:
+				return -1
+			case !x.ok && y.ok:
+				return 1
+			case !x.ok && !y.ok:
+				c = strings.Compare(x.s, y.s)
+			case x.f < y.f:
+				c = -1
+			case x.f > y.f:
+				c = 1
+			}
+		case SortNatural:
+			c = NaturalCompare(x.s, y.s)
+		default:
+			c = strings.Compare(x.s, y.s)
+		}
+		if c != 0 {
+			if key.Descending {
+				return -c
+			}
+			return c
+		}
+	}
+	return 0
+}
+
+// CSVSorter sorts CSV content by one or more columns. Content that
+// doesn't fit in MaxMemory is sorted in runs which are written to
+// temporary files and then merged.
+type CSVSorter struct {
+	// Keys holds the sort key expressions, see ParseSortKey
+	Keys []string
+	// HeaderRow, if true, keeps the first row at the top of the output
+	// and allows keys to refer to columns by name.
+	HeaderRow bool
+	// MaxMemory is the approximate number of bytes of CSV content
+	// sorted in memory, defaults to DefaultSortMemory.
+	MaxMemory int64
+	// TmpDir is where the sorted runs are written, defaults to os.TempDir()
+	TmpDir string
+
+	keys []*CSVSortKey
+	runs []string
+}
+
+// Sort reads CSV content from r and writes it sorted to w
+func (sorter *CSVSorter) Sort(r *csv.Reader, w *csv.Writer) error {
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = false
+	defer sorter.removeRuns()
+
+	var header []string
+	if sorter.HeaderRow {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		header = row
+	}
+	if len(sorter.Keys) == 0 {
+		return fmt.Errorf("missing sort key")
+	}
+	sorter.keys = []*CSVSortKey{}
+	for _, expr := range sorter.Keys {
+		key, err := ParseSortKey(expr, header)
+		if err != nil {
+			return err
+		}
+		sorter.keys = append(sorter.keys, key)
+	}
+	maxMemory := sorter.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = DefaultSortMemory
+	}
+
+	// Sort the content in runs of maxMemory, spilling each run to disk
+	// if the content doesn't fit.
+	rows := []*sortRow{}
+	size := int64(0)
+	for lineNo := 1; ; lineNo++ {
+		cells, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("row %d, %s", lineNo, err)
+		}
+		rows = append(rows, makeSortRow(cells, sorter.keys))
+		for _, cell := range cells {
+			size += int64(len(cell)) + 16
+		}
+		size += 64
+		if size >= maxMemory {
+			if err := sorter.writeRun(rows); err != nil {
+				return err
+			}
+			rows, size = []*sortRow{}, 0
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareSortRows(rows[i], rows[j], sorter.keys) < 0
+	})
+	if header != nil {
+		if err := w.Write(header); err != nil {
+			return err
+		}
+	}
+	if len(sorter.runs) == 0 {
+		for _, row := range rows {
+			if err := w.Write(row.cells); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := sorter.mergeRuns(w, rows); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeRun sorts rows and writes them to a temporary file
+func (sorter *CSVSorter) writeRun(rows []*sortRow) error {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareSortRows(rows[i], rows[j], sorter.keys) < 0
+	})
+	fp, err := os.CreateTemp(sorter.TmpDir, "csvsort-*.csv")
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	sorter.runs = append(sorter.runs, fp.Name())
+	w := csv.NewWriter(fp)
+	for _, row := range rows {
+		if err := w.Write(row.cells); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (sorter *CSVSorter) removeRuns() {
+	for _, fName := range sorter.runs {
+		os.Remove(fName)
+	}
+	sorter.runs = nil
+}
+
+// runReader supplies the next row from a sorted run, either a file
+// or the rows still held in memory.
+type runReader struct {
+	id   int
+	row  *sortRow
+	r    *csv.Reader
+	rows []*sortRow
+}
+
+func (run *runReader) next(keys []*CSVSortKey) error {
+	if run.r == nil {
+		if len(run.rows) == 0 {
+			return io.EOF
+		}
+		run.row, run.rows = run.rows[0], run.rows[1:]
+		return nil
+	}
+	cells, err := run.r.Read()
+	if err != nil {
+		return err
+	}
+	run.row = makeSortRow(cells, keys)
+	return nil
+}
+
+// runHeap orders runs by their current row, ties go to the earlier
+// run so the merge is stable.
+type runHeap struct {
+	runs []*runReader
+	keys []*CSVSortKey
+}
+
+func (h *runHeap) Len() int { return len(h.runs) }
+func (h *runHeap) Less(i, j int) bool {
+	c := compareSortRows(h.runs[i].row, h.runs[j].row, h.keys)
+	if c == 0 {
+		return h.runs[i].id < h.runs[j].id
+	}
+	return c < 0
+}
+func (h *runHeap) Swap(i, j int)      { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *runHeap) Push(x interface{}) { h.runs = append(h.runs, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	run := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return run
+}
+
+// mergeRuns merges the sorted runs on disk with the final run held in
+// memory writing the result to w.
+func (sorter *CSVSorter) mergeRuns(w *csv.Writer, rows []*sortRow) error {
+	h := &runHeap{keys: sorter.keys}
+	for i, fName := range sorter.runs {
+		fp, err := os.Open(fName)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		r := csv.NewReader(fp)
+		r.FieldsPerRecord = -1
+		run := &runReader{id: i, r: r}
+		if err := run.next(sorter.keys); err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		h.runs = append(h.runs, run)
+	}
+	run := &runReader{id: len(sorter.runs), rows: rows}
+	if err := run.next(sorter.keys); err == nil {
+		h.runs = append(h.runs, run)
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		run := h.runs[0]
+		if err := w.Write(run.row.cells); err != nil {
+			return err
+		}
+		if err := run.next(sorter.keys); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	return nil
+}
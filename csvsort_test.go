@@ -0,0 +1,104 @@
+package datatools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"file2", "file10", -1},
+		{"file10", "file2", 1},
+		{"file02", "file2", 0},
+		{"a", "b", -1},
+		{"v1.10", "v1.9", 1},
+		{"", "a", -1},
+	}
+	for _, test := range tests {
+		if got := NaturalCompare(test.a, test.b); got != test.expected {
+			t.Errorf("NaturalCompare(%q, %q) expected %d, got %d", test.a, test.b, test.expected, got)
+		}
+	}
+}
+
+func TestParseSortKey(t *testing.T) {
+	header := []string{"ID", "Title", "Published"}
+	key, err := ParseSortKey("-Published:date:Jan 2, 2006 15:04", header)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if key.Col != 2 || !key.Descending || key.Type != SortDate || key.Layout != "Jan 2, 2006 15:04" {
+		t.Errorf("unexpected key %+v", key)
+	}
+	key, err = ParseSortKey("1:numeric", header)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if key.Col != 0 || key.Descending || key.Type != SortNumeric {
+		t.Errorf("unexpected key %+v", key)
+	}
+	if _, err := ParseSortKey("Title:soundex", header); err == nil {
+		t.Errorf("expected an error for an unknown sort type")
+	}
+}
+
+func TestCSVSorter(t *testing.T) {
+	src := `id,title,year
+10,"The Wee
+Free Men",2003
+2,Canary Row,1945
+1,the hobbit,1937
+3,Monstrous Regiment,2003
+20,Going Postal,2004
+4,Thud!,unknown
+`
+	tests := []struct {
+		keys     []string
+		expected []string
+	}{
+		{[]string{"id:numeric"}, []string{"1", "2", "3", "4", "10", "20"}},
+		{[]string{"id"}, []string{"1", "10", "2", "20", "3", "4"}},
+		{[]string{"-year:numeric", "title:nocase"}, []string{"20", "3", "10", "2", "1", "4"}},
+		{[]string{"title"}, []string{"2", "20", "3", "10", "4", "1"}},
+		{[]string{"title:nocase"}, []string{"2", "20", "3", "1", "10", "4"}},
+	}
+	for _, maxMemory := range []int64{0, 100} {
+		for _, test := range tests {
+			sorter := &CSVSorter{
+				Keys:      test.keys,
+				HeaderRow: true,
+				MaxMemory: maxMemory,
+				TmpDir:    t.TempDir(),
+			}
+			buf := bytes.NewBuffer([]byte{})
+			if err := sorter.Sort(csv.NewReader(strings.NewReader(src)), csv.NewWriter(buf)); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			rows, err := csv.NewReader(buf).ReadAll()
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if len(rows) != len(test.expected)+1 || rows[0][0] != "id" {
+				t.Errorf("%+v (memory %d) unexpected rows %+v", test.keys, maxMemory, rows)
+				continue
+			}
+			for i, id := range test.expected {
+				if rows[i+1][0] != id {
+					t.Errorf("%+v (memory %d) row %d expected id %s, got %s", test.keys, maxMemory, i+1, id, rows[i+1][0])
+				}
+			}
+			if len(sorter.runs) != 0 {
+				t.Errorf("expected temporary runs to be removed, %+v", sorter.runs)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// csvsplit.go provides splitting CSV content into several files by a number
+// of rows, a size in bytes or the values of a column.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultMaxOpen is the number of files CSVSplitter keeps open
+	// when splitting by column value
+	DefaultMaxOpen = 128
+)
+
+// CSVSplitter splits CSV content into several files, each starting
+// with the header row. Set one of Rows, Bytes or Column.
+type CSVSplitter struct {
+	// Rows is the number of rows (not counting the header) per file
+	Rows int
+	// Bytes is the largest size of a file, a row larger than this
+	// is written to a file of its own
+	Bytes int64
+	// Column is a column selector (see ParseColumn), one file is
+	// written for each value
+	Column string
+	// HeaderRow, if true, repeats the first row at the top of each file
+	HeaderRow bool
+	// Prefix of the file names, a number (e.g. split-0001.csv) or the
+	// column value (e.g. split-2017.csv) is added. Defaults to "split".
+	Prefix string
+	// Comma is the delimiter of the files written, defaults to ','
+	Comma rune
+	// UseCRLF ends lines with CRLF
+	UseCRLF bool
+	// MaxOpen is the number of files kept open when splitting by
+	// column value, defaults to DefaultMaxOpen
+	MaxOpen int
+	// Files holds the names of the files written in the order created
+	Files []string
+
+	header  []string
+	buf     *bytes.Buffer
+	enc     *csv.Writer
+	current *splitFile
+	byValue map[string]*splitFile
+	names   map[string]bool
+	open    int
+}
+
+// splitFile is a file being written by CSVSplitter
+type splitFile struct {
+	name string
+	fp   *os.File
+	w    *bufio.Writer
+	size int64
+	rows int
+}
+
+// encode returns a row as CSV
+func (splitter *CSVSplitter) encode(row []string) ([]byte, error) {
+	splitter.buf.Reset()
+	if err := splitter.enc.Write(row); err != nil {
+		return nil, err
+	}
+	splitter.enc.Flush()
+	if err := splitter.enc.Error(); err != nil {
+		return nil, err
+	}
+	return splitter.buf.Bytes(), nil
+}
+
+// create starts a new file writing the header row to it
+func (splitter *CSVSplitter) create(name string) (*splitFile, error) {
+	fp, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	splitter.Files = append(splitter.Files, name)
+	splitter.open++
+	sf := &splitFile{name: name, fp: fp, w: bufio.NewWriter(fp)}
+	if splitter.header != nil {
+		if err := splitter.write(sf, splitter.header); err != nil {
+			return nil, err
+		}
+		sf.rows = 0
+	}
+	return sf, nil
+}
+
+// write writes a row to a file reopening it if it was closed
+func (splitter *CSVSplitter) write(sf *splitFile, row []string) error {
+	if sf.fp == nil {
+		fp, err := os.OpenFile(sf.name, os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			return err
+		}
+		sf.fp, sf.w = fp, bufio.NewWriter(fp)
+		splitter.open++
+	}
+	src, err := splitter.encode(row)
+	if err != nil {
+		return err
+	}
+	if _, err := sf.w.Write(src); err != nil {
+		return err
+	}
+	sf.size += int64(len(src))
+	sf.rows++
+	return nil
+}
+
+// closeFile flushes and closes a file, it can be reopened by write
+func (splitter *CSVSplitter) closeFile(sf *splitFile) error {
+	if sf == nil || sf.fp == nil {
+		return nil
+	}
+	splitter.open--
+	if err := sf.w.Flush(); err != nil {
+		sf.fp.Close()
+		return err
+	}
+	err := sf.fp.Close()
+	sf.fp, sf.w = nil, nil
+	return err
+}
+
+// valueFileName returns a file name for a column value, characters
+// other than letters, digits, "-", "_" and "." are replaced by "_" and
+// a number is added if two values end up with the same name.
+func (splitter *CSVSplitter) valueFileName(prefix string, val string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(val))
+	if strings.Trim(name, ".") == "" {
+		name = "empty"
+	}
+	fName := fmt.Sprintf("%s-%s.csv", prefix, name)
+	for i := 2; splitter.names[fName]; i++ {
+		fName = fmt.Sprintf("%s-%s-%d.csv", prefix, name, i)
+	}
+	splitter.names[fName] = true
+	return fName
+}
+
+// Split reads CSV content from r writing it to the split files
+func (splitter *CSVSplitter) Split(r *csv.Reader) error {
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = false
+	set := 0
+	for _, ok := range []bool{splitter.Rows > 0, splitter.Bytes > 0, splitter.Column != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("split by one of rows, bytes or column")
+	}
+	prefix := splitter.Prefix
+	if prefix == "" {
+		prefix = "split"
+	}
+	maxOpen := splitter.MaxOpen
+	if maxOpen <= 0 {
+		maxOpen = DefaultMaxOpen
+	}
+	splitter.buf = bytes.NewBuffer([]byte{})
+	splitter.enc = csv.NewWriter(splitter.buf)
+	splitter.enc.UseCRLF = splitter.UseCRLF
+	if splitter.Comma != 0 {
+		splitter.enc.Comma = splitter.Comma
+	}
+	splitter.Files, splitter.current, splitter.open = []string{}, nil, 0
+	splitter.byValue, splitter.names = map[string]*splitFile{}, map[string]bool{}
+	defer func() {
+		splitter.closeFile(splitter.current)
+		for _, sf := range splitter.byValue {
+			splitter.closeFile(sf)
+		}
+	}()
+
+	splitter.header = nil
+	if splitter.HeaderRow {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		splitter.header = row
+	}
+	col := -1
+	if splitter.Column != "" {
+		var err error
+		if col, err = ParseColumn(splitter.Column, splitter.header); err != nil {
+			return err
+		}
+	}
+	for lineNo := 1; ; lineNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("row %d, %s", lineNo, err)
+		}
+		var sf *splitFile
+		switch {
+		case col >= 0:
+			val := cellAt(row, col)
+			sf = splitter.byValue[val]
+			if sf == nil || sf.fp == nil {
+				// Close the open files to stay under maxOpen
+				if splitter.open >= maxOpen {
+					for _, open := range splitter.byValue {
+						if err := splitter.closeFile(open); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			if sf == nil {
+				if sf, err = splitter.create(splitter.valueFileName(prefix, val)); err != nil {
+					return err
+				}
+				splitter.byValue[val] = sf
+			}
+		default:
+			sf = splitter.current
+			full := sf == nil || (splitter.Rows > 0 && sf.rows >= splitter.Rows)
+			if !full && splitter.Bytes > 0 && sf.rows > 0 {
+				src, err := splitter.encode(row)
+				if err != nil {
+					return err
+				}
+				full = sf.size+int64(len(src)) > splitter.Bytes
+			}
+			if full {
+				if err := splitter.closeFile(sf); err != nil {
+					return err
+				}
+				if sf, err = splitter.create(fmt.Sprintf("%s-%04d.csv", prefix, len(splitter.Files)+1)); err != nil {
+					return err
+				}
+				splitter.current = sf
+			}
+		}
+		if err := splitter.write(sf, row); err != nil {
+			return err
+		}
+	}
+	if err := splitter.closeFile(splitter.current); err != nil {
+		return err
+	}
+	for _, sf := range splitter.byValue {
+		if err := splitter.closeFile(sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package datatools
+
+import (
+	"encoding/csv"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCSVSplitter(t *testing.T) {
+	src := `id,year,title
+1,2017,A
+2,2018,B
+3,2017,C
+4,,D
+5,2017/18,E
+6,2017_18,F
+7,2018,G
+`
+	readFile := func(name string) string {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+		}
+		return string(src)
+	}
+	tmpDir := t.TempDir()
+
+	splitter := &CSVSplitter{Rows: 3, HeaderRow: true, Prefix: path.Join(tmpDir, "rows")}
+	if err := splitter.Split(csv.NewReader(strings.NewReader(src))); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(splitter.Files) != 3 {
+		t.Errorf("expected 3 files, got %+v", splitter.Files)
+	} else if got := readFile(splitter.Files[2]); got != "id,year,title\n7,2018,G\n" {
+		t.Errorf("unexpected last file %q", got)
+	}
+
+	splitter = &CSVSplitter{Bytes: 30, HeaderRow: true, Prefix: path.Join(tmpDir, "bytes")}
+	if err := splitter.Split(csv.NewReader(strings.NewReader(src))); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	rows := 0
+	for _, name := range splitter.Files {
+		got := readFile(name)
+		if len(got) > 30 || !strings.HasPrefix(got, "id,year,title\n") {
+			t.Errorf("unexpected file %s %q", name, got)
+		}
+		rows += strings.Count(got, "\n") - 1
+	}
+	if rows != 7 {
+		t.Errorf("expected 7 rows in %d files, got %d", len(splitter.Files), rows)
+	}
+
+	// MaxOpen of 1 forces files to be closed and reopened
+	splitter = &CSVSplitter{Column: "year", HeaderRow: true, Prefix: path.Join(tmpDir, "year"), MaxOpen: 1}
+	if err := splitter.Split(csv.NewReader(strings.NewReader(src))); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := map[string]string{
+		"year-2017.csv":      "id,year,title\n1,2017,A\n3,2017,C\n",
+		"year-2018.csv":      "id,year,title\n2,2018,B\n7,2018,G\n",
+		"year-empty.csv":     "id,year,title\n4,,D\n",
+		"year-2017_18.csv":   "id,year,title\n5,2017/18,E\n",
+		"year-2017_18-2.csv": "id,year,title\n6,2017_18,F\n",
+	}
+	if len(splitter.Files) != len(expected) {
+		t.Errorf("expected %d files, got %+v", len(expected), splitter.Files)
+	}
+	for name, content := range expected {
+		if got := readFile(path.Join(tmpDir, name)); got != content {
+			t.Errorf("%s expected %q, got %q", name, content, got)
+		}
+	}
+
+	splitter = &CSVSplitter{Rows: 3, Column: "year"}
+	if err := splitter.Split(csv.NewReader(strings.NewReader(src))); err == nil {
+		t.Errorf("expected an error splitting by rows and column")
+	}
+}
@@ -0,0 +1,123 @@
+// csvstack.go provides concatenating CSV files whose columns may be in a
+// different order by aligning them on their header names.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVStacker concatenates CSV content aligning the columns by their
+// header names. The output header holds the columns of all the inputs
+// in the order they are first seen, a name repeated in a header is
+// matched by its occurrence.
+type CSVStacker struct {
+	// HeaderRow, if false, the inputs have no header and their rows
+	// are written as is
+	HeaderRow bool
+	// ExactNames, if true, header names must match exactly otherwise
+	// case and surrounding spaces are ignored
+	ExactNames bool
+	// SourceColumn, if not empty, adds a first column with this name
+	// holding the name of the input each row came from
+	SourceColumn string
+	// Columns holds the output header once Stack has read the headers
+	Columns []string
+}
+
+// columnKey returns the name a header cell is matched on
+func (stacker *CSVStacker) columnKey(name string) string {
+	if stacker.ExactNames {
+		return name
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Stack reads the CSV content of readers writing it to w, names are
+// the names of the inputs used for the SourceColumn.
+func (stacker *CSVStacker) Stack(readers []*csv.Reader, names []string, w *csv.Writer) error {
+	if len(names) != len(readers) {
+		return fmt.Errorf("expected a name for each input")
+	}
+	// Read the headers and work out where each column goes
+	stacker.Columns = []string{}
+	positions := [][]int{}
+	if stacker.HeaderRow {
+		columns := map[string]int{}
+		for i, r := range readers {
+			r.FieldsPerRecord = -1
+			header, err := r.Read()
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("%s, %s", names[i], err)
+			}
+			seen := map[string]int{}
+			cols := []int{}
+			for _, name := range header {
+				key := stacker.columnKey(name)
+				occurrence := fmt.Sprintf("%s\x1f%d", key, seen[key])
+				seen[key]++
+				pos, ok := columns[occurrence]
+				if !ok {
+					pos = len(stacker.Columns)
+					columns[occurrence] = pos
+					stacker.Columns = append(stacker.Columns, name)
+				}
+				cols = append(cols, pos)
+			}
+			positions = append(positions, cols)
+		}
+		header := stacker.Columns
+		if stacker.SourceColumn != "" {
+			header = append([]string{stacker.SourceColumn}, header...)
+		}
+		if err := w.Write(header); err != nil {
+			return err
+		}
+	}
+	for i, r := range readers {
+		r.FieldsPerRecord = -1
+		for lineNo := 1; ; lineNo++ {
+			row, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("%s, row %d, %s", names[i], lineNo, err)
+			}
+			out := row
+			if stacker.HeaderRow {
+				out = make([]string, len(stacker.Columns))
+				for j, val := range row {
+					if j < len(positions[i]) {
+						out[positions[i][j]] = val
+					} else if strings.TrimSpace(val) != "" {
+						return fmt.Errorf("%s, row %d, more cells than the header", names[i], lineNo)
+					}
+				}
+			}
+			if stacker.SourceColumn != "" {
+				out = append([]string{names[i]}, out...)
+			}
+			if err := w.Write(out); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,49 @@
+package datatools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestCSVStacker(t *testing.T) {
+	srcs := []string{
+		"id,title,year\n1,A,2017\n",
+		"Year , ID,Title,notes\n2018,2,B,new\n",
+		"title,id\nC,3\n",
+	}
+	readers := []*csv.Reader{}
+	for _, src := range srcs {
+		readers = append(readers, csv.NewReader(strings.NewReader(src)))
+	}
+	stacker := &CSVStacker{HeaderRow: true, SourceColumn: "source"}
+	buf := bytes.NewBuffer([]byte{})
+	if err := stacker.Stack(readers, []string{"jan.csv", "feb.csv", "mar.csv"}, csv.NewWriter(buf)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := `source,id,title,year,notes
+jan.csv,1,A,2017,
+feb.csv,2,B,2018,new
+mar.csv,3,C,,
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	// Exact names keep differently written columns apart
+	readers = []*csv.Reader{
+		csv.NewReader(strings.NewReader("id,title\n1,A\n")),
+		csv.NewReader(strings.NewReader("ID,title\n2,B\n")),
+	}
+	stacker = &CSVStacker{HeaderRow: true, ExactNames: true}
+	buf = bytes.NewBuffer([]byte{})
+	if err := stacker.Stack(readers, []string{"a", "b"}, csv.NewWriter(buf)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if buf.String() != "id,title,ID\n1,A,\n,B,2\n" {
+		t.Errorf("unexpected %q", buf.String())
+	}
+}
@@ -0,0 +1,349 @@
+// csvstat.go profiles the columns of CSV content in a single pass.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxDistinct is the number of distinct values per column
+	// counted exactly before CSVStats switches to an estimate.
+	DefaultMaxDistinct = 100000
+
+	// hllPrecision gives 2^14 registers, a standard error of about 0.8%
+	hllPrecision = 14
+)
+
+// ValueCount is a cell value and the number of times it was found
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// ColumnStats holds the profile of a single CSV column
+type ColumnStats struct {
+	// Column number counting from one
+	Column int `json:"column"`
+	// Name from the header row
+	Name string `json:"name,omitempty"`
+	// Type is "integer", "real", "date", "text" or "empty"
+	Type string `json:"type"`
+	// Count of rows examined
+	Count int `json:"count"`
+	// Empty cells (including those only holding spaces)
+	Empty int `json:"empty"`
+	// Null cells, matching one of the null values (e.g. "NULL")
+	Null int `json:"null"`
+	// Distinct non-empty values
+	Distinct int `json:"distinct"`
+	// DistinctApprox is true when Distinct is an estimate
+	DistinctApprox bool `json:"distinct_approximate,omitempty"`
+	// Min and Max are numeric for integer and real columns, otherwise
+	// they are compared as strings
+	Min string `json:"min,omitempty"`
+	Max string `json:"max,omitempty"`
+	// Mean of integer and real columns
+	Mean *float64 `json:"mean,omitempty"`
+	// MaxLength is the length in characters of Longest
+	MaxLength int    `json:"max_length"`
+	Longest   string `json:"longest,omitempty"`
+	// MostFrequent non-empty values, most common first
+	MostFrequent []*ValueCount `json:"most_frequent,omitempty"`
+	// MostFrequentApprox is true when counts may be overestimated
+	MostFrequentApprox bool `json:"most_frequent_approximate,omitempty"`
+
+	colType             string
+	minStr, maxStr      string
+	minNum, maxNum, sum float64
+	numCount            int
+	freq                map[string]int
+	capacity            int
+	hll                 *hyperLogLog
+}
+
+// hyperLogLog estimates the number of distinct values in a stream
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+func (hll *hyperLogLog) add(s string) {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	// Mix the bits (splitmix64 finalizer) as FNV's high bits are weak
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	i := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > hll.registers[i] {
+		hll.registers[i] = rank
+	}
+}
+
+func (hll *hyperLogLog) count() int {
+	m := float64(len(hll.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range hll.registers {
+		sum += 1.0 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := (0.7213 / (1 + 1.079/m)) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Use linear counting for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(estimate + 0.5)
+}
+
+// add updates the column profile with a cell value
+func (col *ColumnStats) add(cell string, nullValues []string, maxDistinct int, top int) {
+	col.Count++
+	if strings.TrimSpace(cell) == "" {
+		col.Empty++
+		return
+	}
+	for _, val := range nullValues {
+		if cell == val {
+			col.Null++
+			return
+		}
+	}
+	cellType := sqlCellType(cell)
+	col.colType = mergeCellType(col.colType, cellType)
+	if cellType == "integer" || cellType == "real" {
+		f, _ := strconv.ParseFloat(cell, 64)
+		if col.numCount == 0 || f < col.minNum {
+			col.minNum = f
+		}
+		if col.numCount == 0 || f > col.maxNum {
+			col.maxNum = f
+		}
+		col.sum += f
+		col.numCount++
+	}
+	if col.minStr == "" || cell < col.minStr {
+		col.minStr = cell
+	}
+	if cell > col.maxStr {
+		col.maxStr = cell
+	}
+	if l := utf8.RuneCountInString(cell); l > col.MaxLength {
+		col.MaxLength, col.Longest = l, cell
+	}
+
+	// Count values exactly until there are too many distinct values,
+	// then estimate the distinct count and track only the most frequent
+	// values (the Space-Saving algorithm).
+	if col.hll == nil {
+		col.freq[cell]++
+		if len(col.freq) > maxDistinct {
+			col.hll = newHyperLogLog()
+			for val := range col.freq {
+				col.hll.add(val)
+			}
+			col.capacity = top * 10
+			if col.capacity < 100 {
+				col.capacity = 100
+			}
+			col.freq = topValues(col.freq, col.capacity)
+			col.MostFrequentApprox = true
+		}
+		return
+	}
+	col.hll.add(cell)
+	if _, ok := col.freq[cell]; ok || len(col.freq) < col.capacity {
+		col.freq[cell]++
+		return
+	}
+	minVal, minCount := "", -1
+	for val, count := range col.freq {
+		if minCount < 0 || count < minCount {
+			minVal, minCount = val, count
+		}
+	}
+	delete(col.freq, minVal)
+	col.freq[cell] = minCount + 1
+}
+
+// topValues returns a map of the n most frequent values in freq
+func topValues(freq map[string]int, n int) map[string]int {
+	counts := sortedCounts(freq)
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	m := make(map[string]int, n)
+	for _, vc := range counts {
+		m[vc.Value] = vc.Count
+	}
+	return m
+}
+
+// sortedCounts orders values by count, most frequent first, ties by value
+func sortedCounts(freq map[string]int) []*ValueCount {
+	counts := []*ValueCount{}
+	for val, count := range freq {
+		counts = append(counts, &ValueCount{Value: val, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Value < counts[j].Value
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	return counts
+}
+
+// finish fills in the reported fields once all rows are read
+func (col *ColumnStats) finish(top int) {
+	col.Type = col.colType
+	if col.Type == "" {
+		col.Type = "empty"
+	}
+	switch col.Type {
+	case "integer", "real":
+		col.Min = strconv.FormatFloat(col.minNum, 'f', -1, 64)
+		col.Max = strconv.FormatFloat(col.maxNum, 'f', -1, 64)
+		mean := col.sum / float64(col.numCount)
+		col.Mean = &mean
+	default:
+		col.Min, col.Max = col.minStr, col.maxStr
+	}
+	if col.hll != nil {
+		col.Distinct, col.DistinctApprox = col.hll.count(), true
+	} else {
+		col.Distinct = len(col.freq)
+	}
+	counts := sortedCounts(col.freq)
+	if len(counts) > top {
+		counts = counts[:top]
+	}
+	col.MostFrequent = counts
+	col.freq, col.hll = nil, nil
+}
+
+// CSVStats reads CSV content from r in a single pass and returns a
+// profile of each column. If headerRow is true the first row provides
+// the column names. top is the number of most frequent values reported.
+// Distinct values are counted exactly up to maxDistinct per column,
+// after which the distinct count is estimated to keep memory bounded.
+// Cells matching one of nullValues are counted as nulls.
+func CSVStats(r *csv.Reader, headerRow bool, top int, maxDistinct int, nullValues []string) ([]*ColumnStats, error) {
+	r.FieldsPerRecord = -1
+	if maxDistinct <= 0 {
+		maxDistinct = DefaultMaxDistinct
+	}
+	if top < 0 {
+		top = 0
+	}
+	header := []string{}
+	columns := []*ColumnStats{}
+	for lineNo := 1; ; lineNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d, %s", lineNo, err)
+		}
+		if lineNo == 1 && headerRow {
+			header = row
+			continue
+		}
+		for len(columns) < len(row) || len(columns) < len(header) {
+			col := &ColumnStats{Column: len(columns) + 1, freq: map[string]int{}}
+			if col.Column <= len(header) {
+				col.Name = header[col.Column-1]
+			}
+			// NOTE: rows seen before a wider row are counted as empty
+			if len(columns) > 0 {
+				col.Count = columns[0].Count
+				col.Empty = columns[0].Count
+			}
+			columns = append(columns, col)
+		}
+		for i, col := range columns {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			col.add(cell, nullValues, maxDistinct, top)
+		}
+	}
+	for _, col := range columns {
+		col.finish(top)
+	}
+	return columns, nil
+}
+
+// ColumnStatsToMarkdown writes the column profiles as a Github Flavored
+// Markdown table.
+func ColumnStatsToMarkdown(out io.Writer, columns []*ColumnStats) {
+	clean := strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ", "\r", " ")
+	rows := [][]string{
+		{"Column", "Name", "Type", "Count", "Empty", "Null", "Distinct", "Min", "Max", "Mean", "Max Length", "Most Frequent"},
+	}
+	for _, col := range columns {
+		distinct := fmt.Sprintf("%d", col.Distinct)
+		if col.DistinctApprox {
+			distinct = "~" + distinct
+		}
+		mean := ""
+		if col.Mean != nil {
+			mean = strconv.FormatFloat(*col.Mean, 'f', -1, 64)
+		}
+		frequent := []string{}
+		for _, vc := range col.MostFrequent {
+			frequent = append(frequent, fmt.Sprintf("%s (%d)", vc.Value, vc.Count))
+		}
+		row := []string{
+			fmt.Sprintf("%d", col.Column),
+			col.Name,
+			col.Type,
+			fmt.Sprintf("%d", col.Count),
+			fmt.Sprintf("%d", col.Empty),
+			fmt.Sprintf("%d", col.Null),
+			distinct,
+			col.Min,
+			col.Max,
+			mean,
+			fmt.Sprintf("%d", col.MaxLength),
+			strings.Join(frequent, ", "),
+		}
+		for i, cell := range row {
+			row[i] = clean.Replace(cell)
+		}
+		rows = append(rows, row)
+	}
+	WriteMarkdownTable(out, rows)
+}
@@ -0,0 +1,75 @@
+package datatools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCSVStats(t *testing.T) {
+	src := `id,title,price,added,notes
+1,The Hobbit,9.5,2024-01-02,
+2,Canary Row,10,2024-02-03,NULL
+3,The Hobbit,,2023-12-31,
+4,Thud!,12.5,,"a longer
+note"
+`
+	columns, err := CSVStats(csv.NewReader(strings.NewReader(src)), true, 2, 0, []string{"NULL"})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(columns) != 5 {
+		t.Errorf("expected 5 columns, got %d", len(columns))
+		t.FailNow()
+	}
+	id, title, price, added, notes := columns[0], columns[1], columns[2], columns[3], columns[4]
+	if id.Name != "id" || id.Type != "integer" || id.Count != 4 || id.Distinct != 4 || id.Min != "1" || id.Max != "4" || id.Mean == nil || *id.Mean != 2.5 {
+		t.Errorf("unexpected id stats %+v", id)
+	}
+	if title.Type != "text" || title.Distinct != 3 || title.Longest != "The Hobbit" || title.MaxLength != 10 {
+		t.Errorf("unexpected title stats %+v", title)
+	}
+	if len(title.MostFrequent) != 2 || title.MostFrequent[0].Value != "The Hobbit" || title.MostFrequent[0].Count != 2 {
+		t.Errorf("unexpected title most frequent %+v", title.MostFrequent)
+	}
+	if price.Type != "real" || price.Empty != 1 || price.Min != "9.5" || price.Max != "12.5" {
+		t.Errorf("unexpected price stats %+v", price)
+	}
+	if added.Type != "date" || added.Min != "2023-12-31" || added.Max != "2024-02-03" || added.Mean != nil {
+		t.Errorf("unexpected added stats %+v", added)
+	}
+	if notes.Type != "text" || notes.Empty != 2 || notes.Null != 1 || notes.Distinct != 1 {
+		t.Errorf("unexpected notes stats %+v", notes)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	ColumnStatsToMarkdown(buf, columns)
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 7 {
+		t.Errorf("expected 7 lines of markdown, got %d\n%s", len(lines), buf.String())
+	}
+}
+
+func TestCSVStatsApproximate(t *testing.T) {
+	buf := bytes.NewBufferString("val\n")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(buf, "v%d\n", i)
+		if i%10 == 0 {
+			fmt.Fprintf(buf, "common\n")
+		}
+	}
+	columns, err := CSVStats(csv.NewReader(buf), true, 1, 1000, nil)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	col := columns[0]
+	if !col.DistinctApprox || col.Distinct < 19000 || col.Distinct > 21000 {
+		t.Errorf("expected an approximate distinct count near 20001, got %d (%t)", col.Distinct, col.DistinctApprox)
+	}
+	if len(col.MostFrequent) != 1 || col.MostFrequent[0].Value != "common" {
+		t.Errorf("expected common to be the most frequent value, got %+v", col.MostFrequent)
+	}
+}
@@ -0,0 +1,477 @@
+// filter provides a small prefix expression language for selecting
+// rows of tabular data (e.g. CSV) by their cell values.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package filter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools"
+	"github.com/caltechlibrary/datatools/lexer"
+)
+
+const (
+	// Version of this package
+	Version = "v0.0.1"
+)
+
+var (
+	// arity holds the minimum and maximum number of arguments of each
+	// function, a maximum of -1 is unlimited.
+	arity = map[string][2]int{
+		"and":         {1, -1},
+		"or":          {1, -1},
+		"not":         {1, 1},
+		"eq":          {2, 2},
+		"ne":          {2, 2},
+		"lt":          {2, 2},
+		"le":          {2, 2},
+		"gt":          {2, 2},
+		"ge":          {2, 2},
+		"in":          {2, -1},
+		"match":       {2, 2},
+		"contains":    {2, 2},
+		"starts-with": {2, 2},
+		"ends-with":   {2, 2},
+		"empty":       {1, 1},
+		"cols":        {1, -1},
+		"colNo":       {1, 1},
+		"lower":       {1, 1},
+		"upper":       {1, 1},
+		"trim":        {1, 1},
+		"length":      {1, 1},
+		"join":        {2, 2},
+		"concat":      {1, -1},
+	}
+
+	// dateLayouts are the formats tried when comparing values as dates
+	dateLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		"2006-01",
+	}
+)
+
+// node is a literal value or a function call in a parsed expression
+type node struct {
+	fn    string
+	args  []*node
+	value interface{}
+	re    *regexp.Regexp
+}
+
+// Filter is a parsed filter expression
+type Filter struct {
+	src    string
+	root   *node
+	header []string
+	names  map[string]int
+}
+
+// parseAtom parses a literal string, number or boolean
+func parseAtom(token string) (*node, error) {
+	if strings.HasPrefix(token, `"`) {
+		s, err := strconv.Unquote(token)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s", token, err)
+		}
+		return &node{value: s}, nil
+	}
+	if token == "true" || token == "false" {
+		return &node{value: token == "true"}, nil
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return &node{value: f}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q, strings must be in double quotes", token)
+}
+
+// parse reads an expression starting at tokens[pos] returning it and
+// the position of the next token.
+func parse(tokens []string, pos int) (*node, int, error) {
+	if pos >= len(tokens) {
+		return nil, pos, fmt.Errorf("unexpected end of expression")
+	}
+	switch tokens[pos] {
+	case ")":
+		return nil, pos, fmt.Errorf("unexpected )")
+	case "(":
+	default:
+		n, err := parseAtom(tokens[pos])
+		return n, pos + 1, err
+	}
+	pos++
+	if pos >= len(tokens) {
+		return nil, pos, fmt.Errorf("unexpected end of expression")
+	}
+	n := &node{fn: tokens[pos]}
+	limits, ok := arity[n.fn]
+	if !ok {
+		return nil, pos, fmt.Errorf("unknown function %q", n.fn)
+	}
+	for pos++; pos < len(tokens) && tokens[pos] != ")"; {
+		var (
+			arg *node
+			err error
+		)
+		arg, pos, err = parse(tokens, pos)
+		if err != nil {
+			return nil, pos, err
+		}
+		n.args = append(n.args, arg)
+	}
+	if pos >= len(tokens) {
+		return nil, pos, fmt.Errorf("(%s is missing a )", n.fn)
+	}
+	if len(n.args) < limits[0] || (limits[1] >= 0 && len(n.args) > limits[1]) {
+		return nil, pos, fmt.Errorf("(%s ...) has the wrong number of arguments", n.fn)
+	}
+	// Compile a literal pattern once
+	if n.fn == "match" {
+		if pattern, ok := n.args[1].value.(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, pos, fmt.Errorf("(match ...) %s", err)
+			}
+			n.re = re
+		}
+	}
+	return n, pos + 1, nil
+}
+
+// Parse parses a filter expression. Expressions use a prefix notation,
+// e.g. (and (eq (cols 1) "x") (gt (cols "Year") 2017)). Literals are
+// double quoted strings, numbers and true or false.
+//
+// Columns are referenced with (cols N) where N counts from one (a
+// negative number counts back from the last column) or is a name from
+// the header row. Given more than one column cols returns a list.
+// (colNo NAME) returns the number of a named column.
+//
+// eq, ne, lt, le, gt and ge compare numbers numerically, dates (e.g.
+// 2017-06-12 or RFC3339 timestamps) chronologically and everything else
+// as strings. in checks if its first argument equals any of the others.
+//
+// and, or and not combine conditions. match tests a regular expression,
+// contains, starts-with, ends-with and empty test strings. lower, upper,
+// trim, length, join (a list with a separator) and concat work on
+// strings.
+func Parse(src string) (*Filter, error) {
+	tokens, err := lexer.Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	root, pos, err := parse(tokens, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pos < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q after expression", tokens[pos])
+	}
+	return &Filter{src: src, root: root}, nil
+}
+
+// String returns the source of the expression
+func (f *Filter) String() string {
+	return f.src
+}
+
+// SetHeader sets the header row used to find columns by name. Column
+// names given as literals are checked against it.
+func (f *Filter) SetHeader(header []string) error {
+	f.header = header
+	f.names = map[string]int{}
+	var check func(n *node) error
+	check = func(n *node) error {
+		for _, arg := range n.args {
+			if name, ok := arg.value.(string); ok && (n.fn == "cols" || n.fn == "colNo") {
+				if _, err := f.column(name, nil); err != nil {
+					return err
+				}
+			}
+			if err := check(arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(f.root)
+}
+
+// column resolves a column reference into a zero based index
+func (f *Filter) column(ref interface{}, row []string) (int, error) {
+	switch ref := ref.(type) {
+	case float64:
+		i := int(ref)
+		width := len(row)
+		if f.header != nil {
+			width = len(f.header)
+		}
+		switch {
+		case i > 0:
+			return i - 1, nil
+		case i < 0 && width+i >= 0:
+			return width + i, nil
+		}
+		return -1, fmt.Errorf("column %d does not exist", i)
+	case string:
+		if i, ok := f.names[ref]; ok {
+			return i, nil
+		}
+		if f.header == nil {
+			return -1, fmt.Errorf("%q, no header row to find column name", ref)
+		}
+		i, err := datatools.ParseColumn(`"`+ref+`"`, f.header)
+		if err != nil {
+			return -1, fmt.Errorf("%q, column not found", ref)
+		}
+		f.names[ref] = i
+		return i, nil
+	}
+	return -1, fmt.Errorf("%v is not a column number or name", ref)
+}
+
+// truthy converts a value into a boolean
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []string:
+		return len(v) > 0
+	}
+	return false
+}
+
+// toString converts a value into a string, lists are comma separated
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// toList converts a value into a list of strings
+func toList(v interface{}) []string {
+	if l, ok := v.([]string); ok {
+		return l
+	}
+	return []string{toString(v)}
+}
+
+// toNumber converts a value into a number if it looks like one
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, false
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+// toDate converts a value into a time if it looks like a date
+func toDate(v interface{}) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// compare orders two values, numbers are compared numerically, dates
+// chronologically and everything else as strings.
+func compare(a interface{}, b interface{}) int {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := toDate(a); ok {
+		if y, ok := toDate(b); ok {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+// eval evaluates a node against a row
+func (f *Filter) eval(n *node, row []string) (interface{}, error) {
+	if n.fn == "" {
+		return n.value, nil
+	}
+	// The boolean combinators only evaluate what they need
+	switch n.fn {
+	case "and", "or":
+		for _, arg := range n.args {
+			v, err := f.eval(arg, row)
+			if err != nil {
+				return nil, err
+			}
+			if truthy(v) == (n.fn == "or") {
+				return n.fn == "or", nil
+			}
+		}
+		return n.fn == "and", nil
+	}
+	args := []interface{}{}
+	for _, arg := range n.args {
+		v, err := f.eval(arg, row)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	switch n.fn {
+	case "not":
+		return !truthy(args[0]), nil
+	case "eq":
+		return compare(args[0], args[1]) == 0, nil
+	case "ne":
+		return compare(args[0], args[1]) != 0, nil
+	case "lt":
+		return compare(args[0], args[1]) < 0, nil
+	case "le":
+		return compare(args[0], args[1]) <= 0, nil
+	case "gt":
+		return compare(args[0], args[1]) > 0, nil
+	case "ge":
+		return compare(args[0], args[1]) >= 0, nil
+	case "in":
+		for _, v := range args[1:] {
+			if compare(args[0], v) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "match":
+		re := n.re
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(toString(args[1])); err != nil {
+				return nil, fmt.Errorf("(match ...) %s", err)
+			}
+		}
+		return re.MatchString(toString(args[0])), nil
+	case "contains":
+		return strings.Contains(toString(args[0]), toString(args[1])), nil
+	case "starts-with":
+		return strings.HasPrefix(toString(args[0]), toString(args[1])), nil
+	case "ends-with":
+		return strings.HasSuffix(toString(args[0]), toString(args[1])), nil
+	case "empty":
+		for _, s := range toList(args[0]) {
+			if strings.TrimSpace(s) != "" {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "cols":
+		values := []string{}
+		for _, ref := range args {
+			i, err := f.column(ref, row)
+			if err != nil {
+				return nil, err
+			}
+			// Missing cells are treated as empty
+			if i < len(row) {
+				values = append(values, row[i])
+			} else {
+				values = append(values, "")
+			}
+		}
+		if len(values) == 1 {
+			return values[0], nil
+		}
+		return values, nil
+	case "colNo":
+		i, err := f.column(args[0], row)
+		if err != nil {
+			return nil, err
+		}
+		return float64(i + 1), nil
+	case "lower":
+		return strings.ToLower(toString(args[0])), nil
+	case "upper":
+		return strings.ToUpper(toString(args[0])), nil
+	case "trim":
+		return strings.TrimSpace(toString(args[0])), nil
+	case "length":
+		if l, ok := args[0].([]string); ok {
+			return float64(len(l)), nil
+		}
+		return float64(utf8.RuneCountInString(toString(args[0]))), nil
+	case "join":
+		return strings.Join(toList(args[0]), toString(args[1])), nil
+	case "concat":
+		parts := []string{}
+		for _, v := range args {
+			parts = append(parts, toList(v)...)
+		}
+		return strings.Join(parts, ""), nil
+	}
+	return nil, fmt.Errorf("unknown function %q", n.fn)
+}
+
+// Eval evaluates the expression against a row returning a string,
+// number, boolean or list of strings.
+func (f *Filter) Eval(row []string) (interface{}, error) {
+	return f.eval(f.root, row)
+}
+
+// Match evaluates the expression against a row, the row matches if
+// the result is true, a non-zero number, a non-empty string or list.
+func (f *Filter) Match(row []string) (bool, error) {
+	v, err := f.Eval(row)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
@@ -0,0 +1,116 @@
+// filter provides a small prefix expression language for selecting
+// rows of tabular data (e.g. CSV) by their cell values.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package filter
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, src := range []string{
+		`(eq (cols 1) "x")`,
+		`(and (eq (cols 1) "x") (gt (cols 4) "2017-06-12"))`,
+		`(or (match (cols "Title") "^The ") (not (empty (cols 2))))`,
+		`(eq (join (cols (colNo "Last Name") (colNo "First Name")) ", ") "Doiel, R. S.")`,
+		`true`,
+		`"a \"quoted\" string"`,
+	} {
+		if _, err := Parse(src); err != nil {
+			t.Errorf("expected (%s) no errors, got %s", src, err)
+		}
+	}
+	for _, src := range []string{
+		``,
+		`(eq (cols 1) "x"`,
+		`(eq (cols 1) "x"))`,
+		`(eq (cols 1))`,
+		`(equals 1 1)`,
+		`(eq (cols 1) x)`,
+		`(match (cols 1) "[")`,
+		`(eq "unterminated)`,
+		`()`,
+	} {
+		if f, err := Parse(src); err == nil {
+			t.Errorf("expected (%s) an error, got %+v", src, f)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	header := []string{"ID", "Title", "Last Name", "First Name", "Published", "Pages"}
+	row := []string{"1", "The Go Programming Language", "Doiel", "R. S.", "2017-06-13", "380"}
+	tests := map[string]bool{
+		`(eq (cols 1) "1")`:                             true,
+		`(eq (cols 1) 1.0)`:                             true,
+		`(gt (cols "Pages") 40)`:                        true,
+		`(gt (cols "Pages") "40")`:                      true,
+		`(lt (cols -1) 1000)`:                           true,
+		`(gt (cols 5) "2017-06-12")`:                    true,
+		`(gt (cols 5) "2017-06-13T00:00:01Z")`:          false,
+		`(ge (cols "published") "2017-06-13")`:          true,
+		`(ne (cols "ID") "1")`:                          false,
+		`(and (eq (cols 1) "1") (gt (cols 6) 9))`:       true,
+		`(and (eq (cols 1) "1") (lt (cols 6) 9))`:       false,
+		`(or (eq (cols 1) "2") (lt (cols 6) 9))`:        false,
+		`(or (eq (cols 1) "2") (not (empty (cols 2))))`: true,
+		`(match (cols "Title") "^the go")`:              false,
+		`(match (lower (cols "Title")) "^the go")`:      true,
+		`(contains (cols 2) "Programming")`:             true,
+		`(starts-with (upper (cols 2)) "THE")`:          true,
+		`(ends-with (trim (cols 2)) "Language")`:        true,
+		`(in (cols 3) "Smith" "Doiel")`:                 true,
+		`(eq (length (cols 3)) 5)`:                      true,
+		`(eq (join (cols (colNo "Last Name") (colNo "First Name")) ", ") "Doiel, R. S.")`: true,
+		`(eq (concat (cols 4) " " (cols 3)) "R. S. Doiel")`:                               true,
+		`(empty (cols 7))`: true,
+	}
+	for src, expected := range tests {
+		f, err := Parse(src)
+		if err != nil {
+			t.Errorf("expected (%s) no errors, got %s", src, err)
+			continue
+		}
+		if err := f.SetHeader(header); err != nil {
+			t.Errorf("expected (%s) no errors, got %s", src, err)
+			continue
+		}
+		result, err := f.Match(row)
+		if err != nil {
+			t.Errorf("expected (%s) no errors, got %s", src, err)
+			continue
+		}
+		if result != expected {
+			t.Errorf("expected (%s) %t, got %t", src, expected, result)
+		}
+	}
+
+	// Unknown column names are reported with the header
+	f, _ := Parse(`(eq (cols "Author") "x")`)
+	if err := f.SetHeader(header); err == nil {
+		t.Errorf("expected an error for an unknown column name")
+	}
+	// Without a header only column numbers can be used
+	f, _ = Parse(`(eq (cols "Title") "x")`)
+	if _, err := f.Match(row); err == nil {
+		t.Errorf("expected an error for a column name without a header")
+	}
+	f, _ = Parse(`(eq (cols 2) "x")`)
+	if ok, err := f.Match(row); err != nil || ok {
+		t.Errorf("expected false, got %t, %s", ok, err)
+	}
+}
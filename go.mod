@@ -15,6 +15,7 @@ require (
 	github.com/hscells/doi v0.0.0-20170821055049-1a5819c7d576
 	github.com/lib/pq v1.10.9
 	github.com/tealeg/xlsx v1.0.5
+	golang.org/x/text v0.21.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
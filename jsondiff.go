@@ -0,0 +1,233 @@
+// jsondiff.go compares two JSON documents structurally producing a JSON
+// Patch, a JSON Merge Patch or a list of the changes found.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"sort"
+	"strconv"
+)
+
+// JSONChange describes a value added, removed, replaced or moved
+// between two JSON documents. Path and From are JSON Pointers.
+type JSONChange struct {
+	// Op is "add", "remove", "replace" or "move"
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	// Old is the value removed or replaced, New the value added or
+	// the replacement
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// JSONDiffer compares JSON documents decoded with JSONUnmarshal.
+// Object key order is ignored and numbers are compared by value.
+// Arrays are compared by position unless ArrayKey is set and every
+// element of both arrays is an object with a unique scalar value for
+// ArrayKey, then elements are matched by that value so an element
+// moving in an array is reported as a move.
+type JSONDiffer struct {
+	// ArrayKey is the name of an identity key (e.g. "id") used to match
+	// the elements of arrays of objects
+	ArrayKey string
+}
+
+// Diff returns the changes which turn oldDoc into newDoc. They are
+// ordered so they can be applied one after another, the paths of
+// array elements are their positions after the earlier changes.
+func (d *JSONDiffer) Diff(oldDoc interface{}, newDoc interface{}) []*JSONChange {
+	changes := []*JSONChange{}
+	d.diff([]string{}, oldDoc, newDoc, &changes)
+	return changes
+}
+
+// Patch returns a JSON Patch (RFC 6902) which turns oldDoc into newDoc
+func (d *JSONDiffer) Patch(oldDoc interface{}, newDoc interface{}) []*JSONPatchOperation {
+	patch := []*JSONPatchOperation{}
+	for _, change := range d.Diff(oldDoc, newDoc) {
+		patch = append(patch, NewJSONPatchOperation(change.Op, change.Path, change.From, change.New))
+	}
+	return patch
+}
+
+// JSONMergeDiff returns a JSON Merge Patch (RFC 7396) which turns
+// oldDoc into newDoc. A merge patch can't set a value to null (null
+// removes it) and replaces arrays whole.
+func JSONMergeDiff(oldDoc interface{}, newDoc interface{}) interface{} {
+	a, ok1 := oldDoc.(map[string]interface{})
+	b, ok2 := newDoc.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return jsonCopy(newDoc)
+	}
+	patch := map[string]interface{}{}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for key, val := range b {
+		old, ok := a[key]
+		if !ok || !jsonEqual(old, val) {
+			patch[key] = JSONMergeDiff(old, val)
+		}
+	}
+	return patch
+}
+
+// diff appends the changes between a and b found at path
+func (d *JSONDiffer) diff(path []string, a interface{}, b interface{}, changes *[]*JSONChange) {
+	if jsonEqual(a, b) {
+		return
+	}
+	switch x := a.(type) {
+	case map[string]interface{}:
+		if y, ok := b.(map[string]interface{}); ok {
+			d.diffObjects(path, x, y, changes)
+			return
+		}
+	case []interface{}:
+		if y, ok := b.([]interface{}); ok {
+			if d.ArrayKey != "" && d.keyed(x) != nil && d.keyed(y) != nil {
+				d.diffKeyed(path, x, y, changes)
+			} else {
+				d.diffPositional(path, x, y, changes)
+			}
+			return
+		}
+	}
+	*changes = append(*changes, &JSONChange{Op: "replace", Path: JSONPointer(path), Old: a, New: b})
+}
+
+// child returns a copy of path with token added
+func child(path []string, token string) []string {
+	return append(path[:len(path):len(path)], token)
+}
+
+// diffObjects compares the members of two objects in key order
+func (d *JSONDiffer) diffObjects(path []string, a map[string]interface{}, b map[string]interface{}, changes *[]*JSONChange) {
+	keys := []string{}
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		old, inA := a[key]
+		val, inB := b[key]
+		switch {
+		case !inB:
+			*changes = append(*changes, &JSONChange{Op: "remove", Path: JSONPointer(child(path, key)), Old: old})
+		case !inA:
+			*changes = append(*changes, &JSONChange{Op: "add", Path: JSONPointer(child(path, key)), New: val})
+		default:
+			d.diff(child(path, key), old, val, changes)
+		}
+	}
+}
+
+// diffPositional compares array elements at the same position, then
+// adds or removes (from the end) the extra elements
+func (d *JSONDiffer) diffPositional(path []string, a []interface{}, b []interface{}, changes *[]*JSONChange) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		d.diff(child(path, strconv.Itoa(i)), a[i], b[i], changes)
+	}
+	for i := len(a); i < len(b); i++ {
+		*changes = append(*changes, &JSONChange{Op: "add", Path: JSONPointer(child(path, strconv.Itoa(i))), New: b[i]})
+	}
+	for i := len(a) - 1; i >= len(b); i-- {
+		*changes = append(*changes, &JSONChange{Op: "remove", Path: JSONPointer(child(path, strconv.Itoa(i))), Old: a[i]})
+	}
+}
+
+// keyed returns the identity of each element of an array or nil if
+// the elements can't be matched by ArrayKey
+func (d *JSONDiffer) keyed(a []interface{}) []string {
+	keys := make([]string, len(a))
+	seen := map[string]bool{}
+	for i, elem := range a {
+		obj, ok := elem.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		val, ok := obj[d.ArrayKey]
+		if !ok {
+			return nil
+		}
+		switch val.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			return nil
+		}
+		// Identities are compared as JSON so 1 and "1" differ
+		src, err := JSONMarshal(val)
+		if err != nil || seen[string(src)] {
+			return nil
+		}
+		seen[string(src)] = true
+		keys[i] = string(src)
+	}
+	return keys
+}
+
+// diffKeyed matches array elements by ArrayKey. Elements missing from
+// b are removed, then b is walked in order moving matched elements
+// into place and adding new ones.
+func (d *JSONDiffer) diffKeyed(path []string, a []interface{}, b []interface{}, changes *[]*JSONChange) {
+	aKeys, bKeys := d.keyed(a), d.keyed(b)
+	inB := map[string]bool{}
+	for _, key := range bKeys {
+		inB[key] = true
+	}
+	// work holds the identities and elements of a as changes are made
+	workKeys, work := []string{}, []interface{}{}
+	for i := len(a) - 1; i >= 0; i-- {
+		if !inB[aKeys[i]] {
+			*changes = append(*changes, &JSONChange{Op: "remove", Path: JSONPointer(child(path, strconv.Itoa(i))), Old: a[i]})
+		}
+	}
+	for i, key := range aKeys {
+		if inB[key] {
+			workKeys, work = append(workKeys, key), append(work, a[i])
+		}
+	}
+	for j, key := range bKeys {
+		p := -1
+		for i := j; i < len(workKeys); i++ {
+			if workKeys[i] == key {
+				p = i
+				break
+			}
+		}
+		if p < 0 {
+			*changes = append(*changes, &JSONChange{Op: "add", Path: JSONPointer(child(path, strconv.Itoa(j))), New: b[j]})
+			workKeys = append(workKeys[:j], append([]string{key}, workKeys[j:]...)...)
+			work = append(work[:j], append([]interface{}{b[j]}, work[j:]...)...)
+			continue
+		}
+		if p > j {
+			*changes = append(*changes, &JSONChange{Op: "move", Path: JSONPointer(child(path, strconv.Itoa(j))), From: JSONPointer(child(path, strconv.Itoa(p)))})
+			elem := work[p]
+			workKeys = append(workKeys[:p], workKeys[p+1:]...)
+			work = append(work[:p], work[p+1:]...)
+			workKeys = append(workKeys[:j], append([]string{key}, workKeys[j:]...)...)
+			work = append(work[:j], append([]interface{}{elem}, work[j:]...)...)
+		}
+		d.diff(child(path, strconv.Itoa(j)), work[j], b[j], changes)
+	}
+}
@@ -0,0 +1,81 @@
+package datatools
+
+import (
+	"testing"
+)
+
+func TestJSONDiffer(t *testing.T) {
+	tests := []struct {
+		arrayKey string
+		old      string
+		new      string
+		expected string
+	}{
+		{"", `{"a": 1, "b": [1, 2]}`, `{"b": [1, 2], "a": 1.0}`, `[]`},
+		{"", `{"a": 1, "b": "x"}`, `{"a": 2, "c": "x"}`,
+			`[{"op":"replace","path":"/a","value":2},{"op":"remove","path":"/b"},{"op":"add","path":"/c","value":"x"}]`},
+		{"", `{"k": [1, 2, 3, 4]}`, `{"k": [1, 5]}`,
+			`[{"op":"replace","path":"/k/1","value":5},{"op":"remove","path":"/k/3"},{"op":"remove","path":"/k/2"}]`},
+		{"", `{"a/b": {"c~d": null}}`, `{"a/b": {"c~d": [1]}}`,
+			`[{"op":"replace","path":"/a~1b/c~0d","value":[1]}]`},
+		{"", `[{"id": 1}, {"id": 2}]`, `[{"id": 2}, {"id": 1}]`,
+			`[{"op":"replace","path":"/0/id","value":2},{"op":"replace","path":"/1/id","value":1}]`},
+		{"id", `[{"id": 1}, {"id": 2}]`, `[{"id": 2}, {"id": 1}]`,
+			`[{"op":"move","from":"/1","path":"/0"}]`},
+		{"id", `[{"id": 1, "n": "a"}, {"id": 2}, {"id": 3}]`, `[{"id": 3}, {"id": 4}, {"id": 1, "n": "b"}]`,
+			`[{"op":"remove","path":"/1"},{"op":"move","from":"/1","path":"/0"},{"op":"add","path":"/1","value":{"id":4}},{"op":"replace","path":"/2/n","value":"b"}]`},
+		{"id", `[{"id": 1}, {"id": 1}]`, `[{"id": 2}]`,
+			`[{"op":"replace","path":"/0/id","value":2},{"op":"remove","path":"/1"}]`},
+		{"", `"a"`, `null`, `[{"op":"replace","path":"","value":null}]`},
+	}
+	for i, test := range tests {
+		var oldDoc, newDoc interface{}
+		if err := JSONUnmarshal([]byte(test.old), &oldDoc); err != nil {
+			t.Fatal(err)
+		}
+		if err := JSONUnmarshal([]byte(test.new), &newDoc); err != nil {
+			t.Fatal(err)
+		}
+		differ := &JSONDiffer{ArrayKey: test.arrayKey}
+		patch := differ.Patch(oldDoc, newDoc)
+		src, _ := JSONMarshal(patch)
+		if string(src) != test.expected {
+			t.Errorf("test %d expected %s, got %s", i, test.expected, src)
+		}
+		patched, err := ApplyJSONPatch(oldDoc, patch)
+		if err != nil {
+			t.Errorf("test %d, %s", i, err)
+		} else if !jsonEqual(patched, newDoc) {
+			got, _ := JSONMarshal(patched)
+			t.Errorf("test %d expected the patch to produce %s, got %s", i, test.new, got)
+		}
+		// Decoding the patch should give the same operations
+		if decoded, err := ParseJSONPatch(src); err != nil || len(decoded) != len(patch) {
+			t.Errorf("test %d, can't decode patch %s, %v", i, src, err)
+		}
+	}
+}
+
+func TestJSONMergeDiff(t *testing.T) {
+	tests := [][3]string{
+		{`{"a": 1, "b": {"c": 2, "d": 3}}`, `{"a": 1.0, "b": {"c": 4}, "e": [1]}`, `{"b":{"c":4,"d":null},"e":[1]}`},
+		{`{"a": [1, 2]}`, `{"a": [1]}`, `{"a":[1]}`},
+		{`{"a": 1}`, `{"a": 1}`, `{}`},
+		{`{"a": 1}`, `["a"]`, `["a"]`},
+		{`"x"`, `{"a": {"b": 1}}`, `{"a":{"b":1}}`},
+	}
+	for _, test := range tests {
+		var oldDoc, newDoc interface{}
+		JSONUnmarshal([]byte(test[0]), &oldDoc)
+		JSONUnmarshal([]byte(test[1]), &newDoc)
+		patch := JSONMergeDiff(oldDoc, newDoc)
+		src, _ := JSONMarshal(patch)
+		if string(src) != test[2] {
+			t.Errorf("%s to %s expected %s, got %s", test[0], test[1], test[2], src)
+		}
+		if patched := ApplyMergePatch(oldDoc, patch); !jsonEqual(patched, newDoc) {
+			got, _ := JSONMarshal(patched)
+			t.Errorf("%s to %s, patch produced %s", test[0], test[1], got)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// jsonmerge.go implements a recursive merge of JSON documents with a choice
+// of strategies for merging arrays.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// MergeReplace replaces an array with the later one
+	MergeReplace = "replace"
+	// MergeConcat appends the elements of the later array
+	MergeConcat = "concat"
+	// MergeUnion appends the elements of the later array not already present
+	MergeUnion = "union"
+	// MergeIndex merges elements at the same position
+	MergeIndex = "index"
+	// MergeKey merges elements with the same value for JSONMerger.ArrayKey
+	MergeKey = "key"
+)
+
+// JSONMergeConflict describes two different values found at the same
+// path while merging. Path is a JSON Pointer.
+type JSONMergeConflict struct {
+	Path string `json:"path"`
+	// Old is the value merged so far, New the value being merged in
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+	// Kept is "old" or "new"
+	Kept string `json:"kept"`
+}
+
+// JSONMerger merges JSON documents decoded with JSONUnmarshal. Objects
+// are merged key by key at every level and arrays according to Arrays.
+// When two values can't be merged (e.g. two different strings, or an
+// object and a number) the later value is kept unless KeepFirst is set.
+type JSONMerger struct {
+	// Arrays is the strategy for merging arrays, MergeReplace (the
+	// default), MergeConcat, MergeUnion, MergeIndex or MergeKey
+	Arrays string
+	// ArrayKey is the identity key of the elements of arrays of objects
+	// used by MergeKey
+	ArrayKey string
+	// KeepFirst keeps the earlier value of a conflict
+	KeepFirst bool
+	// OnConflict, if set, is called for each conflict
+	OnConflict func(*JSONMergeConflict)
+}
+
+// Check makes sure the array strategy is known
+func (m *JSONMerger) Check() error {
+	switch m.Arrays {
+	case "", MergeReplace, MergeConcat, MergeUnion, MergeIndex:
+	case MergeKey:
+		if m.ArrayKey == "" {
+			return fmt.Errorf("merging arrays by key requires an array key")
+		}
+	default:
+		return fmt.Errorf("unknown array strategy %q, expected replace, concat, union, index or key", m.Arrays)
+	}
+	return nil
+}
+
+// Merge returns the result of merging src into dst, neither is changed
+func (m *JSONMerger) Merge(dst interface{}, src interface{}) interface{} {
+	return m.merge([]string{}, jsonCopy(dst), jsonCopy(src))
+}
+
+// merge combines a and b found at path, they may be changed
+func (m *JSONMerger) merge(path []string, a interface{}, b interface{}) interface{} {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		if y, ok := b.(map[string]interface{}); ok {
+			for key, val := range y {
+				if old, ok := x[key]; ok {
+					x[key] = m.merge(child(path, key), old, val)
+				} else {
+					x[key] = val
+				}
+			}
+			return x
+		}
+	case []interface{}:
+		if y, ok := b.([]interface{}); ok {
+			switch m.Arrays {
+			case MergeConcat:
+				return append(x, y...)
+			case MergeUnion:
+				return m.union(x, y)
+			case MergeIndex:
+				for i, val := range y {
+					if i < len(x) {
+						x[i] = m.merge(child(path, strconv.Itoa(i)), x[i], val)
+					} else {
+						x = append(x, val)
+					}
+				}
+				return x
+			case MergeKey:
+				return m.mergeKeyed(path, x, y)
+			}
+		}
+	}
+	if jsonEqual(a, b) {
+		return a
+	}
+	conflict := &JSONMergeConflict{Path: JSONPointer(path), Old: a, New: b, Kept: "new"}
+	if m.KeepFirst {
+		conflict.Kept = "old"
+	}
+	if m.OnConflict != nil {
+		m.OnConflict(conflict)
+	}
+	if m.KeepFirst {
+		return a
+	}
+	return b
+}
+
+// union appends the elements of b not found in a
+func (m *JSONMerger) union(a []interface{}, b []interface{}) []interface{} {
+	for _, val := range b {
+		found := false
+		for _, elem := range a {
+			if jsonEqual(elem, val) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, val)
+		}
+	}
+	return a
+}
+
+// arrayKey returns the identity of an element, false if the element
+// isn't an object with a scalar value for ArrayKey
+func (m *JSONMerger) arrayKey(elem interface{}) (string, bool) {
+	obj, ok := elem.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	val, ok := obj[m.ArrayKey]
+	if !ok {
+		return "", false
+	}
+	switch val.(type) {
+	case map[string]interface{}, []interface{}, nil:
+		return "", false
+	}
+	src, err := JSONMarshal(val)
+	return string(src), err == nil
+}
+
+// mergeKeyed merges the elements of b into the element of a with the
+// same identity, other elements of b are appended (unless an equal
+// element is already present).
+func (m *JSONMerger) mergeKeyed(path []string, a []interface{}, b []interface{}) []interface{} {
+	index := map[string]int{}
+	for i, elem := range a {
+		if key, ok := m.arrayKey(elem); ok {
+			if _, seen := index[key]; !seen {
+				index[key] = i
+			}
+		}
+	}
+	for _, val := range b {
+		key, ok := m.arrayKey(val)
+		if i, found := index[key]; ok && found {
+			a[i] = m.merge(child(path, strconv.Itoa(i)), a[i], val)
+			continue
+		}
+		if ok {
+			index[key] = len(a)
+			a = append(a, val)
+		} else {
+			a = m.union(a, []interface{}{val})
+		}
+	}
+	return a
+}
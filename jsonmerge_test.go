@@ -0,0 +1,64 @@
+package datatools
+
+import (
+	"testing"
+)
+
+func TestJSONMerger(t *testing.T) {
+	a := `{"id": "r1", "title": "Old", "meta": {"lang": "en", "tags": ["a", "b"]}, "authors": [{"id": 1, "name": "Doe"}, {"id": 2}]}`
+	b := `{"title": "New", "meta": {"pages": 10, "tags": ["b", "c"]}, "authors": [{"id": 2, "name": "Roe"}, {"id": 3}]}`
+	tests := []struct {
+		merger    *JSONMerger
+		expected  string
+		conflicts int
+	}{
+		{&JSONMerger{}, `{"authors":[{"id":2,"name":"Roe"},{"id":3}],"id":"r1","meta":{"lang":"en","pages":10,"tags":["b","c"]},"title":"New"}`, 3},
+		{&JSONMerger{KeepFirst: true}, `{"authors":[{"id":1,"name":"Doe"},{"id":2}],"id":"r1","meta":{"lang":"en","pages":10,"tags":["a","b"]},"title":"Old"}`, 3},
+		{&JSONMerger{Arrays: MergeConcat}, `{"authors":[{"id":1,"name":"Doe"},{"id":2},{"id":2,"name":"Roe"},{"id":3}],"id":"r1","meta":{"lang":"en","pages":10,"tags":["a","b","b","c"]},"title":"New"}`, 1},
+		{&JSONMerger{Arrays: MergeUnion}, `{"authors":[{"id":1,"name":"Doe"},{"id":2},{"id":2,"name":"Roe"},{"id":3}],"id":"r1","meta":{"lang":"en","pages":10,"tags":["a","b","c"]},"title":"New"}`, 1},
+		{&JSONMerger{Arrays: MergeIndex}, `{"authors":[{"id":2,"name":"Roe"},{"id":3}],"id":"r1","meta":{"lang":"en","pages":10,"tags":["b","c"]},"title":"New"}`, 6},
+		{&JSONMerger{Arrays: MergeKey, ArrayKey: "id"}, `{"authors":[{"id":1,"name":"Doe"},{"id":2,"name":"Roe"},{"id":3}],"id":"r1","meta":{"lang":"en","pages":10,"tags":["a","b","c"]},"title":"New"}`, 1},
+	}
+	for i, test := range tests {
+		var x, y interface{}
+		JSONUnmarshal([]byte(a), &x)
+		JSONUnmarshal([]byte(b), &y)
+		if err := test.merger.Check(); err != nil {
+			t.Errorf("test %d, %s", i, err)
+			continue
+		}
+		conflicts := []*JSONMergeConflict{}
+		test.merger.OnConflict = func(conflict *JSONMergeConflict) {
+			conflicts = append(conflicts, conflict)
+		}
+		src, _ := JSONMarshal(test.merger.Merge(x, y))
+		if string(src) != test.expected {
+			t.Errorf("test %d expected %s, got %s", i, test.expected, src)
+		}
+		if len(conflicts) != test.conflicts {
+			t.Errorf("test %d expected %d conflicts, got %d", i, test.conflicts, len(conflicts))
+		}
+		if src, _ := JSONMarshal(x); string(src) == test.expected {
+			t.Errorf("test %d changed the document merged into", i)
+		}
+	}
+
+	// The conflict over the title is reported with its path
+	conflicts := []*JSONMergeConflict{}
+	merger := &JSONMerger{OnConflict: func(conflict *JSONMergeConflict) {
+		conflicts = append(conflicts, conflict)
+	}}
+	var x, y interface{}
+	JSONUnmarshal([]byte(`{"a": {"b/c": 1, "d": 2.0}}`), &x)
+	JSONUnmarshal([]byte(`{"a": {"b/c": "1", "d": 2}}`), &y)
+	merger.Merge(x, y)
+	if len(conflicts) != 1 || conflicts[0].Path != "/a/b~1c" || conflicts[0].Kept != "new" {
+		t.Errorf("expected one conflict at /a/b~1c, got %+v", conflicts)
+	}
+
+	for _, m := range []*JSONMerger{{Arrays: "zip"}, {Arrays: MergeKey}} {
+		if err := m.Check(); err == nil {
+			t.Errorf("expected an error for %+v", m)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+// jsonmodify provides a small prefix expression language for creating,
+// updating and deleting the values found at a dotpath in a JSON document.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package jsonmodify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/datatools/lexer"
+	"github.com/caltechlibrary/dotpath"
+)
+
+const (
+	// Version of this package
+	Version = "v0.0.1"
+)
+
+var (
+	// arity holds the minimum and maximum number of arguments of each
+	// operation and function, a maximum of -1 is unlimited.
+	arity = map[string][2]int{
+		"create": {2, 2},
+		"update": {2, 2},
+		"set":    {2, 2},
+		"insert": {2, 2},
+		"append": {2, 2},
+		"delete": {1, 1},
+		"join":   {2, 2},
+		"split":  {2, 2},
+		"concat": {2, -1},
+	}
+
+	// operations change the document, the first argument is the dotpath
+	// changed. join and split used as an operation replace the value at
+	// their dotpath.
+	operations = map[string]bool{
+		"create": true,
+		"update": true,
+		"set":    true,
+		"insert": true,
+		"append": true,
+		"delete": true,
+		"join":   true,
+		"split":  true,
+	}
+)
+
+// node is a literal value, a dotpath or a function call in a parsed
+// expression
+type node struct {
+	fn    string
+	args  []*node
+	path  string
+	value interface{}
+}
+
+// Modifier is a parsed sequence of operations
+type Modifier struct {
+	src string
+	ops []*node
+}
+
+// parseAtom turns a token into a literal or a dotpath
+func parseAtom(token string) (*node, error) {
+	switch {
+	case strings.HasPrefix(token, "."):
+		if _, err := parsePath(token); err != nil {
+			return nil, err
+		}
+		return &node{path: token}, nil
+	case strings.HasPrefix(token, `"`):
+		s, err := strconv.Unquote(token)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s", token, err)
+		}
+		return &node{value: s}, nil
+	case token == "true" || token == "false":
+		return &node{value: token == "true"}, nil
+	case token == "null":
+		return &node{value: nil}, nil
+	case strings.HasPrefix(token, "{") || strings.HasPrefix(token, "["):
+		val, err := decode([]byte(token))
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s", token, err)
+		}
+		return &node{value: val}, nil
+	}
+	if _, err := strconv.ParseFloat(token, 64); err == nil {
+		return &node{value: json.Number(token)}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q, strings must be in double quotes", token)
+}
+
+// parse reads an expression starting at tokens[pos] returning it and
+// the position of the next token.
+func parse(tokens []string, pos int) (*node, int, error) {
+	if pos >= len(tokens) {
+		return nil, pos, fmt.Errorf("unexpected end of expression")
+	}
+	switch tokens[pos] {
+	case ")":
+		return nil, pos, fmt.Errorf("unexpected )")
+	case "(":
+	default:
+		n, err := parseAtom(tokens[pos])
+		return n, pos + 1, err
+	}
+	pos++
+	if pos >= len(tokens) {
+		return nil, pos, fmt.Errorf("unexpected end of expression")
+	}
+	n := &node{fn: tokens[pos]}
+	limits, ok := arity[n.fn]
+	if !ok {
+		return nil, pos, fmt.Errorf("unknown operation %q", n.fn)
+	}
+	for pos++; pos < len(tokens) && tokens[pos] != ")"; {
+		var (
+			arg *node
+			err error
+		)
+		arg, pos, err = parse(tokens, pos)
+		if err != nil {
+			return nil, pos, err
+		}
+		n.args = append(n.args, arg)
+	}
+	if pos >= len(tokens) {
+		return nil, pos, fmt.Errorf("(%s is missing a )", n.fn)
+	}
+	if len(n.args) < limits[0] || (limits[1] >= 0 && len(n.args) > limits[1]) {
+		return nil, pos, fmt.Errorf("(%s ...) has the wrong number of arguments", n.fn)
+	}
+	return n, pos + 1, nil
+}
+
+// Parse parses one or more operations. Operations use a prefix
+// notation, e.g. (create .name (concat .family .given ", ")).
+//
+// (create DOTPATH VALUE) adds a value that must not already exist,
+// (update DOTPATH VALUE) replaces a value that must exist and
+// (set DOTPATH VALUE) does either. Missing objects (or arrays, when the
+// next step of the dotpath is a number) along the dotpath are created.
+// (insert DOTPATH VALUE) inserts into an array before the index ending
+// the dotpath, (append DOTPATH VALUE) adds to the end of an array and
+// (delete DOTPATH) removes a value.
+//
+// Values are double quoted strings, numbers, true, false, null, JSON
+// objects or arrays, a dotpath (copying the value found there) or one
+// of the functions (join VALUE SEP) which joins an array into a string,
+// (split VALUE SEP) which splits a string into an array and
+// (concat VALUE... SEP) which joins strings and numbers. Used as an
+// operation (join DOTPATH SEP) and (split DOTPATH SEP) replace the
+// value at DOTPATH.
+func Parse(src string) (*Modifier, error) {
+	tokens, err := lexer.Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	m := &Modifier{src: src}
+	for pos := 0; pos < len(tokens); {
+		var op *node
+		op, pos, err = parse(tokens, pos)
+		if err != nil {
+			return nil, err
+		}
+		if !operations[op.fn] {
+			if op.fn == "" {
+				return nil, fmt.Errorf("expected an operation, got %v", op.value)
+			}
+			return nil, fmt.Errorf("(%s ...) can only be used as a value", op.fn)
+		}
+		if op.args[0].path == "" {
+			return nil, fmt.Errorf("(%s ...) expects a dotpath as its first argument", op.fn)
+		}
+		m.ops = append(m.ops, op)
+	}
+	if len(m.ops) == 0 {
+		return nil, fmt.Errorf("no operations")
+	}
+	return m, nil
+}
+
+// String returns the source of the operations
+func (m *Modifier) String() string {
+	return m.src
+}
+
+// Apply runs the operations in order against data, a value decoded
+// from JSON, returning the modified value. data may be changed in place.
+func (m *Modifier) Apply(data interface{}) (interface{}, error) {
+	var err error
+	for _, op := range m.ops {
+		path := op.args[0].path
+		var val interface{}
+		if len(op.args) > 1 {
+			if val, err = eval(op.args[1], data); err != nil {
+				return nil, fmt.Errorf("(%s %s ...) %s", op.fn, path, err)
+			}
+		}
+		switch op.fn {
+		case "create":
+			data, err = Create(data, path, val)
+		case "update":
+			data, err = Update(data, path, val)
+		case "set":
+			data, err = Set(data, path, val)
+		case "insert":
+			data, err = Insert(data, path, val)
+		case "append":
+			data, err = Append(data, path, val)
+		case "delete":
+			data, err = Delete(data, path)
+		case "join", "split":
+			// Used as an operation they replace the value at the dotpath
+			if val, err = eval(op, data); err == nil {
+				data, err = Update(data, path, val)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("(%s %s ...) %s", op.fn, path, err)
+		}
+	}
+	return data, nil
+}
+
+// eval returns the value of a literal, dotpath or function
+func eval(n *node, data interface{}) (interface{}, error) {
+	switch {
+	case n.path != "":
+		val, err := Get(data, n.path)
+		if err != nil {
+			return nil, err
+		}
+		return deepCopy(val), nil
+	case n.fn == "":
+		return deepCopy(n.value), nil
+	}
+	args := []interface{}{}
+	for _, arg := range n.args {
+		val, err := eval(arg, data)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+	}
+	sep, ok := args[len(args)-1].(string)
+	if !ok {
+		return nil, fmt.Errorf("(%s ...) expects a string separator", n.fn)
+	}
+	switch n.fn {
+	case "join":
+		a, ok := args[0].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("(join ...) expects an array, got %T", args[0])
+		}
+		parts := []string{}
+		for _, v := range a {
+			parts = append(parts, toString(v))
+		}
+		return strings.Join(parts, sep), nil
+	case "split":
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("(split ...) expects a string, got %T", args[0])
+		}
+		a := []interface{}{}
+		for _, part := range strings.Split(s, sep) {
+			a = append(a, part)
+		}
+		return a, nil
+	case "concat":
+		parts := []string{}
+		for _, v := range args[:len(args)-1] {
+			switch v.(type) {
+			case string, json.Number, float64, int, int64:
+				parts = append(parts, toString(v))
+			default:
+				return nil, fmt.Errorf("(concat ...) expects strings or numbers, got %T", v)
+			}
+		}
+		return strings.Join(parts, sep), nil
+	}
+	return nil, fmt.Errorf("unknown function %q", n.fn)
+}
+
+// toString renders a value as text, objects and arrays as JSON
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		src, _ := json.Marshal(v)
+		return string(src)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// decode parses JSON keeping numbers as json.Number
+func decode(src []byte) (interface{}, error) {
+	var val interface{}
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	if err := dec.Decode(&val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// deepCopy copies maps and arrays so values copied from one dotpath
+// to another don't share storage
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = deepCopy(val)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, val := range v {
+			a[i] = deepCopy(val)
+		}
+		return a
+	}
+	return v
+}
+
+// parsePath splits a dotpath into keys the same way as dotpath.Eval,
+// "." is the document itself and has no keys.
+func parsePath(p string) ([]string, error) {
+	if p == "." {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(p, ".") {
+		return nil, fmt.Errorf("%q is an invalid dot path", p)
+	}
+	keys := strings.FieldsFunc(p, func(c rune) bool {
+		return c == '.' || c == '[' || c == ']' || c == '"'
+	})
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%q is an invalid dot path", p)
+	}
+	return keys, nil
+}
+
+// Get returns the value found at a dotpath, "." is the whole document
+func Get(data interface{}, path string) (interface{}, error) {
+	if path == "." {
+		return data, nil
+	}
+	return dotpath.Eval(path, data)
+}
+
+// Create adds value at path, it is an error if a value is already there
+func Create(data interface{}, path string, value interface{}) (interface{}, error) {
+	return modify(data, path, "create", value)
+}
+
+// Update replaces the value at path, it is an error if there isn't one
+func Update(data interface{}, path string, value interface{}) (interface{}, error) {
+	return modify(data, path, "update", value)
+}
+
+// Set creates or replaces the value at path
+func Set(data interface{}, path string, value interface{}) (interface{}, error) {
+	return modify(data, path, "set", value)
+}
+
+// Insert puts value into an array before the index ending path,
+// e.g. .keywords[0] inserts at the start of the keywords array
+func Insert(data interface{}, path string, value interface{}) (interface{}, error) {
+	return modify(data, path, "insert", value)
+}
+
+// Append adds value to the end of the array at path, creating the
+// array if needed
+func Append(data interface{}, path string, value interface{}) (interface{}, error) {
+	return modify(data, path, "append", value)
+}
+
+// Delete removes the value at path, array elements after it move down
+func Delete(data interface{}, path string) (interface{}, error) {
+	return modify(data, path, "delete", nil)
+}
+
+// modify parses path and changes data returning the new document
+func modify(data interface{}, path string, mode string, value interface{}) (interface{}, error) {
+	keys, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if mode == "append" {
+		// Appending is setting the element one past the end
+		cur, err := Get(data, path)
+		if err != nil {
+			cur = []interface{}{}
+		}
+		a, ok := cur.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is not an array", path)
+		}
+		return modify(data, path, "set", append(a, value))
+	}
+	if len(keys) == 0 {
+		switch mode {
+		case "update", "set":
+			return value, nil
+		case "delete":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can't %s the whole document", mode)
+	}
+	return modifyNode(data, keys, mode, value)
+}
+
+// modifyNode walks keys from node changing the value at the end
+func modifyNode(node interface{}, keys []string, mode string, value interface{}) (interface{}, error) {
+	key, last := keys[0], len(keys) == 1
+	switch n := node.(type) {
+	case map[string]interface{}:
+		cur, exists := n[key]
+		if !last {
+			if !exists {
+				if mode == "update" || mode == "delete" {
+					return nil, fmt.Errorf("%q not found", key)
+				}
+				cur = newContainer(keys[1])
+			}
+			child, err := modifyNode(cur, keys[1:], mode, value)
+			if err != nil {
+				return nil, err
+			}
+			n[key] = child
+			return n, nil
+		}
+		switch {
+		case mode == "create" && exists:
+			return nil, fmt.Errorf("%q already exists", key)
+		case (mode == "update" || mode == "delete") && !exists:
+			return nil, fmt.Errorf("%q not found", key)
+		case mode == "insert":
+			return nil, fmt.Errorf("%q is not an array index", key)
+		case mode == "delete":
+			delete(n, key)
+		default:
+			n[key] = value
+		}
+		return n, nil
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse array index %q", key)
+		}
+		exists := i >= 0 && i < len(n)
+		if !last {
+			if i == len(n) && (mode == "create" || mode == "set" || mode == "insert") {
+				n = append(n, newContainer(keys[1]))
+			} else if !exists {
+				return nil, fmt.Errorf("index %d is out of bounds", i)
+			}
+			child, err := modifyNode(n[i], keys[1:], mode, value)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = child
+			return n, nil
+		}
+		switch {
+		case mode == "insert":
+			if i < 0 || i > len(n) {
+				return nil, fmt.Errorf("index %d is out of bounds", i)
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = value
+		case i == len(n) && (mode == "create" || mode == "set"):
+			n = append(n, value)
+		case !exists:
+			return nil, fmt.Errorf("index %d is out of bounds", i)
+		case mode == "create":
+			return nil, fmt.Errorf("index %d already exists", i)
+		case mode == "delete":
+			n = append(n[:i], n[i+1:]...)
+		default:
+			n[i] = value
+		}
+		return n, nil
+	case nil:
+		if mode == "update" || mode == "delete" {
+			return nil, fmt.Errorf("%q not found", key)
+		}
+		return modifyNode(newContainer(key), keys, mode, value)
+	}
+	return nil, fmt.Errorf("can't use key %q with a value of type %T", key, node)
+}
+
+// newContainer returns an empty array if key is an array index,
+// otherwise an empty object
+func newContainer(key string) interface{} {
+	if _, err := strconv.Atoi(key); err == nil {
+		return []interface{}{}
+	}
+	return map[string]interface{}{}
+}
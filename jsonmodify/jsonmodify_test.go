@@ -0,0 +1,104 @@
+// jsonmodify provides a small prefix expression language for creating,
+// updating and deleting the values found at a dotpath in a JSON document.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package jsonmodify
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, src := range []string{
+		`(set .title "Datatools")`,
+		`(create .keywords ["csv", "json"]) (append .keywords "xlsx")`,
+		`(update .name (concat .family .given ", "))`,
+		`(insert .authors[0] {"name": "Doiel, R. S."})`,
+		`(delete .version)`,
+		`(join .keywords "; ")`,
+		`(set .words (split .title " "))`,
+	} {
+		if _, err := Parse(src); err != nil {
+			t.Errorf("expected (%s) no errors, got %s", src, err)
+		}
+	}
+	for _, src := range []string{
+		``,
+		`(set .title "x"`,
+		`(set .title)`,
+		`(set title "x")`,
+		`(set .title x)`,
+		`(concat .a .b ",")`,
+		`(delete .a .b)`,
+		`(replace .a "x")`,
+		`(set .a {"x": 1)`,
+		`"set"`,
+		`()`,
+	} {
+		if m, err := Parse(src); err == nil {
+			t.Errorf("expected (%s) an error, got %+v", src, m)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	src := `{"title": "Datatools", "family": "Doiel", "given": "Robert", "keywords": ["csv", "json"], "version": "1.0.0"}`
+	tests := map[string]string{
+		`(set .title "dt")`: `{"family":"Doiel","given":"Robert","keywords":["csv","json"],"title":"dt","version":"1.0.0"}`,
+		`(create .name (concat .family .given ", ")) (delete .family) (delete .given)`:                                                        `{"keywords":["csv","json"],"name":"Doiel, Robert","title":"Datatools","version":"1.0.0"}`,
+		`(append .keywords "xlsx") (insert .keywords[0] "tsv") (delete .keywords[1])`:                                                         `{"family":"Doiel","given":"Robert","keywords":["tsv","json","xlsx"],"title":"Datatools","version":"1.0.0"}`,
+		`(join .keywords ";") (delete .family) (delete .given) (delete .title)`:                                                               `{"keywords":"csv;json","version":"1.0.0"}`,
+		`(set .authors[0].name .family) (set .count 2) (delete .family) (delete .given) (delete .keywords) (delete .title) (delete .version)`: `{"authors":[{"name":"Doiel"}],"count":2}`,
+		`(set .copy .keywords) (append .copy "x") (set .version null) (delete .family) (delete .given) (delete .title)`:                       `{"copy":["csv","json","x"],"keywords":["csv","json"],"version":null}`,
+	}
+	for expr, expected := range tests {
+		m, err := Parse(expr)
+		if err != nil {
+			t.Errorf("%s, %s", expr, err)
+			continue
+		}
+		data, _ := decode([]byte(src))
+		data, err = m.Apply(data)
+		if err != nil {
+			t.Errorf("%s, %s", expr, err)
+			continue
+		}
+		out, _ := json.Marshal(data)
+		if string(out) != expected {
+			t.Errorf("%s, expected %s, got %s", expr, expected, out)
+		}
+	}
+	for _, expr := range []string{
+		`(create .title "x")`,
+		`(update .missing "x")`,
+		`(delete .missing)`,
+		`(insert .keywords[5] "x")`,
+		`(append .title "x")`,
+		`(join .title ",")`,
+		`(set .title.x 1)`,
+	} {
+		m, err := Parse(expr)
+		if err != nil {
+			t.Errorf("%s, %s", expr, err)
+			continue
+		}
+		data, _ := decode([]byte(src))
+		if _, err := m.Apply(data); err == nil {
+			t.Errorf("expected an error for %s", expr)
+		}
+	}
+}
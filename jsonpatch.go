@@ -0,0 +1,508 @@
+// jsonpatch.go implements JSON Pointer (RFC 6901), JSON Patch (RFC 6902)
+// and JSON Merge Patch (RFC 7396) for documents decoded from JSON.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// JSONPatchOperation is one operation of a JSON Patch (RFC 6902)
+type JSONPatchOperation struct {
+	// Op is one of "add", "remove", "replace", "move", "copy" or "test"
+	Op string `json:"op"`
+	// Path is a JSON Pointer to the value the operation targets
+	Path string `json:"path"`
+	// From is a JSON Pointer to the source of a move or copy
+	From string `json:"from,omitempty"`
+	// Value used by add, replace and test
+	Value interface{} `json:"value,omitempty"`
+
+	// hasValue is true when the operation included a value, it may be null
+	hasValue bool
+	// hasFrom is true when the operation included from, it may be ""
+	hasFrom bool
+}
+
+// JSONPatchError describes a patch operation which could not be applied
+type JSONPatchError struct {
+	// Index of the operation in the patch counting from zero
+	Index int `json:"index"`
+	// Op, Path and From of the failed operation
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	// Message explains the failure
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e *JSONPatchError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s) %s", e.Index, e.Op, e.Path, e.Message)
+}
+
+// UnmarshalJSON notes if an operation's value was present so
+// "value": null can be told apart from a missing value.
+func (op *JSONPatchOperation) UnmarshalJSON(src []byte) error {
+	m := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &m); err != nil {
+		return err
+	}
+	for _, key := range []string{"op", "path", "from"} {
+		if val, ok := m[key]; ok {
+			s, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("%q must be a string", key)
+			}
+			switch key {
+			case "op":
+				op.Op = s
+			case "path":
+				op.Path = s
+			case "from":
+				op.From, op.hasFrom = s, true
+			}
+		}
+	}
+	op.Value, op.hasValue = m["value"]
+	return nil
+}
+
+// MarshalJSON writes the members in the order op, from, path and value,
+// the value of add, replace and test is written even when it is null.
+func (op *JSONPatchOperation) MarshalJSON() ([]byte, error) {
+	obj := struct {
+		Op    string       `json:"op"`
+		From  *string      `json:"from,omitempty"`
+		Path  string       `json:"path"`
+		Value *interface{} `json:"value,omitempty"`
+	}{Op: op.Op, Path: op.Path}
+	if op.hasFrom || op.From != "" {
+		obj.From = &op.From
+	}
+	if op.hasValue || op.Value != nil || op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+		obj.Value = &op.Value
+	}
+	return JSONMarshal(obj)
+}
+
+// NewJSONPatchOperation returns an operation, value is ignored by
+// remove, move and copy and from is ignored by add, remove, replace
+// and test.
+func NewJSONPatchOperation(op string, path string, from string, value interface{}) *JSONPatchOperation {
+	patchOp := &JSONPatchOperation{Op: op, Path: path}
+	switch op {
+	case "move", "copy":
+		patchOp.From, patchOp.hasFrom = from, true
+	case "add", "replace", "test":
+		patchOp.Value, patchOp.hasValue = value, true
+	}
+	return patchOp
+}
+
+// ParseJSONPatch decodes a JSON Patch, an array of operations, and
+// checks each operation has the members it needs.
+func ParseJSONPatch(src []byte) ([]*JSONPatchOperation, error) {
+	patch := []*JSONPatchOperation{}
+	if strings.HasPrefix(strings.TrimSpace(string(src)), "{") {
+		return nil, fmt.Errorf("a JSON Patch is an array of operations, not an object")
+	}
+	if err := JSONUnmarshal(src, &patch); err != nil {
+		return nil, err
+	}
+	for i, op := range patch {
+		if err := op.check(); err != nil {
+			return nil, &JSONPatchError{Index: i, Op: op.Op, Path: op.Path, From: op.From, Message: err.Error()}
+		}
+	}
+	return patch, nil
+}
+
+// check validates the members of an operation
+func (op *JSONPatchOperation) check() error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if !op.hasValue && op.Value == nil {
+			return fmt.Errorf("missing value")
+		}
+	case "move", "copy":
+		if !op.hasFrom && op.From == "" {
+			return fmt.Errorf("missing from")
+		}
+		if _, err := ParseJSONPointer(op.From); err != nil {
+			return fmt.Errorf("from %s", err)
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	_, err := ParseJSONPointer(op.Path)
+	return err
+}
+
+// ApplyJSONPatch applies the operations of a JSON Patch in order and
+// returns the patched document. The patch is atomic, if an operation
+// fails the error is a *JSONPatchError and doc is left unchanged.
+func ApplyJSONPatch(doc interface{}, patch []*JSONPatchOperation) (interface{}, error) {
+	var err error
+	doc = jsonCopy(doc)
+	for i, op := range patch {
+		if doc, err = op.apply(doc); err != nil {
+			return nil, &JSONPatchError{Index: i, Op: op.Op, Path: op.Path, From: op.From, Message: err.Error()}
+		}
+	}
+	return doc, nil
+}
+
+// CheckJSONPatch reports the operations of a JSON Patch which would
+// fail without changing doc. Operations are tried in order against a
+// copy of doc, later operations see the changes of the earlier ones
+// which succeeded.
+func CheckJSONPatch(doc interface{}, patch []*JSONPatchOperation) []*JSONPatchError {
+	errs := []*JSONPatchError{}
+	doc = jsonCopy(doc)
+	for i, op := range patch {
+		patched, err := op.apply(jsonCopy(doc))
+		if err != nil {
+			errs = append(errs, &JSONPatchError{Index: i, Op: op.Op, Path: op.Path, From: op.From, Message: err.Error()})
+			continue
+		}
+		doc = patched
+	}
+	return errs
+}
+
+// apply performs the operation returning the new document
+func (op *JSONPatchOperation) apply(doc interface{}) (interface{}, error) {
+	if err := op.check(); err != nil {
+		return nil, err
+	}
+	path, _ := ParseJSONPointer(op.Path)
+	switch op.Op {
+	case "add":
+		return pointerAdd(doc, path, jsonCopy(op.Value))
+	case "remove":
+		doc, _, err := pointerRemove(doc, path)
+		return doc, err
+	case "replace":
+		if _, err := pointerGet(doc, path); err != nil {
+			return nil, err
+		}
+		doc, _, err := pointerRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, jsonCopy(op.Value))
+	case "move":
+		from, _ := ParseJSONPointer(op.From)
+		if len(from) < len(path) && JSONPointer(path[:len(from)]) == op.From {
+			return nil, fmt.Errorf("can't move %s into one of its children", op.From)
+		}
+		doc, val, err := pointerRemove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, val)
+	case "copy":
+		from, _ := ParseJSONPointer(op.From)
+		val, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, jsonCopy(val))
+	case "test":
+		val, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(val, op.Value) {
+			return nil, fmt.Errorf("test failed, value is not equal")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7396) returning the
+// patched document. Members of patch objects set to null are removed,
+// other values (including arrays) replace the target's value. doc is
+// left unchanged.
+func ApplyMergePatch(doc interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return jsonCopy(patch)
+	}
+	target, ok := jsonCopy(doc).(map[string]interface{})
+	if !ok {
+		target = map[string]interface{}{}
+	}
+	for key, val := range p {
+		if val == nil {
+			delete(target, key)
+		} else {
+			target[key] = ApplyMergePatch(target[key], val)
+		}
+	}
+	return target
+}
+
+// ParseJSONPointer splits a JSON Pointer (RFC 6901) into its unescaped
+// reference tokens. The empty string points at the whole document.
+func ParseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%q is not a JSON Pointer, it must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("%q has an invalid escape", pointer)
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// JSONPointer escapes reference tokens and joins them into a JSON
+// Pointer, it is the inverse of ParseJSONPointer.
+func JSONPointer(tokens []string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString("/")
+		sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return sb.String()
+}
+
+// JSONPointerEval returns the value in doc a JSON Pointer refers to
+func JSONPointerEval(pointer string, doc interface{}) (interface{}, error) {
+	tokens, err := ParseJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return pointerGet(doc, tokens)
+}
+
+// arrayIndex parses a reference token as an index into an array of
+// length n. When end is true "-" (and n) refer to the end of the array.
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if token == "-" && end {
+		return n, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i > n || (i == n && !end) {
+		return 0, fmt.Errorf("array index %s is out of bounds", token)
+	}
+	return i, nil
+}
+
+// pointerGet returns the value at the reference tokens
+func pointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	for depth, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			val, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", JSONPointer(tokens[:depth+1]))
+			}
+			doc = val
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%s not found", JSONPointer(tokens[:depth+1]))
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds (or for objects replaces) the value at the reference
+// tokens, arrays insert the value at the index.
+func pointerAdd(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parent, err := pointerGet(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[token] = value
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node, nil)
+		copy(node[i+1:], node[i:])
+		node[i] = value
+		return pointerSet(doc, tokens[:len(tokens)-1], node)
+	}
+	return nil, fmt.Errorf("%s is not an object or array", JSONPointer(tokens[:len(tokens)-1]))
+}
+
+// pointerRemove removes the value at the reference tokens returning
+// the new document and the value removed.
+func pointerRemove(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := pointerGet(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	token := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		val, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s not found", JSONPointer(tokens))
+		}
+		delete(node, token)
+		return doc, val, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		val := node[i]
+		node = append(node[:i:i], node[i+1:]...)
+		doc, err = pointerSet(doc, tokens[:len(tokens)-1], node)
+		return doc, val, err
+	}
+	return nil, nil, fmt.Errorf("%s not found", JSONPointer(tokens))
+}
+
+// pointerSet replaces an existing value, it is used to store arrays
+// which changed length.
+func pointerSet(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parent, err := pointerGet(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[token] = value
+	case []interface{}:
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = value
+	}
+	return doc, nil
+}
+
+// jsonCopy deep copies the objects and arrays of a decoded JSON value
+func jsonCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = jsonCopy(val)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, val := range v {
+			a[i] = jsonCopy(val)
+		}
+		return a
+	}
+	return v
+}
+
+// jsonNumber returns the value of a number as a big.Rat so 1, 1.0
+// and 1e0 compare as equal.
+func jsonNumber(v interface{}) (*big.Rat, bool) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = v.String()
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case int:
+		s = strconv.Itoa(v)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	default:
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// jsonEqual compares decoded JSON values, object key order is ignored
+// and numbers are compared by value.
+func jsonEqual(a interface{}, b interface{}) bool {
+	if x, ok := jsonNumber(a); ok {
+		y, ok := jsonNumber(b)
+		return ok && x.Cmp(y) == 0
+	}
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, val := range a {
+			other, ok := b[key]
+			if !ok || !jsonEqual(val, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case nil:
+		return b == nil
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package datatools
+
+import (
+	"testing"
+)
+
+func TestJSONPointer(t *testing.T) {
+	src := `{"foo": ["bar", "baz"], "": 0, "a/b": 1, "c%d": 2, "m~n": 8, " ": 7}`
+	var doc interface{}
+	if err := JSONUnmarshal([]byte(src), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"/foo/0": `"bar"`,
+		"/":      `0`,
+		"/a~1b":  `1`,
+		"/c%d":   `2`,
+		"/m~0n":  `8`,
+		"/ ":     `7`,
+		"/foo":   `["bar","baz"]`,
+	}
+	for pointer, expected := range tests {
+		val, err := JSONPointerEval(pointer, doc)
+		if err != nil {
+			t.Errorf("%q, %s", pointer, err)
+			continue
+		}
+		got, _ := JSONMarshal(val)
+		if string(got) != expected {
+			t.Errorf("%q expected %s, got %s", pointer, expected, got)
+		}
+		tokens, _ := ParseJSONPointer(pointer)
+		if JSONPointer(tokens) != pointer {
+			t.Errorf("expected %q, got %q", pointer, JSONPointer(tokens))
+		}
+	}
+	for _, pointer := range []string{"foo", "/foo/2", "/foo/01", "/foo/-", "/m~2n", "/missing"} {
+		if val, err := JSONPointerEval(pointer, doc); err == nil {
+			t.Errorf("%q expected an error, got %+v", pointer, val)
+		}
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`, `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz": "qux", "foo": ["a", 2, "c"]}`, `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo": "bar"}`, `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`},
+		{`{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"foo": null}`, `[{"op": "test", "path": "/foo", "value": null}, {"op": "copy", "from": "/foo", "path": "/bar"}]`, `{"bar":null,"foo":null}`},
+		{`{"foo": "bar"}`, `[{"op": "replace", "path": "", "value": [1]}]`, `[1]`},
+	}
+	for i, test := range tests {
+		var doc interface{}
+		if err := JSONUnmarshal([]byte(test.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		patch, err := ParseJSONPatch([]byte(test.patch))
+		if err != nil {
+			t.Errorf("test %d, %s", i, err)
+			continue
+		}
+		patched, err := ApplyJSONPatch(doc, patch)
+		if err != nil {
+			t.Errorf("test %d, %s", i, err)
+			continue
+		}
+		got, _ := JSONMarshal(patched)
+		if string(got) != test.expected {
+			t.Errorf("test %d expected %s, got %s", i, test.expected, got)
+		}
+		if errs := CheckJSONPatch(doc, patch); len(errs) > 0 {
+			t.Errorf("test %d expected no errors, got %s", i, errs[0])
+		}
+	}
+
+	// Operations which should fail leaving the document unchanged
+	src := `{"baz": "qux", "foo": ["a", 2, "c"]}`
+	for _, s := range []string{
+		`[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+		`[{"op": "remove", "path": "/missing"}]`,
+		`[{"op": "replace", "path": "/foo/3", "value": 1}]`,
+		`[{"op": "add", "path": "/foo/4", "value": 1}]`,
+		`[{"op": "test", "path": "/baz", "value": "bar"}]`,
+		`[{"op": "move", "from": "/foo", "path": "/foo/0"}]`,
+		`[{"op": "remove", "path": "/foo/0"}, {"op": "test", "path": "/foo/0", "value": "a"}]`,
+	} {
+		var doc interface{}
+		JSONUnmarshal([]byte(src), &doc)
+		patch, err := ParseJSONPatch([]byte(s))
+		if err != nil {
+			t.Errorf("%s, %s", s, err)
+			continue
+		}
+		if patched, err := ApplyJSONPatch(doc, patch); err == nil {
+			got, _ := JSONMarshal(patched)
+			t.Errorf("%s expected an error, got %s", s, got)
+		}
+		if errs := CheckJSONPatch(doc, patch); len(errs) != 1 {
+			t.Errorf("%s expected one failed operation, got %d", s, len(errs))
+		}
+		if got, _ := JSONMarshal(doc); string(got) != `{"baz":"qux","foo":["a",2,"c"]}` {
+			t.Errorf("%s expected the document unchanged, got %s", s, got)
+		}
+	}
+	for _, s := range []string{
+		`{"op": "add", "path": "/a", "value": 1}`,
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "rename", "path": "/a"}]`,
+		`[{"op": "copy", "path": "/a"}]`,
+		`[{"op": "remove", "path": "a"}]`,
+	} {
+		if patch, err := ParseJSONPatch([]byte(s)); err == nil {
+			t.Errorf("%s expected an error, got %+v", s, patch)
+		}
+	}	// Operations built as struct literals marshal as valid operations
+	for _, test := range []struct {
+		op       *JSONPatchOperation
+		expected string
+	}{
+		{&JSONPatchOperation{Op: "add", Path: "/a"}, `{"op":"add","path":"/a","value":null}`},
+		{&JSONPatchOperation{Op: "test", Path: "/a"}, `{"op":"test","path":"/a","value":null}`},
+		{&JSONPatchOperation{Op: "remove", Path: "/a"}, `{"op":"remove","path":"/a"}`},
+		{&JSONPatchOperation{Op: "move", From: "/a", Path: "/b"}, `{"op":"move","from":"/a","path":"/b"}`},
+	} {
+		if src, err := JSONMarshal(test.op); err != nil || string(src) != test.expected {
+			t.Errorf("expected %s, got %s, %v", test.expected, src, err)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	// Examples from RFC 7396, Appendix A
+	tests := [][3]string{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, test := range tests {
+		var doc, patch interface{}
+		JSONUnmarshal([]byte(test[0]), &doc)
+		JSONUnmarshal([]byte(test[1]), &patch)
+		got, _ := JSONMarshal(ApplyMergePatch(doc, patch))
+		if string(got) != test[2] {
+			t.Errorf("%s patched with %s expected %s, got %s", test[0], test[1], test[2], got)
+		}
+		if src, _ := JSONMarshal(doc); string(src) != test[0] {
+			t.Errorf("expected %s unchanged, got %s", test[0], src)
+		}
+	}
+}
@@ -0,0 +1,906 @@
+// jsonpath.go implements JSONPath (RFC 9535) queries, including filter
+// expressions, wildcards, slices and recursive descent.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONPath is a parsed JSONPath query, e.g. $.authors[?@.orcid].orcid
+type JSONPath struct {
+	src      string
+	segments []*jpSegment
+	// singular is true if the query can select at most one node
+	singular bool
+}
+
+// jpSegment selects the children (or with descendant all the
+// descendants) of a node matching any of its selectors
+type jpSegment struct {
+	descendant bool
+	selectors  []*jpSelector
+}
+
+const (
+	jpName = iota
+	jpWildcard
+	jpIndex
+	jpSlice
+	jpFilter
+)
+
+// jpSelector is one selector of a segment
+type jpSelector struct {
+	kind   int
+	name   string
+	index  int
+	slice  [3]*int
+	filter *jpExpr
+}
+
+// Types of a filter expression, see RFC 9535 section 2.4.1
+const (
+	jpValueType = iota
+	jpLogicalType
+	jpNodesType
+)
+
+// jpExpr is a node of a filter expression. op is one of "||", "&&",
+// "!", a comparison operator, "literal", "query" or "function".
+type jpExpr struct {
+	op       string
+	args     []*jpExpr
+	value    interface{}
+	query    *JSONPath
+	relative bool
+	fn       string
+}
+
+// jpFunctions gives the result and parameter types of the function
+// extensions defined by RFC 9535
+var jpFunctions = map[string][]int{
+	"length": {jpValueType, jpValueType},
+	"count":  {jpValueType, jpNodesType},
+	"match":  {jpLogicalType, jpValueType, jpValueType},
+	"search": {jpLogicalType, jpValueType, jpValueType},
+	"value":  {jpValueType, jpNodesType},
+}
+
+// jpParser holds the position while parsing a query
+type jpParser struct {
+	src string
+	pos int
+}
+
+// ParseJSONPath parses a JSONPath query, it must start with "$"
+func ParseJSONPath(query string) (*JSONPath, error) {
+	p := &jpParser{src: query}
+	if !p.consume("$") {
+		return nil, fmt.Errorf("%q is not a JSONPath, it must start with $", query)
+	}
+	path, err := p.segments()
+	if err != nil {
+		return nil, fmt.Errorf("%q, %s", query, err)
+	}
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("%q, unexpected %q at position %d", query, p.src[p.pos:], p.pos)
+	}
+	path.src = query
+	return path, nil
+}
+
+// JSONPathEval returns the values (the nodelist) selected by a JSONPath
+// query from a document decoded with JSONUnmarshal
+func JSONPathEval(query string, doc interface{}) ([]interface{}, error) {
+	path, err := ParseJSONPath(query)
+	if err != nil {
+		return nil, err
+	}
+	return path.Eval(doc), nil
+}
+
+// String returns the query
+func (path *JSONPath) String() string {
+	return path.src
+}
+
+// Eval returns the values selected by the query. Object members are
+// visited in key order so results are repeatable.
+func (path *JSONPath) Eval(doc interface{}) []interface{} {
+	return path.eval(doc, doc)
+}
+
+// eval applies the segments starting at node
+func (path *JSONPath) eval(root interface{}, node interface{}) []interface{} {
+	nodes := []interface{}{node}
+	for _, segment := range path.segments {
+		selected := []interface{}{}
+		for _, n := range nodes {
+			if segment.descendant {
+				for _, d := range descendants(n, nil) {
+					selected = segment.apply(root, d, selected)
+				}
+			} else {
+				selected = segment.apply(root, n, selected)
+			}
+		}
+		nodes = selected
+	}
+	return nodes
+}
+
+// descendants returns node followed by all the values it contains
+func descendants(node interface{}, nodes []interface{}) []interface{} {
+	nodes = append(nodes, node)
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(n) {
+			nodes = descendants(n[key], nodes)
+		}
+	case []interface{}:
+		for _, val := range n {
+			nodes = descendants(val, nodes)
+		}
+	}
+	return nodes
+}
+
+// sortedKeys returns the keys of an object in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// apply appends the children of node matching the selectors
+func (segment *jpSegment) apply(root interface{}, node interface{}, selected []interface{}) []interface{} {
+	for _, sel := range segment.selectors {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			switch sel.kind {
+			case jpName:
+				if val, ok := n[sel.name]; ok {
+					selected = append(selected, val)
+				}
+			case jpWildcard:
+				for _, key := range sortedKeys(n) {
+					selected = append(selected, n[key])
+				}
+			case jpFilter:
+				for _, key := range sortedKeys(n) {
+					if sel.filter.test(root, n[key]) {
+						selected = append(selected, n[key])
+					}
+				}
+			}
+		case []interface{}:
+			switch sel.kind {
+			case jpIndex:
+				i := sel.index
+				if i < 0 {
+					i += len(n)
+				}
+				if i >= 0 && i < len(n) {
+					selected = append(selected, n[i])
+				}
+			case jpWildcard:
+				selected = append(selected, n...)
+			case jpSlice:
+				for _, i := range sliceIndexes(sel.slice, len(n)) {
+					selected = append(selected, n[i])
+				}
+			case jpFilter:
+				for _, val := range n {
+					if sel.filter.test(root, val) {
+						selected = append(selected, val)
+					}
+				}
+			}
+		}
+	}
+	return selected
+}
+
+// sliceIndexes returns the indexes selected by start:end:step from an
+// array of length n, see RFC 9535 section 2.3.4.2.2
+func sliceIndexes(slice [3]*int, n int) []int {
+	step := 1
+	if slice[2] != nil {
+		step = *slice[2]
+	}
+	if step == 0 {
+		return nil
+	}
+	normalize := func(i int) int {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	clamp := func(i int, low int, high int) int {
+		if i < low {
+			return low
+		}
+		if i > high {
+			return high
+		}
+		return i
+	}
+	indexes := []int{}
+	if step > 0 {
+		start, end := 0, n
+		if slice[0] != nil {
+			start = normalize(*slice[0])
+		}
+		if slice[1] != nil {
+			end = normalize(*slice[1])
+		}
+		for i := clamp(start, 0, n); i < clamp(end, 0, n); i += step {
+			indexes = append(indexes, i)
+		}
+		return indexes
+	}
+	start, end := n-1, -n-1
+	if slice[0] != nil {
+		start = normalize(*slice[0])
+	}
+	if slice[1] != nil {
+		end = normalize(*slice[1])
+	}
+	for i := clamp(start, -1, n-1); clamp(end, -1, n-1) < i; i += step {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+//
+// Parsing
+//
+
+// consume advances past s if the query continues with it
+func (p *jpParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// peek returns the next byte or 0 at the end of the query
+func (p *jpParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// skipSpace skips blank space (space, tab, LF and CR)
+func (p *jpParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// segments parses the segments following $ or @
+func (p *jpParser) segments() (*JSONPath, error) {
+	path := &JSONPath{singular: true}
+	for {
+		start := p.pos
+		p.skipSpace()
+		if c := p.peek(); c != '.' && c != '[' {
+			p.pos = start
+			return path, nil
+		}
+		segment, err := p.segment()
+		if err != nil {
+			return nil, err
+		}
+		if segment.descendant || len(segment.selectors) != 1 ||
+			(segment.selectors[0].kind != jpName && segment.selectors[0].kind != jpIndex) {
+			path.singular = false
+		}
+		path.segments = append(path.segments, segment)
+	}
+}
+
+// segment parses .name, .*, [selectors] or a descendant segment
+func (p *jpParser) segment() (*jpSegment, error) {
+	segment := &jpSegment{}
+	if p.consume("..") {
+		segment.descendant = true
+		if p.peek() == '[' {
+			return segment, p.brackets(segment)
+		}
+	} else if !p.consume(".") {
+		return segment, p.brackets(segment)
+	}
+	if p.consume("*") {
+		segment.selectors = []*jpSelector{{kind: jpWildcard}}
+		return segment, nil
+	}
+	name := p.shorthand()
+	if name == "" {
+		return nil, fmt.Errorf("expected a member name at position %d", p.pos)
+	}
+	segment.selectors = []*jpSelector{{kind: jpName, name: name}}
+	return segment, nil
+}
+
+// shorthand reads a member name used after ".", it starts with a
+// letter, "_" or non-ASCII character and may contain digits
+func (p *jpParser) shorthand() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+// brackets parses a bracketed list of selectors
+func (p *jpParser) brackets(segment *jpSegment) error {
+	if !p.consume("[") {
+		return fmt.Errorf("expected [ at position %d", p.pos)
+	}
+	for {
+		p.skipSpace()
+		sel, err := p.selector()
+		if err != nil {
+			return err
+		}
+		segment.selectors = append(segment.selectors, sel)
+		p.skipSpace()
+		if p.consume("]") {
+			return nil
+		}
+		if !p.consume(",") {
+			return fmt.Errorf("expected , or ] at position %d", p.pos)
+		}
+	}
+}
+
+// selector parses a name, wildcard, index, slice or filter selector
+func (p *jpParser) selector() (*jpSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.stringLiteral()
+		return &jpSelector{kind: jpName, name: s}, err
+	case c == '*':
+		p.pos++
+		return &jpSelector{kind: jpWildcard}, nil
+	case c == '?':
+		p.pos++
+		p.skipSpace()
+		expr, err := p.logicalOr()
+		return &jpSelector{kind: jpFilter, filter: expr}, err
+	}
+	sel := &jpSelector{kind: jpIndex}
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			p.skipSpace()
+			if !p.consume(":") {
+				break
+			}
+			sel.kind = jpSlice
+			p.skipSpace()
+		}
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			n, err := p.integer()
+			if err != nil {
+				return nil, err
+			}
+			sel.slice[i] = &n
+		} else if i == 0 {
+			// A slice may leave out the start
+			p.skipSpace()
+			if p.peek() != ':' {
+				return nil, fmt.Errorf("expected a selector at position %d", p.pos)
+			}
+		}
+	}
+	if sel.kind == jpIndex {
+		if sel.slice[0] == nil {
+			return nil, fmt.Errorf("expected a selector at position %d", p.pos)
+		}
+		sel.index = *sel.slice[0]
+	}
+	return sel, nil
+}
+
+// integer parses an index or slice bound, RFC 9535 limits them to
+// the range of integers exactly represented by a float64
+func (p *jpParser) integer() (int, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	if p.pos == digits || (p.src[digits] == '0' && (p.pos-digits > 1 || digits > start)) {
+		return 0, fmt.Errorf("invalid integer %q at position %d", s, start)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n > 1<<53-1 || n < -(1<<53-1) {
+		return 0, fmt.Errorf("integer %s is out of range", s)
+	}
+	return int(n), nil
+}
+
+// stringLiteral parses a single or double quoted string
+func (p *jpParser) stringLiteral() (string, error) {
+	quote := p.src[p.pos]
+	start := p.pos
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c < 0x20:
+			return "", fmt.Errorf("control character in string at position %d", p.pos)
+		case c != '\\':
+			sb.WriteByte(c)
+			p.pos++
+			continue
+		}
+		p.pos++
+		if p.pos >= len(p.src) {
+			break
+		}
+		switch esc := p.src[p.pos]; esc {
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case '/', '\\', quote:
+			sb.WriteByte(esc)
+		case 'u':
+			r, err := p.unicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(r)
+			continue
+		default:
+			return "", fmt.Errorf("invalid escape \\%c at position %d", esc, p.pos)
+		}
+		p.pos++
+	}
+	return "", fmt.Errorf("unterminated string at position %d", start)
+}
+
+// unicodeEscape parses the XXXX of \uXXXX (p.pos is at the u) and a
+// following low surrogate if needed
+func (p *jpParser) unicodeEscape() (rune, error) {
+	hex := func() (rune, error) {
+		if p.pos+5 > len(p.src) {
+			return 0, fmt.Errorf("invalid unicode escape at position %d", p.pos)
+		}
+		n, err := strconv.ParseUint(p.src[p.pos+1:p.pos+5], 16, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid unicode escape at position %d", p.pos)
+		}
+		p.pos += 5
+		return rune(n), nil
+	}
+	r, err := hex()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case r >= 0xDC00 && r <= 0xDFFF:
+		return 0, fmt.Errorf("unpaired surrogate at position %d", p.pos)
+	case r >= 0xD800 && r <= 0xDBFF:
+		if !strings.HasPrefix(p.src[p.pos:], `\u`) {
+			return 0, fmt.Errorf("unpaired surrogate at position %d", p.pos)
+		}
+		p.pos++
+		low, err := hex()
+		if err != nil || low < 0xDC00 || low > 0xDFFF {
+			return 0, fmt.Errorf("unpaired surrogate at position %d", p.pos)
+		}
+		return (r-0xD800)<<10 + (low - 0xDC00) + 0x10000, nil
+	}
+	return r, nil
+}
+
+// logicalOr parses a filter expression
+func (p *jpParser) logicalOr() (*jpExpr, error) {
+	expr, err := p.logicalAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		start := p.pos
+		p.skipSpace()
+		if !p.consume("||") {
+			p.pos = start
+			return expr, nil
+		}
+		p.skipSpace()
+		right, err := p.logicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		expr = &jpExpr{op: "||", args: []*jpExpr{expr, right}}
+	}
+}
+
+// logicalAnd parses expressions joined by &&
+func (p *jpParser) logicalAnd() (*jpExpr, error) {
+	expr, err := p.basic()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		start := p.pos
+		p.skipSpace()
+		if !p.consume("&&") {
+			p.pos = start
+			return expr, nil
+		}
+		p.skipSpace()
+		right, err := p.basic()
+		if err != nil {
+			return nil, err
+		}
+		expr = &jpExpr{op: "&&", args: []*jpExpr{expr, right}}
+	}
+}
+
+// basic parses a parenthesized or negated expression, a comparison
+// or a test of a query or function
+func (p *jpParser) basic() (*jpExpr, error) {
+	if p.consume("!") {
+		p.skipSpace()
+		expr, err := p.basic()
+		if err != nil {
+			return nil, err
+		}
+		switch expr.op {
+		case "==", "!=", "<", "<=", ">", ">=":
+			return nil, fmt.Errorf("a comparison must be in parentheses to be negated at position %d", p.pos)
+		}
+		return &jpExpr{op: "!", args: []*jpExpr{expr}}, nil
+	}
+	if p.consume("(") {
+		p.skipSpace()
+		expr, err := p.logicalOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, fmt.Errorf("expected ) at position %d", p.pos)
+		}
+		// Wrap so a parenthesized comparison can be negated
+		return &jpExpr{op: "()", args: []*jpExpr{expr}}, nil
+	}
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	start := p.pos
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			p.skipSpace()
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			for _, arg := range []*jpExpr{left, right} {
+				if err := arg.comparable(); err != nil {
+					return nil, err
+				}
+			}
+			return &jpExpr{op: op, args: []*jpExpr{left, right}}, nil
+		}
+	}
+	p.pos = start
+	switch {
+	case left.op == "query":
+		return left, nil
+	case left.op == "function" && jpFunctions[left.fn][0] != jpValueType:
+		return left, nil
+	case left.op == "function":
+		return nil, fmt.Errorf("the result of %s() must be compared", left.fn)
+	}
+	return nil, fmt.Errorf("a literal must be compared at position %d", p.pos)
+}
+
+// comparable checks an expression can be used in a comparison
+func (e *jpExpr) comparable() error {
+	switch {
+	case e.op == "query" && !e.query.singular:
+		return fmt.Errorf("%s can select more than one value and can't be compared", e.query.src)
+	case e.op == "function" && jpFunctions[e.fn][0] != jpValueType:
+		return fmt.Errorf("the result of %s() can't be compared", e.fn)
+	}
+	return nil
+}
+
+// operand parses a literal, a query or a function call
+func (p *jpParser) operand() (*jpExpr, error) {
+	start := p.pos
+	switch c := p.peek(); {
+	case c == '$' || c == '@':
+		p.pos++
+		query, err := p.segments()
+		if err != nil {
+			return nil, err
+		}
+		query.src = p.src[start:p.pos]
+		return &jpExpr{op: "query", query: query, relative: c == '@'}, nil
+	case c == '\'' || c == '"':
+		s, err := p.stringLiteral()
+		return &jpExpr{op: "literal", value: s}, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	}
+	for _, lit := range []string{"true", "false", "null"} {
+		if p.consume(lit) {
+			var val interface{}
+			if lit != "null" {
+				val = lit == "true"
+			}
+			return &jpExpr{op: "literal", value: val}, nil
+		}
+	}
+	// Function names are lower case letters, digits and "_"
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (p.pos > start && c >= '0' && c <= '9')) {
+			break
+		}
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "" || !p.consume("(") {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.src[start:], start)
+	}
+	types, ok := jpFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s()", name)
+	}
+	expr := &jpExpr{op: "function", fn: name}
+	for {
+		p.skipSpace()
+		if len(expr.args) == 0 && p.consume(")") {
+			break
+		}
+		arg, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		expr.args = append(expr.args, arg)
+		p.skipSpace()
+		if p.consume(")") {
+			break
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected , or ) at position %d", p.pos)
+		}
+	}
+	if len(expr.args) != len(types)-1 {
+		return nil, fmt.Errorf("%s() expects %d arguments", name, len(types)-1)
+	}
+	for i, arg := range expr.args {
+		switch types[i+1] {
+		case jpValueType:
+			if err := arg.comparable(); err != nil {
+				return nil, fmt.Errorf("%s(), %s", name, err)
+			}
+		case jpNodesType:
+			if arg.op != "query" {
+				return nil, fmt.Errorf("%s() expects a query", name)
+			}
+		}
+	}
+	return expr, nil
+}
+
+// number parses a number literal
+func (p *jpParser) number() (*jpExpr, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+		if (p.src[p.pos] == '+' || p.src[p.pos] == '-') && p.src[p.pos-1] != 'e' && p.src[p.pos-1] != 'E' {
+			break
+		}
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	if p.pos == digits || !json.Valid([]byte(s)) {
+		return nil, fmt.Errorf("invalid number %q at position %d", s, start)
+	}
+	return &jpExpr{op: "literal", value: json.Number(s)}, nil
+}
+
+//
+// Filter evaluation
+//
+
+// test evaluates a filter expression for the node @
+func (e *jpExpr) test(root interface{}, current interface{}) bool {
+	switch e.op {
+	case "||":
+		return e.args[0].test(root, current) || e.args[1].test(root, current)
+	case "&&":
+		return e.args[0].test(root, current) && e.args[1].test(root, current)
+	case "!":
+		return !e.args[0].test(root, current)
+	case "()":
+		return e.args[0].test(root, current)
+	case "query":
+		return len(e.nodes(root, current)) > 0
+	case "function":
+		val, _ := e.eval(root, current)
+		b, ok := val.(bool)
+		return ok && b
+	}
+	a, aNothing := e.args[0].eval(root, current)
+	b, bNothing := e.args[1].eval(root, current)
+	switch e.op {
+	case "==":
+		return jpEqual(a, aNothing, b, bNothing)
+	case "!=":
+		return !jpEqual(a, aNothing, b, bNothing)
+	case "<":
+		return jpLess(a, aNothing, b, bNothing)
+	case "<=":
+		return jpLess(a, aNothing, b, bNothing) || jpEqual(a, aNothing, b, bNothing)
+	case ">":
+		return jpLess(b, bNothing, a, aNothing)
+	case ">=":
+		return jpLess(b, bNothing, a, aNothing) || jpEqual(a, aNothing, b, bNothing)
+	}
+	return false
+}
+
+// nodes returns the nodelist of a query
+func (e *jpExpr) nodes(root interface{}, current interface{}) []interface{} {
+	if e.relative {
+		return e.query.eval(root, current)
+	}
+	return e.query.eval(root, root)
+}
+
+// eval returns the value of a literal, singular query or function,
+// nothing is true when there is no value (e.g. a missing member)
+func (e *jpExpr) eval(root interface{}, current interface{}) (val interface{}, nothing bool) {
+	switch e.op {
+	case "literal":
+		return e.value, false
+	case "query":
+		nodes := e.nodes(root, current)
+		if len(nodes) != 1 {
+			return nil, true
+		}
+		return nodes[0], false
+	case "function":
+	default:
+		return e.test(root, current), false
+	}
+	switch e.fn {
+	case "count":
+		return json.Number(strconv.Itoa(len(e.args[0].nodes(root, current)))), false
+	case "value":
+		nodes := e.args[0].nodes(root, current)
+		if len(nodes) != 1 {
+			return nil, true
+		}
+		return nodes[0], false
+	case "length":
+		arg, nothing := e.args[0].eval(root, current)
+		if nothing {
+			return nil, true
+		}
+		switch v := arg.(type) {
+		case string:
+			return json.Number(strconv.Itoa(utf8.RuneCountInString(v))), false
+		case []interface{}:
+			return json.Number(strconv.Itoa(len(v))), false
+		case map[string]interface{}:
+			return json.Number(strconv.Itoa(len(v))), false
+		}
+		return nil, true
+	case "match", "search":
+		s, nothing := e.args[0].eval(root, current)
+		pattern, patternNothing := e.args[1].eval(root, current)
+		str, ok1 := s.(string)
+		expr, ok2 := pattern.(string)
+		if nothing || patternNothing || !ok1 || !ok2 {
+			return false, false
+		}
+		if e.fn == "match" {
+			expr = "^(?:" + expr + ")$"
+		}
+		re, err := regexp.Compile(iregexp(expr))
+		return err == nil && re.MatchString(str), false
+	}
+	return nil, true
+}
+
+// iregexp adapts an I-Regexp (RFC 9485) for Go, in an I-Regexp "."
+// doesn't match line endings
+func iregexp(expr string) string {
+	var sb strings.Builder
+	inClass := false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\\' && i+1 < len(expr):
+			sb.WriteString(expr[i : i+2])
+			i++
+			continue
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '.' && !inClass:
+			sb.WriteString(`[^\n\r]`)
+			continue
+		}
+		sb.WriteByte(expr[i])
+	}
+	return sb.String()
+}
+
+// jpEqual compares two values, Nothing only equals Nothing
+func jpEqual(a interface{}, aNothing bool, b interface{}, bNothing bool) bool {
+	if aNothing || bNothing {
+		return aNothing && bNothing
+	}
+	return jsonEqual(a, b)
+}
+
+// jpLess is true if a and b are both numbers or both strings and a < b
+func jpLess(a interface{}, aNothing bool, b interface{}, bNothing bool) bool {
+	if aNothing || bNothing {
+		return false
+	}
+	if x, ok := jsonNumber(a); ok {
+		y, ok := jsonNumber(b)
+		return ok && x.Cmp(y) < 0
+	}
+	x, ok1 := a.(string)
+	y, ok2 := b.(string)
+	return ok1 && ok2 && x < y
+}
@@ -0,0 +1,130 @@
+package datatools
+
+import (
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	// The bookstore example from RFC 9535, section 1.5
+	src := `{ "store": {
+    "book": [
+      { "category": "reference",
+        "author": "Nigel Rees",
+        "title": "Sayings of the Century",
+        "price": 8.95
+      },
+      { "category": "fiction",
+        "author": "Evelyn Waugh",
+        "title": "Sword of Honour",
+        "price": 12.99
+      },
+      { "category": "fiction",
+        "author": "Herman Melville",
+        "title": "Moby Dick",
+        "isbn": "0-553-21311-3",
+        "price": 8.99
+      },
+      { "category": "fiction",
+        "author": "J. R. R. Tolkien",
+        "title": "The Lord of the Rings",
+        "isbn": "0-395-19395-8",
+        "price": 22.99
+      }
+    ],
+    "bicycle": {
+      "color": "red",
+      "price": 399
+    }
+  }
+}`
+	var doc interface{}
+	if err := JSONUnmarshal([]byte(src), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		`$.store.book[*].author`:                                 `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`,
+		`$..author`:                                              `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`,
+		`$.store.*`:                                              `[{"color":"red","price":399},[{"author":"Nigel Rees","category":"reference","price":8.95,"title":"Sayings of the Century"},{"author":"Evelyn Waugh","category":"fiction","price":12.99,"title":"Sword of Honour"},{"author":"Herman Melville","category":"fiction","isbn":"0-553-21311-3","price":8.99,"title":"Moby Dick"},{"author":"J. R. R. Tolkien","category":"fiction","isbn":"0-395-19395-8","price":22.99,"title":"The Lord of the Rings"}]]`,
+		`$.store..price`:                                         `[399,8.95,12.99,8.99,22.99]`,
+		`$..book[2].title`:                                       `["Moby Dick"]`,
+		`$..book[-1].title`:                                      `["The Lord of the Rings"]`,
+		`$..book[0,1].title`:                                     `["Sayings of the Century","Sword of Honour"]`,
+		`$..book[:2].title`:                                      `["Sayings of the Century","Sword of Honour"]`,
+		`$..book[?@.isbn].title`:                                 `["Moby Dick","The Lord of the Rings"]`,
+		`$..book[?(@.isbn)].title`:                               `["Moby Dick","The Lord of the Rings"]`,
+		`$..book[?@.price<10].title`:                             `["Sayings of the Century","Moby Dick"]`,
+		`$..book[?!@.isbn && @.price > 10].title`:                `["Sword of Honour"]`,
+		`$.store["bicycle"]['color']`:                            `["red"]`,
+		`$.store.book[::-2].price`:                               `[22.99,12.99]`,
+		`$.store.book[1:3].price`:                                `[12.99,8.99]`,
+		`$.store.book[?match(@.author, "J.*")].author`:           `["J. R. R. Tolkien"]`,
+		`$.store.book[?search(@.title, "of the")].title`:         `["Sayings of the Century","The Lord of the Rings"]`,
+		`$.store.book[?length(@.title) == 9].title`:              `["Moby Dick"]`,
+		`$.store[?count(@.*) == 2].color`:                        `["red"]`,
+		`$.store.book[?@.price == $.store.book[0].price].title`:  `["Sayings of the Century"]`,
+		`$.store.book[?value(@..isbn) == "0-553-21311-3"].title`: `["Moby Dick"]`,
+		`$.store.book[?@.price == 8.950].price`:                  `[8.95]`,
+		`$.store.book[?@.missing == @.other].title`:              `["Sayings of the Century","Sword of Honour","Moby Dick","The Lord of the Rings"]`,
+		`$.store.book[?!(@.category == "fiction")].title`:        `["Sayings of the Century"]`,
+		`$.store.book[10]`:                                       `[]`,
+		`$`:                                                      `[{"store":{"bicycle":{"color":"red","price":399},"book":[{"author":"Nigel Rees","category":"reference","price":8.95,"title":"Sayings of the Century"},{"author":"Evelyn Waugh","category":"fiction","price":12.99,"title":"Sword of Honour"},{"author":"Herman Melville","category":"fiction","isbn":"0-553-21311-3","price":8.99,"title":"Moby Dick"},{"author":"J. R. R. Tolkien","category":"fiction","isbn":"0-395-19395-8","price":22.99,"title":"The Lord of the Rings"}]}}]`,
+	}
+	for query, expected := range tests {
+		nodes, err := JSONPathEval(query, doc)
+		if err != nil {
+			t.Errorf("%s, %s", query, err)
+			continue
+		}
+		got, _ := JSONMarshal(nodes)
+		if string(got) != expected {
+			t.Errorf("%s expected %s, got %s", query, expected, got)
+		}
+	}
+	for _, query := range []string{
+		``,
+		`store.book`,
+		`$.store.`,
+		`$.store[`,
+		`$.store.book[01]`,
+		`$.store.book[-0]`,
+		`$.store.book[?@.price]]`,
+		`$.store.book[?@..price == 1]`,
+		`$.store.book[?@.* == 1]`,
+		`$.store.book[?!@.price == 1]`,
+		`$.store.book[?length(@.title)]`,
+		`$.store.book[?count(1) == 1]`,
+		`$.store.book[?match(@.title, "x") == true]`,
+		`$.store.book[?1 == 1 &&]`,
+		`$.store.book[?true]`,
+		`$.store.book[?nope(@)]`,
+		`$['unterminated]`,
+		`$["\q"]`,
+		` $`,
+	} {
+		if nodes, err := JSONPathEval(query, doc); err == nil {
+			t.Errorf("%q expected an error, got %+v", query, nodes)
+		}
+	}
+}
+
+func TestSliceIndexes(t *testing.T) {
+	n := func(i int) *int { return &i }
+	tests := []struct {
+		slice    [3]*int
+		expected string
+	}{
+		{[3]*int{n(1), n(3), nil}, `[1,2]`},
+		{[3]*int{n(5), nil, nil}, `[5,6]`},
+		{[3]*int{n(1), n(5), n(2)}, `[1,3]`},
+		{[3]*int{n(5), n(1), n(-2)}, `[5,3]`},
+		{[3]*int{nil, nil, n(-1)}, `[6,5,4,3,2,1,0]`},
+		{[3]*int{n(-2), nil, nil}, `[5,6]`},
+		{[3]*int{nil, nil, n(0)}, `null`},
+	}
+	for i, test := range tests {
+		got, _ := JSONMarshal(sliceIndexes(test.slice, 7))
+		if string(got) != test.expected {
+			t.Errorf("test %d expected %s, got %s", i, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// lexer splits the prefix expressions used by filter and jsonmodify
+// into tokens.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Version of this package
+	Version = "v0.0.1"
+)
+
+// Tokenize splits an expression into parentheses, double quoted
+// strings, JSON objects and arrays and symbols (e.g. function names,
+// numbers and dotpaths). Quoted strings, objects and arrays are
+// returned as written, it is up to the parser to decode them.
+func Tokenize(src string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(src); {
+		switch c := src[i]; {
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string %s", src[i:])
+			}
+			tokens = append(tokens, src[i:j+1])
+			i = j + 1
+		case c == '{' || c == '[':
+			// Scan to the matching bracket skipping over strings
+			depth, j, inString := 0, i, false
+			for ; j < len(src); j++ {
+				switch {
+				case inString && src[j] == '\\':
+					j++
+				case src[j] == '"':
+					inString = !inString
+				case inString:
+				case src[j] == '{' || src[j] == '[':
+					depth++
+				case src[j] == '}' || src[j] == ']':
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated JSON %s", src[i:])
+			}
+			tokens = append(tokens, src[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(src) && !strings.ContainsRune(" \t\r\n()\"", rune(src[j])) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
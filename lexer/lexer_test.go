@@ -0,0 +1,47 @@
+// lexer splits the prefix expressions used by filter and jsonmodify
+// into tokens.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := map[string]string{
+		`(eq (cols 1) "x y")`:              `( eq ( cols 1 ) "x y" )`,
+		`(set .a "say \"hi\"")`:            `( set .a "say \"hi\"" )`,
+		`(set .a {"b": [1, "]"]})`:         `( set .a {"b": [1, "]"]} )`,
+		"(append .tags\n\t[\"a\", \"b\"])": `( append .tags ["a", "b"] )`,
+	}
+	for src, expected := range tests {
+		tokens, err := Tokenize(src)
+		if err != nil {
+			t.Errorf("%s, %s", src, err)
+			continue
+		}
+		if got := strings.Join(tokens, " "); got != expected {
+			t.Errorf("%s, expected %s, got %s", src, expected, got)
+		}
+	}
+	for _, src := range []string{`(eq "x)`, `(set .a {"b": 1)`} {
+		if tokens, err := Tokenize(src); err == nil {
+			t.Errorf("%s, expected an error, got %q", src, tokens)
+		}
+	}
+}
@@ -0,0 +1,688 @@
+// phonetic.go provides the Soundex and Double Metaphone phonetic keys
+// used to match names that sound alike.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"strings"
+	"unicode"
+)
+
+// soundexCodes maps letters to their Soundex digit, vowels, H, W and Y
+// are not coded.
+var soundexCodes = map[rune]byte{
+	'B': '1', 'F': '1', 'P': '1', 'V': '1',
+	'C': '2', 'G': '2', 'J': '2', 'K': '2', 'Q': '2', 'S': '2', 'X': '2', 'Z': '2',
+	'D': '3', 'T': '3',
+	'L': '4',
+	'M': '5', 'N': '5',
+	'R': '6',
+}
+
+// Soundex returns the American Soundex code of a word (e.g. "Robert"
+// and "Rupert" are both "R163"). Characters other than the letters A
+// to Z are ignored, an empty string is returned if there are none.
+func Soundex(word string) string {
+	code := []byte{}
+	var last byte
+	for _, r := range strings.ToUpper(word) {
+		if r < 'A' || r > 'Z' {
+			continue
+		}
+		digit, coded := soundexCodes[r]
+		if len(code) == 0 {
+			code = append(code, byte(r))
+			last = digit
+			continue
+		}
+		switch {
+		case coded && digit != last:
+			code = append(code, digit)
+			last = digit
+		case r == 'H' || r == 'W':
+			// H and W don't separate letters with the same code
+		case !coded:
+			// vowels separate letters with the same code
+			last = 0
+		}
+		if len(code) == 4 {
+			break
+		}
+	}
+	if len(code) == 0 {
+		return ""
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+// metaphoneKeyLength is the length of the keys returned by DoubleMetaphone
+const metaphoneKeyLength = 4
+
+// metaphone holds the state of encoding a word with Double Metaphone
+type metaphone struct {
+	word      []rune
+	length    int
+	last      int
+	primary   strings.Builder
+	alternate strings.Builder
+}
+
+// at returns the rune at pos or zero if pos is out of range
+func (m *metaphone) at(pos int) rune {
+	if pos < 0 || pos >= len(m.word) {
+		return 0
+	}
+	return m.word[pos]
+}
+
+// stringAt checks if one of the strings is found at start
+func (m *metaphone) stringAt(start int, length int, strs ...string) bool {
+	if start < 0 || start+length > len(m.word) {
+		return false
+	}
+	target := string(m.word[start : start+length])
+	for _, s := range strs {
+		if s == target {
+			return true
+		}
+	}
+	return false
+}
+
+// isVowel checks if the rune at pos is a vowel (including Y)
+func (m *metaphone) isVowel(pos int) bool {
+	switch m.at(pos) {
+	case 'A', 'E', 'I', 'O', 'U', 'Y':
+		return true
+	}
+	return false
+}
+
+// slavoGermanic checks for letters suggesting a Slavic or Germanic word
+func (m *metaphone) slavoGermanic() bool {
+	s := string(m.word)
+	return strings.Contains(s, "W") || strings.Contains(s, "K") || strings.Contains(s, "CZ") || strings.Contains(s, "WITZ")
+}
+
+// add appends to the primary and alternate keys, an alternate of " "
+// adds nothing to the alternate key.
+func (m *metaphone) add(primary string, alternate ...string) {
+	m.primary.WriteString(primary)
+	if len(alternate) == 0 {
+		m.alternate.WriteString(primary)
+	} else if alternate[0] != " " {
+		m.alternate.WriteString(alternate[0])
+	}
+}
+
+// DoubleMetaphone returns the primary and alternate Double Metaphone
+// keys of a word, this is a port of Lawrence Philips' algorithm
+// which accounts for the spelling of names from many languages (e.g.
+// "Smith" and "Schmidt" share the alternate key "XMT").
+func DoubleMetaphone(word string) (string, string) {
+	m := &metaphone{}
+	for _, r := range strings.ToUpper(word) {
+		if unicode.IsLetter(r) || r == ' ' {
+			m.word = append(m.word, r)
+		}
+	}
+	m.length = len(m.word)
+	if m.length < 1 {
+		return "", ""
+	}
+	m.last = m.length - 1
+	// pad the word so look ahead past the end is safe
+	m.word = append(m.word, []rune("     ")...)
+
+	current := 0
+	// skip these when at start of word
+	if m.stringAt(0, 2, "GN", "KN", "PN", "WR", "PS") {
+		current++
+	}
+	// initial 'X' is pronounced 'Z' e.g. 'Xavier'
+	if m.at(0) == 'X' {
+		m.add("S")
+		current++
+	}
+
+	for m.primary.Len() < metaphoneKeyLength || m.alternate.Len() < metaphoneKeyLength {
+		if current >= m.length {
+			break
+		}
+		switch m.at(current) {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			if current == 0 {
+				// all initial vowels map to 'A'
+				m.add("A")
+			}
+			current++
+		case 'B':
+			// "-mb", e.g. "dumb", already skipped over
+			m.add("P")
+			if m.at(current+1) == 'B' {
+				current += 2
+			} else {
+				current++
+			}
+		case 'Ç':
+			m.add("S")
+			current++
+		case 'C':
+			current = m.encodeC(current)
+		case 'D':
+			if m.stringAt(current, 2, "DG") {
+				if m.stringAt(current+2, 1, "I", "E", "Y") {
+					// e.g. 'edge'
+					m.add("J")
+					current += 3
+				} else {
+					// e.g. 'edgar'
+					m.add("TK")
+					current += 2
+				}
+				break
+			}
+			if m.stringAt(current, 2, "DT", "DD") {
+				m.add("T")
+				current += 2
+				break
+			}
+			m.add("T")
+			current++
+		case 'F':
+			if m.at(current+1) == 'F' {
+				current += 2
+			} else {
+				current++
+			}
+			m.add("F")
+		case 'G':
+			current = m.encodeG(current)
+		case 'H':
+			// only keep if first & before vowel or between 2 vowels
+			if (current == 0 || m.isVowel(current-1)) && m.isVowel(current+1) {
+				m.add("H")
+				current += 2
+			} else {
+				// also takes care of 'HH'
+				current++
+			}
+		case 'J':
+			current = m.encodeJ(current)
+		case 'K':
+			if m.at(current+1) == 'K' {
+				current += 2
+			} else {
+				current++
+			}
+			m.add("K")
+		case 'L':
+			if m.at(current+1) == 'L' {
+				// spanish e.g. 'cabrillo', 'gallegos'
+				if (current == m.length-3 && m.stringAt(current-1, 4, "ILLO", "ILLA", "ALLE")) ||
+					((m.stringAt(m.last-1, 2, "AS", "OS") || m.stringAt(m.last, 1, "A", "O")) && m.stringAt(current-1, 4, "ALLE")) {
+					m.add("L", " ")
+					current += 2
+					break
+				}
+				current += 2
+			} else {
+				current++
+			}
+			m.add("L")
+		case 'M':
+			if (m.stringAt(current-1, 3, "UMB") && (current+1 == m.last || m.stringAt(current+2, 2, "ER"))) || m.at(current+1) == 'M' {
+				// 'dumb', 'thumb'
+				current += 2
+			} else {
+				current++
+			}
+			m.add("M")
+		case 'N':
+			if m.at(current+1) == 'N' {
+				current += 2
+			} else {
+				current++
+			}
+			m.add("N")
+		case 'Ñ':
+			current++
+			m.add("N")
+		case 'P':
+			if m.at(current+1) == 'H' {
+				m.add("F")
+				current += 2
+				break
+			}
+			// also account for "campbell", "raspberry"
+			if m.stringAt(current+1, 1, "P", "B") {
+				current += 2
+			} else {
+				current++
+			}
+			m.add("P")
+		case 'Q':
+			if m.at(current+1) == 'Q' {
+				current += 2
+			} else {
+				current++
+			}
+			m.add("K")
+		case 'R':
+			// french e.g. 'rogier', but exclude 'hochmeier'
+			if current == m.last && !m.slavoGermanic() && m.stringAt(current-2, 2, "IE") && !m.stringAt(current-4, 2, "ME", "MA") {
+				m.add("", "R")
+			} else {
+				m.add("R")
+			}
+			if m.at(current+1) == 'R' {
+				current += 2
+			} else {
+				current++
+			}
+		case 'S':
+			current = m.encodeS(current)
+		case 'T':
+			if m.stringAt(current, 4, "TION") {
+				m.add("X")
+				current += 3
+				break
+			}
+			if m.stringAt(current, 3, "TIA", "TCH") {
+				m.add("X")
+				current += 3
+				break
+			}
+			if m.stringAt(current, 2, "TH") || m.stringAt(current, 3, "TTH") {
+				// special case 'thomas', 'thames' or germanic
+				if m.stringAt(current+2, 2, "OM", "AM") || m.stringAt(0, 4, "VAN ", "VON ") || m.stringAt(0, 3, "SCH") {
+					m.add("T")
+				} else {
+					m.add("0", "T")
+				}
+				current += 2
+				break
+			}
+			if m.stringAt(current+1, 1, "T", "D") {
+				current += 2
+			} else {
+				current++
+			}
+			m.add("T")
+		case 'V':
+			if m.at(current+1) == 'V' {
+				current += 2
+			} else {
+				current++
+			}
+			m.add("F")
+		case 'W':
+			// can also be in middle of word
+			if m.stringAt(current, 2, "WR") {
+				m.add("R")
+				current += 2
+				break
+			}
+			if current == 0 && (m.isVowel(current+1) || m.stringAt(current, 2, "WH")) {
+				// Wasserman should match Vasserman
+				if m.isVowel(current + 1) {
+					m.add("A", "F")
+				} else {
+					// need Uomo to match Womo
+					m.add("A")
+				}
+			}
+			// Arnow should match Arnoff
+			if (current == m.last && m.isVowel(current-1)) || m.stringAt(current-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") || m.stringAt(0, 3, "SCH") {
+				m.add("", "F")
+				current++
+				break
+			}
+			// polish e.g. 'filipowicz'
+			if m.stringAt(current, 4, "WICZ", "WITZ") {
+				m.add("TS", "FX")
+				current += 4
+				break
+			}
+			current++
+		case 'X':
+			// french e.g. breaux
+			if !(current == m.last && (m.stringAt(current-3, 3, "IAU", "EAU") || m.stringAt(current-2, 2, "AU", "OU"))) {
+				m.add("KS")
+			}
+			if m.stringAt(current+1, 1, "C", "X") {
+				current += 2
+			} else {
+				current++
+			}
+		case 'Z':
+			// chinese pinyin e.g. 'zhao'
+			if m.at(current+1) == 'H' {
+				m.add("J")
+				current += 2
+				break
+			}
+			if m.stringAt(current+1, 2, "ZO", "ZI", "ZA") || (m.slavoGermanic() && current > 0 && m.at(current-1) != 'T') {
+				m.add("S", "TS")
+			} else {
+				m.add("S")
+			}
+			if m.at(current+1) == 'Z' {
+				current += 2
+			} else {
+				current++
+			}
+		default:
+			current++
+		}
+	}
+	primary, alternate := m.primary.String(), m.alternate.String()
+	if len(primary) > metaphoneKeyLength {
+		primary = primary[:metaphoneKeyLength]
+	}
+	if len(alternate) > metaphoneKeyLength {
+		alternate = alternate[:metaphoneKeyLength]
+	}
+	return primary, alternate
+}
+
+// encodeC encodes a 'C' at current returning the next position
+func (m *metaphone) encodeC(current int) int {
+	// various germanic
+	if current > 1 && !m.isVowel(current-2) && m.stringAt(current-1, 3, "ACH") &&
+		m.at(current+2) != 'I' && (m.at(current+2) != 'E' || m.stringAt(current-2, 6, "BACHER", "MACHER")) {
+		m.add("K")
+		return current + 2
+	}
+	// special case 'caesar'
+	if current == 0 && m.stringAt(current, 6, "CAESAR") {
+		m.add("S")
+		return current + 2
+	}
+	// italian 'chianti'
+	if m.stringAt(current, 4, "CHIA") {
+		m.add("K")
+		return current + 2
+	}
+	if m.stringAt(current, 2, "CH") {
+		// find 'michael'
+		if current > 0 && m.stringAt(current, 4, "CHAE") {
+			m.add("K", "X")
+			return current + 2
+		}
+		// greek roots e.g. 'chemistry', 'chorus'
+		if current == 0 && (m.stringAt(current+1, 5, "HARAC", "HARIS") || m.stringAt(current+1, 3, "HOR", "HYM", "HIA", "HEM")) && !m.stringAt(0, 5, "CHORE") {
+			m.add("K")
+			return current + 2
+		}
+		// germanic, greek, or otherwise 'ch' for 'kh' sound
+		if m.stringAt(0, 4, "VAN ", "VON ") || m.stringAt(0, 3, "SCH") ||
+			// 'architect but not 'arch', 'orchestra', 'orchid'
+			m.stringAt(current-2, 6, "ORCHES", "ARCHIT", "ORCHID") ||
+			m.stringAt(current+2, 1, "T", "S") ||
+			((m.stringAt(current-1, 1, "A", "O", "U", "E") || current == 0) &&
+				// e.g., 'wachtler', 'wechsler', but not 'tichner'
+				m.stringAt(current+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ")) {
+			m.add("K")
+		} else if current > 0 {
+			if m.stringAt(0, 2, "MC") {
+				// e.g., "McHugh"
+				m.add("K")
+			} else {
+				m.add("X", "K")
+			}
+		} else {
+			m.add("X")
+		}
+		return current + 2
+	}
+	// e.g, 'czerny'
+	if m.stringAt(current, 2, "CZ") && !m.stringAt(current-2, 4, "WICZ") {
+		m.add("S", "X")
+		return current + 2
+	}
+	// e.g., 'focaccia'
+	if m.stringAt(current+1, 3, "CIA") {
+		m.add("X")
+		return current + 3
+	}
+	// double 'C', but not if e.g. 'McClellan'
+	if m.stringAt(current, 2, "CC") && !(current == 1 && m.at(0) == 'M') {
+		// 'bellocchio' but not 'bacchus'
+		if m.stringAt(current+2, 1, "I", "E", "H") && !m.stringAt(current+2, 2, "HU") {
+			if (current == 1 && m.at(current-1) == 'A') || m.stringAt(current-1, 5, "UCCEE", "UCCES") {
+				// 'accident', 'accede' 'succeed'
+				m.add("KS")
+			} else {
+				// 'bacci', 'bertucci', other italian
+				m.add("X")
+			}
+			return current + 3
+		}
+		// Pierce's rule
+		m.add("K")
+		return current + 2
+	}
+	if m.stringAt(current, 2, "CK", "CG", "CQ") {
+		m.add("K")
+		return current + 2
+	}
+	if m.stringAt(current, 2, "CI", "CE", "CY") {
+		// italian vs. english
+		if m.stringAt(current, 3, "CIO", "CIE", "CIA") {
+			m.add("S", "X")
+		} else {
+			m.add("S")
+		}
+		return current + 2
+	}
+	m.add("K")
+	// name sent in 'mac caffrey', 'mac gregor'
+	switch {
+	case m.stringAt(current+1, 2, " C", " Q", " G"):
+		return current + 3
+	case m.stringAt(current+1, 1, "C", "K", "Q") && !m.stringAt(current+1, 2, "CE", "CI"):
+		return current + 2
+	}
+	return current + 1
+}
+
+// encodeG encodes a 'G' at current returning the next position
+func (m *metaphone) encodeG(current int) int {
+	if m.at(current+1) == 'H' {
+		if current > 0 && !m.isVowel(current-1) {
+			m.add("K")
+			return current + 2
+		}
+		// 'ghislane', 'ghiradelli'
+		if current == 0 {
+			if m.at(current+2) == 'I' {
+				m.add("J")
+			} else {
+				m.add("K")
+			}
+			return current + 2
+		}
+		// Parker's rule (with some further refinements) - e.g., 'hugh'
+		if (current > 1 && m.stringAt(current-2, 1, "B", "H", "D")) ||
+			// e.g., 'bough'
+			(current > 2 && m.stringAt(current-3, 1, "B", "H", "D")) ||
+			// e.g., 'broughton'
+			(current > 3 && m.stringAt(current-4, 1, "B", "H")) {
+			return current + 2
+		}
+		// e.g., 'laugh', 'McLaughlin', 'cough', 'gough', 'rough', 'tough'
+		if current > 2 && m.at(current-1) == 'U' && m.stringAt(current-3, 1, "C", "G", "L", "R", "T") {
+			m.add("F")
+		} else if current > 0 && m.at(current-1) != 'I' {
+			m.add("K")
+		}
+		return current + 2
+	}
+	if m.at(current+1) == 'N' {
+		if current == 1 && m.isVowel(0) && !m.slavoGermanic() {
+			m.add("KN", "N")
+		} else if !m.stringAt(current+2, 2, "EY") && m.at(current+1) != 'Y' && !m.slavoGermanic() {
+			// not e.g. 'cagney'
+			m.add("N", "KN")
+		} else {
+			m.add("KN")
+		}
+		return current + 2
+	}
+	// 'tagliaro'
+	if m.stringAt(current+1, 2, "LI") && !m.slavoGermanic() {
+		m.add("KL", "L")
+		return current + 2
+	}
+	// -ges-, -gep-, -gel-, -gie- at beginning
+	if current == 0 && (m.at(current+1) == 'Y' || m.stringAt(current+1, 2, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")) {
+		m.add("K", "J")
+		return current + 2
+	}
+	// -ger-,  -gy-
+	if (m.stringAt(current+1, 2, "ER") || m.at(current+1) == 'Y') && !m.stringAt(0, 6, "DANGER", "RANGER", "MANGER") &&
+		!m.stringAt(current-1, 1, "E", "I") && !m.stringAt(current-1, 3, "RGY", "OGY") {
+		m.add("K", "J")
+		return current + 2
+	}
+	// italian e.g, 'biaggi'
+	if m.stringAt(current+1, 1, "E", "I", "Y") || m.stringAt(current-1, 4, "AGGI", "OGGI") {
+		if m.stringAt(0, 4, "VAN ", "VON ") || m.stringAt(0, 3, "SCH") || m.stringAt(current+1, 2, "ET") {
+			// obvious germanic
+			m.add("K")
+		} else if m.stringAt(current+1, 4, "IER ") {
+			// always soft if french ending
+			m.add("J")
+		} else {
+			m.add("J", "K")
+		}
+		return current + 2
+	}
+	m.add("K")
+	if m.at(current+1) == 'G' {
+		return current + 2
+	}
+	return current + 1
+}
+
+// encodeJ encodes a 'J' at current returning the next position
+func (m *metaphone) encodeJ(current int) int {
+	// obvious spanish, 'jose', 'san jacinto'
+	if m.stringAt(current, 4, "JOSE") || m.stringAt(0, 4, "SAN ") {
+		if (current == 0 && m.at(current+4) == ' ') || m.stringAt(0, 4, "SAN ") {
+			m.add("H")
+		} else {
+			m.add("J", "H")
+		}
+		return current + 1
+	}
+	if current == 0 && !m.stringAt(current, 4, "JOSE") {
+		// Yankelovich/Jankelowicz
+		m.add("J", "A")
+	} else if m.isVowel(current-1) && !m.slavoGermanic() && (m.at(current+1) == 'A' || m.at(current+1) == 'O') {
+		// spanish pron. of e.g. 'bajador'
+		m.add("J", "H")
+	} else if current == m.last {
+		m.add("J", " ")
+	} else if !m.stringAt(current+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") && !m.stringAt(current-1, 1, "S", "K", "L") {
+		m.add("J")
+	}
+	if m.at(current+1) == 'J' {
+		return current + 2
+	}
+	return current + 1
+}
+
+// encodeS encodes an 'S' at current returning the next position
+func (m *metaphone) encodeS(current int) int {
+	// special cases 'island', 'isle', 'carlisle', 'carlysle'
+	if m.stringAt(current-1, 3, "ISL", "YSL") {
+		return current + 1
+	}
+	// special case 'sugar-'
+	if current == 0 && m.stringAt(current, 5, "SUGAR") {
+		m.add("X", "S")
+		return current + 1
+	}
+	if m.stringAt(current, 2, "SH") {
+		// germanic
+		if m.stringAt(current+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			m.add("S")
+		} else {
+			m.add("X")
+		}
+		return current + 2
+	}
+	// italian & armenian
+	if m.stringAt(current, 3, "SIO", "SIA") || m.stringAt(current, 4, "SIAN") {
+		if !m.slavoGermanic() {
+			m.add("S", "X")
+		} else {
+			m.add("S")
+		}
+		return current + 3
+	}
+	// german & anglicisations, e.g. 'smith' match 'schmidt', 'snider'
+	// match 'schneider', also -sz- in slavic language although in
+	// hungarian it is pronounced 's'
+	if (current == 0 && m.stringAt(current+1, 1, "M", "N", "L", "W")) || m.stringAt(current+1, 1, "Z") {
+		m.add("S", "X")
+		if m.stringAt(current+1, 1, "Z") {
+			return current + 2
+		}
+		return current + 1
+	}
+	if m.stringAt(current, 2, "SC") {
+		// Schlesinger's rule
+		if m.at(current+2) == 'H' {
+			// dutch origin, e.g. 'school', 'schooner'
+			if m.stringAt(current+3, 2, "OO", "ER", "EN", "UY", "ED", "EM") {
+				// 'schermerhorn', 'schenker'
+				if m.stringAt(current+3, 2, "ER", "EN") {
+					m.add("X", "SK")
+				} else {
+					m.add("SK")
+				}
+				return current + 3
+			}
+			if current == 0 && !m.isVowel(3) && m.at(3) != 'W' {
+				m.add("X", "S")
+			} else {
+				m.add("X")
+			}
+			return current + 3
+		}
+		if m.stringAt(current+2, 1, "I", "E", "Y") {
+			m.add("S")
+			return current + 3
+		}
+		m.add("SK")
+		return current + 3
+	}
+	// french e.g. 'resnais', 'artois'
+	if current == m.last && m.stringAt(current-2, 2, "AI", "OI") {
+		m.add("", "S")
+	} else {
+		m.add("S")
+	}
+	if m.stringAt(current+1, 1, "S", "Z") {
+		return current + 2
+	}
+	return current + 1
+}
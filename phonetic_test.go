@@ -0,0 +1,51 @@
+package datatools
+
+import (
+	"testing"
+)
+
+func TestSoundex(t *testing.T) {
+	expected := map[string]string{
+		"Robert":   "R163",
+		"Rupert":   "R163",
+		"Rubin":    "R150",
+		"Ashcraft": "A261",
+		"Tymczak":  "T522",
+		"Pfister":  "P236",
+		"Honeyman": "H555",
+		"Lee":      "L000",
+		"":         "",
+		"123":      "",
+	}
+	for word, code := range expected {
+		if s := Soundex(word); s != code {
+			t.Errorf("Soundex(%q) expected %q, got %q", word, code, s)
+		}
+	}
+}
+
+func TestDoubleMetaphone(t *testing.T) {
+	expected := map[string][2]string{
+		"Smith":       {"SM0", "XMT"},
+		"Schmidt":     {"XMT", "SMT"},
+		"Thomas":      {"TMS", "TMS"},
+		"Michael":     {"MKL", "MXL"},
+		"Caesar":      {"SSR", "SSR"},
+		"Jankelowicz": {"JNKL", "ANKL"},
+		"Gallegos":    {"KLKS", "KKS"},
+		"Filipowicz":  {"FLPT", "FLPF"},
+		"Xavier":      {"SF", "SFR"},
+		"Jose":        {"HS", "HS"},
+		"Knight":      {"NT", "NT"},
+		"Laugh":       {"LF", "LF"},
+		"Arnow":       {"ARN", "ARNF"},
+		"Wasserman":   {"ASRM", "FSRM"},
+		"":            {"", ""},
+	}
+	for word, keys := range expected {
+		primary, alternate := DoubleMetaphone(word)
+		if primary != keys[0] || alternate != keys[1] {
+			t.Errorf("DoubleMetaphone(%q) expected %q, %q, got %q, %q", word, keys[0], keys[1], primary, alternate)
+		}
+	}
+}
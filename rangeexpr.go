@@ -23,43 +23,491 @@ import (
 	"strings"
 )
 
-// ParseRange takes a string in the form of a "range expression" like 1,2 (one and two), 1-3 (one, two, three)
-// or 1,2,8-10 (one, two, eight, nine, ten) and returns an array of ints holding the values of the range expression.
-func ParseRange(s string) ([]int, error) {
-	r := []int{}
-	// Convert colon delimited ranges to dashes
+// RangeItem is one element of a range expression. Start and End count
+// from one, negative values count back from the end of the sequence
+// (-1 is the last). Step is the increment between values, a negative
+// Step counts down from the larger end of the range.
+type RangeItem struct {
+	Start int
+	End   int
+	Step  int
+}
+
+// RangeExpr is a parsed range expression, see ParseRangeExpr.
+type RangeExpr []RangeItem
+
+// parseRangeInt parses a position in a range expression
+func parseRangeInt(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q, %s", s, err)
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("%q, ranges are counted from one", s)
+	}
+	return i, nil
+}
+
+// parseRangeItem parses a single element of a range expression
+func parseRangeItem(s string) (RangeItem, error) {
+	s = strings.TrimSpace(s)
+	item := RangeItem{Start: 1, End: -1, Step: 1}
+	if strings.EqualFold(s, "all") {
+		return item, nil
+	}
+	var err error
 	if strings.Contains(s, ":") {
-		s = strings.Replace(s, ":", "-", -1)
-	}
-	cells := strings.Split(s, ",")
-	for _, c := range cells {
-		if strings.Contains(c, "-") {
-			p := strings.Split(c, "-")
-			if len(p) != 2 {
-				return r, fmt.Errorf("%q is not an int range like 10 - 13", c)
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return item, fmt.Errorf("%q is not a range like START:END:STEP", s)
+		}
+		if strings.TrimSpace(parts[0]) != "" {
+			if item.Start, err = parseRangeInt(parts[0]); err != nil {
+				return item, err
 			}
-			p[0], p[1] = strings.TrimSpace(p[0]), strings.TrimSpace(p[1])
-			start, err := strconv.Atoi(p[0])
-			if err != nil {
-				return r, fmt.Errorf("%q, %s", p[0], err)
+		}
+		if strings.TrimSpace(parts[1]) != "" {
+			if item.End, err = parseRangeInt(parts[1]); err != nil {
+				return item, err
 			}
-			end, err := strconv.Atoi(p[1])
-			if err != nil {
-				return r, fmt.Errorf("%q, %s", p[1], err)
+		}
+		if len(parts) == 3 && strings.TrimSpace(parts[2]) != "" {
+			if item.Step, err = strconv.Atoi(strings.TrimSpace(parts[2])); err != nil || item.Step == 0 {
+				return item, fmt.Errorf("%q, step must be a non-zero integer", s)
 			}
-			if start > end {
-				start, end = end, start
+		}
+		return item, nil
+	}
+	// Support the original dash range, e.g. 8-10
+	if i := strings.LastIndex(s, "-"); i > 0 {
+		if item.Start, err = parseRangeInt(s[:i]); err != nil {
+			return item, err
+		}
+		if item.End, err = parseRangeInt(s[i+1:]); err != nil {
+			return item, err
+		}
+		return item, nil
+	}
+	if item.Start, err = parseRangeInt(s); err != nil {
+		return item, err
+	}
+	item.End = item.Start
+	return item, nil
+}
+
+// ParseRangeExpr parses a range expression. A range expression is a
+// comma separated list of positions (counting from one) and ranges.
+//
+//	5       the fifth
+//	-1      the last
+//	2:10    the second through the tenth (2-10 also works)
+//	2:      the second through the last
+//	:10     the first through the tenth
+//	-20:    the last twenty
+//	1:100:5 every fifth from the first through the hundredth
+//	all     everything
+//
+// Negative positions and open ended ranges are resolved against the
+// length of the sequence, see Resolve and RangeSelector.
+func ParseRangeExpr(s string) (RangeExpr, error) {
+	expr := RangeExpr{}
+	for _, part := range strings.Split(s, ",") {
+		item, err := parseRangeItem(part)
+		if err != nil {
+			return nil, err
+		}
+		expr = append(expr, item)
+	}
+	return expr, nil
+}
+
+// bounds returns the lowest and highest position of the item for a
+// sequence of n, hi is less than lo if the item is empty.
+func (item RangeItem) bounds(n int) (int, int) {
+	start, end := item.Start, item.End
+	if start < 0 {
+		start = n + start + 1
+	}
+	if end < 0 {
+		end = n + end + 1
+	}
+	if start > end {
+		start, end = end, start
+	}
+	if start < 1 {
+		start = 1
+	}
+	return start, end
+}
+
+// contains checks if pos is selected by the item for a sequence of n
+func (item RangeItem) contains(pos int, n int) bool {
+	lo, hi := item.bounds(n)
+	if pos < lo || pos > hi {
+		return false
+	}
+	if item.Step > 0 {
+		return (pos-lo)%item.Step == 0
+	}
+	return (hi-pos)%(-item.Step) == 0
+}
+
+// Bounded is true if the expression can be resolved without knowing
+// the length of the sequence (it has no negative or open ended ranges).
+func (expr RangeExpr) Bounded() bool {
+	for _, item := range expr {
+		if item.Start < 0 || item.End < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TailSize returns how many items from the end of a sequence must be
+// seen before the position of an item relative to the end is known.
+// It returns -1 if the whole sequence is needed (a negative step
+// counting down from a position relative to the end).
+func (expr RangeExpr) TailSize() int {
+	size := 0
+	for _, item := range expr {
+		if item.Step < 0 && (item.Start < 0 || item.End < 0) {
+			return -1
+		}
+		for _, i := range []int{item.Start, item.End} {
+			if i < 0 && -i > size {
+				size = -i
 			}
-			for i := start; i <= end; i++ {
+		}
+	}
+	return size
+}
+
+// Contains checks if pos (counting from one) is selected in a
+// sequence of n.
+func (expr RangeExpr) Contains(pos int, n int) bool {
+	for _, item := range expr {
+		if item.contains(pos, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the positions selected in a sequence of n in the
+// order of the expression. n is only used for negative and open ended
+// ranges so may be zero for a Bounded expression.
+func (expr RangeExpr) Resolve(n int) []int {
+	r := []int{}
+	for _, item := range expr {
+		lo, hi := item.bounds(n)
+		if item.Step > 0 {
+			for i := lo; i <= hi; i += item.Step {
 				r = append(r, i)
 			}
 		} else {
-			i, err := strconv.Atoi(strings.TrimSpace(c))
-			if err != nil {
-				return r, fmt.Errorf("%q, %s", c, err)
+			for i := hi; i >= lo; i += item.Step {
+				r = append(r, i)
+			}
+		}
+	}
+	return r
+}
+
+// RangeSelector selects items from a stream by their position, counting
+// from one, with a range expression. Selected items are returned in
+// stream order. Items whose selection depends on their distance from
+// the end of the stream are held in a tail buffer of TailSize items
+// until it is known.
+type RangeSelector struct {
+	expr    RangeExpr
+	tail    int
+	count   int
+	pending []interface{}
+}
+
+// NewRangeSelector creates a RangeSelector for a range expression
+func NewRangeSelector(expr RangeExpr) *RangeSelector {
+	return &RangeSelector{expr: expr, tail: expr.TailSize()}
+}
+
+// Push adds the next item in the stream returning the items now known
+// to be selected.
+func (s *RangeSelector) Push(item interface{}) []interface{} {
+	s.count++
+	s.pending = append(s.pending, item)
+	selected := []interface{}{}
+	if s.tail < 0 {
+		return selected
+	}
+	for len(s.pending) > s.tail {
+		pos := s.count - len(s.pending) + 1
+		// At least tail items follow pos so any position counted
+		// back from the end is after it, pos+tail stands in for the
+		// length of the stream.
+		if s.expr.Contains(pos, pos+s.tail) {
+			selected = append(selected, s.pending[0])
+		}
+		s.pending = s.pending[1:]
+	}
+	return selected
+}
+
+// Flush is called at the end of the stream returning the remaining
+// selected items.
+func (s *RangeSelector) Flush() []interface{} {
+	selected := []interface{}{}
+	pos := s.count - len(s.pending)
+	for _, item := range s.pending {
+		pos++
+		if s.expr.Contains(pos, s.count) {
+			selected = append(selected, item)
+		}
+	}
+	s.pending = nil
+	return selected
+}
+
+// ParseRange takes a string in the form of a "range expression" like 1,2 (one and two), 1-3 (one, two, three)
+// or 1,2,8-10 (one, two, eight, nine, ten) and returns an array of ints holding the values of the range expression.
+// Steps are supported (e.g. 1:10:3 is one, four, seven, ten), negative and open ended ranges need the length
+// of the sequence so use ParseRangeExpr for those. Zero is accepted (e.g. 0-3) as it was before range
+// expressions counted from one.
+func ParseRange(s string) ([]int, error) {
+	expr, err := ParseRangeExpr(s)
+	if err != nil {
+		if r, zeroErr := parseZeroRange(s); zeroErr == nil {
+			return r, nil
+		}
+		return []int{}, err
+	}
+	if !expr.Bounded() {
+		return []int{}, fmt.Errorf("%q, range is relative to the end, use ParseRangeExpr", s)
+	}
+	return expr.Resolve(0), nil
+}
+
+// parseZeroRange parses the comma separated integers and dash (or
+// colon) ranges ParseRange has always accepted, including zero.
+func parseZeroRange(s string) ([]int, error) {
+	r := []int{}
+	for _, c := range strings.Split(strings.Replace(s, ":", "-", -1), ",") {
+		p := strings.Split(c, "-")
+		if len(p) > 2 {
+			return r, fmt.Errorf("%q is not an int range like 10 - 13", c)
+		}
+		start, err := strconv.Atoi(strings.TrimSpace(p[0]))
+		if err != nil {
+			return r, fmt.Errorf("%q, %s", p[0], err)
+		}
+		end := start
+		if len(p) == 2 {
+			if end, err = strconv.Atoi(strings.TrimSpace(p[1])); err != nil {
+				return r, fmt.Errorf("%q, %s", p[1], err)
 			}
+		}
+		if start > end {
+			start, end = end, start
+		}
+		for i := start; i <= end; i++ {
 			r = append(r, i)
 		}
 	}
 	return r, nil
 }
+
+// splitSelector splits a column selector on commas that are not
+// inside double quotes.
+func splitSelector(s string) ([]string, error) {
+	parts := []string{}
+	inQuote := false
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case c == ',' && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("%q has an unterminated quote", s)
+	}
+	return append(parts, s[start:]), nil
+}
+
+// cutSelector splits a column selector element into the start and end
+// of a range on the first colon that is not inside double quotes.
+func cutSelector(s string) (string, string, bool) {
+	inQuote := false
+	for i, c := range s {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case c == ':' && !inQuote:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// splitRange splits a column selector element into the start, end
+// and step of a range on the colons that are not inside double quotes.
+func splitRange(s string) []string {
+	parts := []string{}
+	inQuote := false
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case c == ':' && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// columnIndex resolves a single column reference into a zero based
+// column index. A reference is a column number counting from one,
+// a negative number counting back from the last column, or a header
+// name which may be enclosed in double quotes.
+func columnIndex(ref string, header []string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return -1, fmt.Errorf("missing column reference")
+	}
+	quoted := strings.HasPrefix(ref, `"`) && strings.HasSuffix(ref, `"`) && len(ref) > 1
+	if !quoted {
+		if i, err := strconv.Atoi(ref); err == nil {
+			switch {
+			case i > 0:
+				return i - 1, nil
+			case i < 0 && len(header)+i >= 0:
+				return len(header) + i, nil
+			case i < 0:
+				return -1, fmt.Errorf("%q, is before the first column", ref)
+			}
+			return -1, fmt.Errorf("%q, columns are counted from one", ref)
+		}
+	}
+	name := ref
+	if quoted {
+		name = ref[1 : len(ref)-1]
+	}
+	if header == nil {
+		return -1, fmt.Errorf("%q, no header row to find column name", ref)
+	}
+	for i, val := range header {
+		if val == name {
+			return i, nil
+		}
+	}
+	for i, val := range header {
+		if strings.EqualFold(strings.TrimSpace(val), strings.TrimSpace(name)) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%q, column not found", ref)
+}
+
+// ParseColumns takes a column selector expression and a header row and
+// returns the zero based column indexes it describes. A column selector
+// is a range expression (e.g. 1,3:5, 2: or all, see ParseRangeExpr)
+// which may also use header names,
+// quoted if they contain a comma or colon (e.g. Title,"Last, First"),
+// ranges of header names (e.g. "Title":"Year") and negative numbers
+// counting back from the last column (e.g. -1 is the last column).
+// header may be nil if only positive column numbers are used.
+func ParseColumns(s string, header []string) ([]int, error) {
+	cols := []int{}
+	parts, err := splitSelector(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		// A header name takes precedence over any range syntax
+		if i, err := columnIndex(part, header); err == nil {
+			cols = append(cols, i)
+			continue
+		}
+		if strings.EqualFold(part, "all") && header != nil {
+			cols = append(cols, columnRange(RangeExpr{{Start: 1, End: -1, Step: 1}}, header)...)
+			continue
+		}
+		parts := splitRange(part)
+		if len(parts) == 1 && strings.Contains(strings.TrimPrefix(part, "-"), "-") {
+			// Support the original dash range, e.g. 8-10
+			if r, err := ParseRange(part); err == nil {
+				for _, i := range r {
+					cols = append(cols, i-1)
+				}
+				continue
+			}
+		}
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("%q is not a range like START:END:STEP", part)
+		}
+		if len(parts) == 1 {
+			col, err := columnIndex(part, header)
+			if err != nil {
+				return nil, err
+			}
+			cols = append(cols, col)
+			continue
+		}
+		// Resolve the ends to column numbers, empty ends are open
+		item := RangeItem{Start: 1, End: -1, Step: 1}
+		if strings.TrimSpace(parts[0]) != "" {
+			col, err := columnIndex(parts[0], header)
+			if err != nil {
+				return nil, err
+			}
+			item.Start = col + 1
+		}
+		if strings.TrimSpace(parts[1]) != "" {
+			col, err := columnIndex(parts[1], header)
+			if err != nil {
+				return nil, err
+			}
+			item.End = col + 1
+		} else if header == nil {
+			return nil, fmt.Errorf("%q, no header row to find the last column", part)
+		}
+		if len(parts) == 3 && strings.TrimSpace(parts[2]) != "" {
+			step, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+			if err != nil || step == 0 {
+				return nil, fmt.Errorf("%q, step must be a non-zero integer", part)
+			}
+			item.Step = step
+		}
+		cols = append(cols, columnRange(RangeExpr{item}, header)...)
+	}
+	return cols, nil
+}
+
+// columnRange resolves a range expression against a header returning
+// zero based column indexes
+func columnRange(expr RangeExpr, header []string) []int {
+	cols := []int{}
+	for _, i := range expr.Resolve(len(header)) {
+		cols = append(cols, i-1)
+	}
+	return cols
+}
+
+// ParseColumn is like ParseColumns but expects the selector to
+// describe exactly one column.
+func ParseColumn(s string, header []string) (int, error) {
+	cols, err := ParseColumns(s, header)
+	if err != nil {
+		return -1, err
+	}
+	if len(cols) != 1 {
+		return -1, fmt.Errorf("%q, expected a single column", s)
+	}
+	return cols[0], nil
+}
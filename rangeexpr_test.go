@@ -18,6 +18,7 @@
 package datatools
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -131,3 +132,156 @@ func TestParseRangeOriginal(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRangeExpr(t *testing.T) {
+	tests := map[string][]int{
+		"2:":       {2, 3, 4, 5, 6, 7, 8, 9, 10},
+		":3":       {1, 2, 3},
+		"-3:":      {8, 9, 10},
+		"-20:":     {1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		"all":      {1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		"1:10:4":   {1, 5, 9},
+		"10:1:-3":  {10, 7, 4, 1},
+		"-1,1":     {10, 1},
+		"2:-8":     {2, 3},
+		"1,3-4,-2": {1, 3, 4, 9},
+		"-11":      {},
+	}
+	for src, expected := range tests {
+		expr, err := ParseRangeExpr(src)
+		if err != nil {
+			t.Errorf("expected (%s) no errors, got %s", src, err)
+			continue
+		}
+		result := expr.Resolve(10)
+		if len(result) != len(expected) {
+			t.Errorf("expected (%s) %+v, got %+v", src, expected, result)
+			continue
+		}
+		for i, got := range result {
+			if got != expected[i] {
+				t.Errorf("expected (%s) %+v, got %+v", src, expected, result)
+				break
+			}
+		}
+	}
+
+	for _, src := range []string{"", "0", "a:3", "1:2:0", "1:2:3:4", "1,,2"} {
+		if expr, err := ParseRangeExpr(src); err == nil {
+			t.Errorf("expected (%s) an error, got %+v", src, expr)
+		}
+	}
+
+	// ParseRange only handles expressions not relative to the end
+	if result, err := ParseRange("1:10:3"); err != nil || len(result) != 4 || result[3] != 10 {
+		t.Errorf("expected [1 4 7 10], got %+v, %s", result, err)
+	}
+	if result, err := ParseRange("2:"); err == nil {
+		t.Errorf("expected an error for an open range, got %+v", result)
+	}
+	// ParseRange still accepts zero
+	if result, err := ParseRange("0-2,5"); err != nil || fmt.Sprintf("%v", result) != "[0 1 2 5]" {
+		t.Errorf("expected [0 1 2 5], got %+v, %v", result, err)
+	}
+}
+
+func TestRangeSelector(t *testing.T) {
+	for _, src := range []string{"2:", ":3", "-3:", "-20:", "all", "1:20:4", "-1,1", "2:-8", "-4:-2,5", "-2::-1", "-12:5:-2", "20:-20"} {
+		expr, err := ParseRangeExpr(src)
+		if err != nil {
+			t.Errorf("expected (%s) no errors, got %s", src, err)
+			continue
+		}
+		for _, n := range []int{0, 1, 5, 12} {
+			// Selected items are returned in stream order
+			expected := []int{}
+			for pos := 1; pos <= n; pos++ {
+				if expr.Contains(pos, n) {
+					expected = append(expected, pos)
+				}
+			}
+			result := []int{}
+			selector := NewRangeSelector(expr)
+			for pos := 1; pos <= n; pos++ {
+				for _, item := range selector.Push(pos) {
+					result = append(result, item.(int))
+				}
+				if tail := expr.TailSize(); tail >= 0 && len(selector.pending) > tail {
+					t.Errorf("expected (%s) at most %d pending, got %d", src, tail, len(selector.pending))
+				}
+			}
+			for _, item := range selector.Flush() {
+				result = append(result, item.(int))
+			}
+			if fmt.Sprintf("%v", result) != fmt.Sprintf("%v", expected) {
+				t.Errorf("expected (%s, %d) %+v, got %+v", src, n, expected, result)
+			}
+		}
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	header := []string{"ID", "Title", "Last, First", "Year", "Publisher"}
+	tests := map[string][]int{
+		"1":                {0},
+		"1,3:4":            {0, 2, 3},
+		"2-3":              {1, 2},
+		"Title":            {1},
+		"title,Year":       {1, 3},
+		`"Last, First",ID`: {2, 0},
+		`"Title":"Year"`:   {1, 2, 3},
+		`Title:Year`:       {1, 2, 3},
+		"-1":               {4},
+		"-2:-1":            {3, 4},
+		"2:-1":             {1, 2, 3, 4},
+		`ID,"Year":-1`:     {0, 3, 4},
+		"7":                {6},
+		"2:":               {1, 2, 3, 4},
+		":2":               {0, 1},
+		"all":              {0, 1, 2, 3, 4},
+		"1:5:2":            {0, 2, 4},
+		"::-1":             {4, 3, 2, 1, 0},
+		"Year:,ID":         {3, 4, 0},
+	}
+	for expr, expected := range tests {
+		result, err := ParseColumns(expr, header)
+		if err != nil {
+			t.Errorf("expected (%s) no errors, got %s", expr, err)
+			continue
+		}
+		if len(result) != len(expected) {
+			t.Errorf("expected (%s) %+v, got %+v", expr, expected, result)
+			continue
+		}
+		for i, got := range result {
+			if got != expected[i] {
+				t.Errorf("expected (%s) %+v, got %+v", expr, expected, result)
+				break
+			}
+		}
+	}
+
+	for _, expr := range []string{"Author", "0", "-6", `"Title`, "Title:Author"} {
+		if result, err := ParseColumns(expr, header); err == nil {
+			t.Errorf("expected (%s) an error, got %+v", expr, result)
+		}
+	}
+
+	// Without a header only column numbers can be used
+	if _, err := ParseColumns("Title", nil); err == nil {
+		t.Errorf("expected an error for a column name without a header")
+	}
+	if result, err := ParseColumns("1,3", nil); err != nil || len(result) != 2 {
+		t.Errorf("expected [0 2], got %+v, %s", result, err)
+	}
+}
+
+func TestParseColumn(t *testing.T) {
+	header := []string{"ID", "Title", "Year"}
+	if col, err := ParseColumn("Year", header); err != nil || col != 2 {
+		t.Errorf("expected 2, got %d, %s", col, err)
+	}
+	if _, err := ParseColumn("1:2", header); err == nil {
+		t.Errorf("expected an error for more than one column")
+	}
+}
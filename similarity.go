@@ -0,0 +1,273 @@
+// similarity.go provides normalized (0 to 1) string similarity metrics
+// for fuzzy matching of CSV cells.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SimilarityMetrics lists the names accepted by SimilarityMetric
+var SimilarityMetrics = []string{
+	"levenshtein",
+	"jaro-winkler",
+	"token-sort",
+	"token-set",
+	"jaccard",
+	"soundex",
+	"metaphone",
+}
+
+// SimilarityFunc scores the similarity of two strings from 0 (nothing
+// in common) to 1 (the same)
+type SimilarityFunc func(a string, b string) float64
+
+// SimilarityMetric returns the similarity function for a metric name
+// from SimilarityMetrics.
+func SimilarityMetric(name string) (SimilarityFunc, error) {
+	switch strings.ToLower(name) {
+	case "levenshtein":
+		return LevenshteinRatio, nil
+	case "jaro-winkler", "jarowinkler":
+		return JaroWinkler, nil
+	case "token-sort":
+		return TokenSortRatio, nil
+	case "token-set":
+		return TokenSetRatio, nil
+	case "jaccard":
+		return func(a string, b string) float64 {
+			return NGramJaccard(a, b, 3)
+		}, nil
+	case "soundex":
+		return SoundexSimilarity, nil
+	case "metaphone", "double-metaphone":
+		return MetaphoneSimilarity, nil
+	}
+	return nil, fmt.Errorf("unknown similarity metric %q, expected one of %s", name, strings.Join(SimilarityMetrics, ", "))
+}
+
+// LevenshteinRatio normalizes the Levenshtein edit distance (with unit
+// costs) by the length of the longer string, 1 - distance/length.
+func LevenshteinRatio(a string, b string) float64 {
+	longest := len([]rune(a))
+	if n := len([]rune(b)); n > longest {
+		longest = n
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(Levenshtein(a, b, 1, 1, 1, true))/float64(longest)
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of a and b, it
+// favours strings that share a common prefix of up to four runes.
+func JaroWinkler(a string, b string) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) == 0 && len(s2) == 0 {
+		return 1
+	}
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0
+	}
+	window := len(s1)
+	if len(s2) > window {
+		window = len(s2)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+	matches := 0
+	for i := range s1 {
+		start, end := i-window, i+window+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(s2) {
+			end = len(s2)
+		}
+		for j := start; j < end; j++ {
+			if !matched2[j] && s1[i] == s2[j] {
+				matched1[i], matched2[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	// Count the transpositions of the matched runes
+	transpositions, j := 0, 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[j] {
+			j++
+		}
+		if s1[i] != s2[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions/2))/m) / 3
+	prefix := 0
+	for prefix < 4 && prefix < len(s1) && prefix < len(s2) && s1[prefix] == s2[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// similarityTokens splits s into words ignoring punctuation
+func similarityTokens(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// TokenSortRatio compares the words of a and b after sorting them so
+// word order doesn't matter (e.g. "Doiel, R. S." and "R. S. Doiel").
+func TokenSortRatio(a string, b string) float64 {
+	t1, t2 := similarityTokens(a), similarityTokens(b)
+	sort.Strings(t1)
+	sort.Strings(t2)
+	return LevenshteinRatio(strings.Join(t1, " "), strings.Join(t2, " "))
+}
+
+// TokenSetRatio compares the words a and b have in common with the
+// words of each, it scores 1 when the words of one are a subset of
+// the other's (e.g. "Doiel, R." and "R. S. Doiel").
+func TokenSetRatio(a string, b string) float64 {
+	set1, set2 := map[string]bool{}, map[string]bool{}
+	for _, t := range similarityTokens(a) {
+		set1[t] = true
+	}
+	for _, t := range similarityTokens(b) {
+		set2[t] = true
+	}
+	if len(set1) == 0 && len(set2) == 0 {
+		return 1
+	}
+	if len(set1) == 0 || len(set2) == 0 {
+		return 0
+	}
+	common, diff1, diff2 := []string{}, []string{}, []string{}
+	for t := range set1 {
+		if set2[t] {
+			common = append(common, t)
+		} else {
+			diff1 = append(diff1, t)
+		}
+	}
+	for t := range set2 {
+		if !set1[t] {
+			diff2 = append(diff2, t)
+		}
+	}
+	sort.Strings(common)
+	sort.Strings(diff1)
+	sort.Strings(diff2)
+	t0 := strings.Join(common, " ")
+	t1 := strings.TrimSpace(t0 + " " + strings.Join(diff1, " "))
+	t2 := strings.TrimSpace(t0 + " " + strings.Join(diff2, " "))
+	score := LevenshteinRatio(t1, t2)
+	if len(common) > 0 {
+		for _, s := range []float64{LevenshteinRatio(t0, t1), LevenshteinRatio(t0, t2)} {
+			if s > score {
+				score = s
+			}
+		}
+	}
+	return score
+}
+
+// NGramJaccard returns the Jaccard similarity (shared over combined) of
+// the sets of n-grams of a and b, the strings are padded so their
+// first and last runes form n-grams.
+func NGramJaccard(a string, b string, n int) float64 {
+	if n < 1 {
+		n = DefaultQGram
+	}
+	grams1, _ := qGrams(a, n)
+	grams2, _ := qGrams(b, n)
+	shared := 0
+	for gram := range grams1 {
+		if _, ok := grams2[gram]; ok {
+			shared++
+		}
+	}
+	union := len(grams1) + len(grams2) - shared
+	if union == 0 {
+		return 1
+	}
+	return float64(shared) / float64(union)
+}
+
+// phoneticSimilarity pairs the words of a and b which share a phonetic
+// key, returning twice the pairs found over the total number of words.
+func phoneticSimilarity(a string, b string, keys func(string) []string) float64 {
+	t1, t2 := similarityTokens(a), similarityTokens(b)
+	if len(t1) == 0 && len(t2) == 0 {
+		return 1
+	}
+	keys2 := make([][]string, len(t2))
+	for i, t := range t2 {
+		keys2[i] = keys(t)
+	}
+	used := make([]bool, len(t2))
+	pairs := 0
+	for _, t := range t1 {
+		keys1 := keys(t)
+	search:
+		for j := range t2 {
+			if used[j] {
+				continue
+			}
+			for _, k1 := range keys1 {
+				for _, k2 := range keys2[j] {
+					if k1 != "" && k1 == k2 {
+						used[j] = true
+						pairs++
+						break search
+					}
+				}
+			}
+		}
+	}
+	return float64(2*pairs) / float64(len(t1)+len(t2))
+}
+
+// SoundexSimilarity compares the Soundex codes of the words of a and b
+func SoundexSimilarity(a string, b string) float64 {
+	return phoneticSimilarity(a, b, func(word string) []string {
+		return []string{Soundex(word)}
+	})
+}
+
+// MetaphoneSimilarity compares the primary and alternate Double Metaphone
+// keys of the words of a and b
+func MetaphoneSimilarity(a string, b string) float64 {
+	return phoneticSimilarity(a, b, func(word string) []string {
+		primary, alternate := DoubleMetaphone(word)
+		return []string{primary, alternate}
+	})
+}
@@ -0,0 +1,61 @@
+package datatools
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 0.0001
+	}
+	if s := JaroWinkler("martha", "marhta"); !near(s, 0.9611) {
+		t.Errorf("JaroWinkler expected 0.9611, got %f", s)
+	}
+	if s := JaroWinkler("dwayne", "duane"); !near(s, 0.84) {
+		t.Errorf("JaroWinkler expected 0.84, got %f", s)
+	}
+	if s := JaroWinkler("abc", "xyz"); s != 0 {
+		t.Errorf("JaroWinkler expected 0, got %f", s)
+	}
+	if s := LevenshteinRatio("kitten", "sitting"); !near(s, 1-3.0/7.0) {
+		t.Errorf("LevenshteinRatio expected %f, got %f", 1-3.0/7.0, s)
+	}
+	if s := TokenSortRatio("doiel, r. s.", "r. s. doiel"); s != 1 {
+		t.Errorf("TokenSortRatio expected 1, got %f", s)
+	}
+	if s := TokenSetRatio("doiel, r.", "r. s. doiel"); s != 1 {
+		t.Errorf("TokenSetRatio expected 1, got %f", s)
+	}
+	if s := TokenSetRatio("doiel", "smith"); s >= 0.5 {
+		t.Errorf("TokenSetRatio expected a low score, got %f", s)
+	}
+	if s := NGramJaccard("night", "night", 3); s != 1 {
+		t.Errorf("NGramJaccard expected 1, got %f", s)
+	}
+	if s := NGramJaccard("abc", "xyz", 3); s != 0 {
+		t.Errorf("NGramJaccard expected 0, got %f", s)
+	}
+	if s := SoundexSimilarity("Doiel, Robert", "Rupert Doyle"); s != 1 {
+		t.Errorf("SoundexSimilarity expected 1, got %f", s)
+	}
+	if s := MetaphoneSimilarity("Smith, J", "Schmidt J"); s != 1 {
+		t.Errorf("MetaphoneSimilarity expected 1, got %f", s)
+	}
+	if s := MetaphoneSimilarity("Smith", "Jones"); s != 0 {
+		t.Errorf("MetaphoneSimilarity expected 0, got %f", s)
+	}
+	for _, name := range SimilarityMetrics {
+		fn, err := SimilarityMetric(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if s := fn("same value", "same value"); s != 1 {
+			t.Errorf("%s expected 1 for identical values, got %f", name, s)
+		}
+	}
+	if _, err := SimilarityMetric("unknown"); err == nil {
+		t.Errorf("expected an error for an unknown metric")
+	}
+}
@@ -0,0 +1,487 @@
+// sniff.go guesses the dialect (delimiter, quote character, header and
+// line ending) and the character encoding of CSV content from a sample.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// SniffSampleSize is the number of bytes SniffReader inspects
+	SniffSampleSize = 64 * 1024
+)
+
+var (
+	// sniffDelimiters are the candidate delimiters in order of preference
+	sniffDelimiters = []rune{',', '\t', ';', '|', ':'}
+
+	// cp1252 maps the bytes 0x80 to 0x9F of Windows-1252 to Unicode,
+	// the remaining bytes are the same as Latin-1 (ISO-8859-1)
+	cp1252 = [32]rune{
+		'€', '�', '‚', 'ƒ', '„', '…', '†', '‡',
+		'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+		'�', '‘', '’', '“', '”', '•', '–', '—',
+		'˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+	}
+)
+
+// CSVDialect describes how a CSV file was written as guessed by
+// SniffCSV or SniffReader.
+type CSVDialect struct {
+	// Delimiter is the cell separator, e.g. ",", "\t" or ";"
+	Delimiter string `json:"delimiter"`
+	// Quote is the character used to quote cells, `"` or `'`
+	Quote string `json:"quote"`
+	// HasHeader is true if the first row looks like column names
+	HasHeader bool `json:"has_header"`
+	// LineEnding is "LF", "CRLF" or "CR"
+	LineEnding string `json:"line_ending"`
+	// Encoding is one of "utf-8", "utf-16le", "utf-16be",
+	// "windows-1252" or "iso-8859-1"
+	Encoding string `json:"encoding"`
+	// BOM is true if the content started with a byte order mark
+	BOM bool `json:"bom"`
+	// Columns is the number of cells found in the first row
+	Columns int `json:"columns"`
+}
+
+// SniffCSV guesses the dialect and encoding of a complete sample of
+// CSV content.
+func SniffCSV(sample []byte) *CSVDialect {
+	return sniffCSV(sample, false)
+}
+
+// SniffReader reads a sample from the start of in and guesses its
+// dialect. It returns a reader of the full content transcoded to
+// UTF-8 with any byte order mark removed and with bare carriage
+// return line endings turned into line feeds so it can be handed
+// directly to csv.NewReader. Note encoding/csv only understands
+// double quotes, a dialect using single quotes is reported but not
+// translated.
+func SniffReader(in io.Reader) (io.Reader, *CSVDialect, error) {
+	buf := bufio.NewReaderSize(in, SniffSampleSize)
+	sample, err := buf.Peek(SniffSampleSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+	dialect := sniffCSV(sample, err == nil)
+	if dialect.BOM {
+		switch dialect.Encoding {
+		case "utf-8":
+			buf.Discard(3)
+		default:
+			buf.Discard(2)
+		}
+	}
+	if dialect.Encoding == "utf-8" && dialect.LineEnding != "CR" {
+		return buf, dialect, nil
+	}
+	return &transcoder{
+		r:      buf,
+		enc:    dialect.Encoding,
+		crToLF: dialect.LineEnding == "CR",
+	}, dialect, nil
+}
+
+// SniffInput applies the guess of SniffReader to the options of a
+// command reading CSV when sniff is true (-sniff), otherwise in,
+// delimiter and header are returned unchanged. The guessed delimiter
+// is used if delimiter is empty. The guess of a header row is used
+// unless the command line sets the option named headerFlag (e.g.
+// "header-row"), an empty headerFlag leaves header unchanged.
+func SniffInput(in io.Reader, sniff bool, delimiter string, header bool, headerFlag string) (io.Reader, string, bool, error) {
+	if !sniff {
+		return in, delimiter, header, nil
+	}
+	src, dialect, err := SniffReader(in)
+	if err != nil {
+		return nil, delimiter, header, err
+	}
+	if delimiter == "" {
+		delimiter = dialect.Delimiter
+	}
+	if headerFlag != "" {
+		// An explicit header option takes precedence over the guess
+		explicit := false
+		flag.Visit(func(f *flag.Flag) {
+			explicit = explicit || f.Name == headerFlag
+		})
+		if !explicit {
+			header = dialect.HasHeader
+		}
+	}
+	return src, delimiter, header, nil
+}
+
+// sniffCSV does the work of SniffCSV, truncated is true when the sample
+// may end in the middle of a row (or character).
+func sniffCSV(sample []byte, truncated bool) *CSVDialect {
+	dialect := &CSVDialect{
+		Delimiter:  ",",
+		Quote:      `"`,
+		LineEnding: "LF",
+	}
+	dialect.Encoding, dialect.BOM = sniffEncoding(sample, truncated)
+	if dialect.BOM {
+		if dialect.Encoding == "utf-8" {
+			sample = sample[3:]
+		} else {
+			sample = sample[2:]
+		}
+	}
+	text := decodeSample(sample, dialect.Encoding)
+
+	// Line endings, a file with no line ends at all is treated as LF
+	crlf := strings.Count(text, "\r\n")
+	lf := strings.Count(text, "\n") - crlf
+	cr := strings.Count(text, "\r") - crlf
+	switch {
+	case crlf > 0 && crlf >= lf:
+		dialect.LineEnding = "CRLF"
+	case lf > 0:
+		dialect.LineEnding = "LF"
+	case cr > 0:
+		dialect.LineEnding = "CR"
+		text = strings.ReplaceAll(text, "\r", "\n")
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	// Drop a row that may have been cut short by the sample size
+	if truncated {
+		if i := strings.LastIndex(text, "\n"); i > 0 {
+			text = text[:i+1]
+		}
+	}
+
+	delimiter := sniffDelimiter(text, '"')
+	dialect.Delimiter = string(delimiter)
+	if sniffQuote(text, delimiter) == '\'' {
+		dialect.Quote = "'"
+		if d := sniffDelimiter(text, '\''); d != delimiter {
+			delimiter = d
+			dialect.Delimiter = string(d)
+		}
+	}
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows := [][]string{}
+	for len(rows) < 21 {
+		row, err := r.Read()
+		if err != nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) > 0 {
+		dialect.Columns = len(rows[0])
+		dialect.HasHeader = sniffHeader(rows)
+	}
+	return dialect
+}
+
+// sniffEncoding looks for a byte order mark then checks if the sample
+// looks like UTF-16, is valid UTF-8 or otherwise falls back to one of
+// the single byte encodings.
+func sniffEncoding(sample []byte, truncated bool) (string, bool) {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8", true
+	case bytes.HasPrefix(sample, []byte{0xff, 0xfe}):
+		return "utf-16le", true
+	case bytes.HasPrefix(sample, []byte{0xfe, 0xff}):
+		return "utf-16be", true
+	}
+
+	// Mostly ASCII text encoded as UTF-16 has a NUL in every other byte
+	n := len(sample)
+	if n > 1024 {
+		n = 1024
+	}
+	even, odd := 0, 0
+	for i := 0; i < n; i++ {
+		if sample[i] == 0 {
+			if i%2 == 0 {
+				even++
+			} else {
+				odd++
+			}
+		}
+	}
+	if n >= 2 {
+		switch {
+		case odd > n/4 && even < n/20:
+			return "utf-16le", false
+		case even > n/4 && odd < n/20:
+			return "utf-16be", false
+		}
+	}
+
+	s := sample
+	if truncated {
+		// The sample may end part way through a multi-byte character
+		for i := 1; i < utf8.UTFMax && i <= len(s); i++ {
+			if utf8.RuneStart(s[len(s)-i]) {
+				if !utf8.FullRune(s[len(s)-i:]) {
+					s = s[:len(s)-i]
+				}
+				break
+			}
+		}
+	}
+	if utf8.Valid(s) {
+		return "utf-8", false
+	}
+	for _, b := range sample {
+		if b >= 0x80 && b <= 0x9f {
+			return "windows-1252", false
+		}
+	}
+	return "iso-8859-1", false
+}
+
+// decodeSample converts a sample into a UTF-8 string
+func decodeSample(sample []byte, encoding string) string {
+	if encoding == "utf-8" {
+		return string(sample)
+	}
+	t := &transcoder{r: bufio.NewReader(bytes.NewReader(sample)), enc: encoding}
+	src, _ := io.ReadAll(t)
+	return string(src)
+}
+
+// sniffDelimiter picks the candidate delimiter which is found the same
+// (non-zero) number of times outside of quotes on the most rows.
+func sniffDelimiter(text string, quote rune) rune {
+	best, bestScore, bestMode := sniffDelimiters[0], 0.0, 0
+	for _, delimiter := range sniffDelimiters {
+		counts := map[int]int{}
+		rows, cnt, inQuote := 0, 0, false
+		for _, c := range text {
+			switch {
+			case c == quote:
+				inQuote = !inQuote
+			case inQuote:
+			case c == delimiter:
+				cnt++
+			case c == '\n':
+				counts[cnt]++
+				rows++
+				cnt = 0
+			}
+		}
+		if cnt > 0 {
+			counts[cnt]++
+			rows++
+		}
+		mode, freq := 0, 0
+		for k, v := range counts {
+			if k > 0 && (v > freq || (v == freq && k > mode)) {
+				mode, freq = k, v
+			}
+		}
+		if mode == 0 {
+			continue
+		}
+		score := float64(freq) / float64(rows)
+		if score > bestScore || (score == bestScore && mode > bestMode) {
+			best, bestScore, bestMode = delimiter, score, mode
+		}
+	}
+	return best
+}
+
+// sniffQuote counts the cells wrapped in double and single quotes,
+// returning the more common of the two.
+func sniffQuote(text string, delimiter rune) rune {
+	double, single := 0, 0
+	for _, line := range strings.Split(text, "\n") {
+		for _, cell := range strings.Split(line, string(delimiter)) {
+			cell = strings.TrimSpace(cell)
+			if len(cell) < 2 {
+				continue
+			}
+			switch {
+			case cell[0] == '"' && cell[len(cell)-1] == '"':
+				double++
+			case cell[0] == '\'' && cell[len(cell)-1] == '\'':
+				single++
+			}
+		}
+	}
+	if single > double {
+		return '\''
+	}
+	return '"'
+}
+
+// sniffHeader votes column by column on whether the first row differs
+// from the rows that follow, either by being non-numeric in a numeric
+// column or by having a different length in a fixed length column.
+// When the vote is tied the first row is a header if its cells are
+// non-empty, non-numeric and unique.
+func sniffHeader(rows [][]string) bool {
+	header := rows[0]
+	isNumber := func(s string) bool {
+		_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return err == nil
+	}
+	votes := 0
+	if len(rows) > 1 {
+		for col, name := range header {
+			numeric, length := true, -1
+			for _, row := range rows[1:] {
+				if col >= len(row) {
+					continue
+				}
+				if !isNumber(row[col]) {
+					numeric = false
+				}
+				switch {
+				case length == -1:
+					length = utf8.RuneCountInString(row[col])
+				case length != utf8.RuneCountInString(row[col]):
+					length = -2
+				}
+			}
+			switch {
+			case numeric && length != -1:
+				if isNumber(name) {
+					votes--
+				} else {
+					votes++
+				}
+			case length >= 0:
+				if utf8.RuneCountInString(name) == length {
+					votes--
+				} else {
+					votes++
+				}
+			}
+		}
+	}
+	if votes != 0 {
+		return votes > 0
+	}
+	seen := map[string]bool{}
+	for _, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" || isNumber(name) || seen[name] {
+			return false
+		}
+		seen[name] = true
+	}
+	return true
+}
+
+// transcoder is an io.Reader converting UTF-16, Windows-1252 or
+// Latin-1 content into UTF-8, optionally turning carriage returns into
+// line feeds.
+type transcoder struct {
+	r      *bufio.Reader
+	enc    string
+	crToLF bool
+	buf    []byte
+	err    error
+}
+
+// Read implements io.Reader
+func (t *transcoder) Read(p []byte) (int, error) {
+	for len(t.buf) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		t.fill()
+	}
+	n := copy(p, t.buf)
+	t.buf = t.buf[n:]
+	return n, nil
+}
+
+// fill decodes the next block of input into buf
+func (t *transcoder) fill() {
+	for i := 0; i < 4096; i++ {
+		var c rune
+		switch t.enc {
+		case "utf-16le", "utf-16be":
+			u, err := t.readUnit()
+			if err != nil {
+				t.err = err
+				return
+			}
+			c = rune(u)
+			if utf16.IsSurrogate(c) {
+				u2, err := t.readUnit()
+				if err != nil {
+					t.err = err
+					c = utf8.RuneError
+				} else {
+					c = utf16.DecodeRune(c, rune(u2))
+				}
+			}
+		case "utf-8":
+			r, _, err := t.r.ReadRune()
+			if err != nil {
+				t.err = err
+				return
+			}
+			c = r
+		default:
+			b, err := t.r.ReadByte()
+			if err != nil {
+				t.err = err
+				return
+			}
+			c = rune(b)
+			if t.enc == "windows-1252" && b >= 0x80 && b <= 0x9f {
+				c = cp1252[b-0x80]
+			}
+		}
+		if c == '\r' && t.crToLF {
+			c = '\n'
+		}
+		t.buf = utf8.AppendRune(t.buf, c)
+	}
+}
+
+// readUnit reads a 16 bit code unit in the transcoder's byte order
+func (t *transcoder) readUnit() (uint16, error) {
+	b1, err := t.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	b2, err := t.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if t.enc == "utf-16be" {
+		return uint16(b1)<<8 | uint16(b2), nil
+	}
+	return uint16(b2)<<8 | uint16(b1), nil
+}
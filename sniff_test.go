@@ -0,0 +1,147 @@
+package datatools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestSniffCSV(t *testing.T) {
+	testData := []struct {
+		src        string
+		delimiter  string
+		quote      string
+		hasHeader  bool
+		lineEnding string
+		columns    int
+	}{
+		{"id,name,year\n1,Ada,1815\n2,Grace,1906\n", ",", `"`, true, "LF", 3},
+		{"1,Ada,1815\n2,Grace,1906\n3,Alan,1912\n", ",", `"`, false, "LF", 3},
+		{"id;name;note\r\n1;Ada;\"a; b\"\r\n2;Grace;c\r\n", ";", `"`, true, "CRLF", 3},
+		{"id\tname\n1\tAda, Countess\n2\tGrace\n", "\t", `"`, true, "LF", 2},
+		{"code|label\rAA|First\rBB|Second\r", "|", `"`, true, "CR", 2},
+		{"'id','name'\n'1','Ada'\n'2','Grace'\n", ",", "'", true, "LF", 2},
+		{"name\nAda\nGrace\n", ",", `"`, true, "LF", 1},
+	}
+	for i, test := range testData {
+		dialect := SniffCSV([]byte(test.src))
+		if dialect.Delimiter != test.delimiter {
+			t.Errorf("(%d) expected delimiter %q, got %q", i, test.delimiter, dialect.Delimiter)
+		}
+		if dialect.Quote != test.quote {
+			t.Errorf("(%d) expected quote %q, got %q", i, test.quote, dialect.Quote)
+		}
+		if dialect.HasHeader != test.hasHeader {
+			t.Errorf("(%d) expected has header %t, got %t", i, test.hasHeader, dialect.HasHeader)
+		}
+		if dialect.LineEnding != test.lineEnding {
+			t.Errorf("(%d) expected line ending %q, got %q", i, test.lineEnding, dialect.LineEnding)
+		}
+		if dialect.Columns != test.columns {
+			t.Errorf("(%d) expected %d columns, got %d", i, test.columns, dialect.Columns)
+		}
+		if dialect.Encoding != "utf-8" || dialect.BOM {
+			t.Errorf("(%d) expected utf-8 without BOM, got %+v", i, dialect)
+		}
+	}
+}
+
+func TestSniffReader(t *testing.T) {
+	expected := "name;city\nJosé;Zürich\n“Bob”;Köln\n"
+	utf16le := func(bom bool) []byte {
+		buf := []byte{}
+		if bom {
+			buf = append(buf, 0xff, 0xfe)
+		}
+		for _, u := range utf16.Encode([]rune(expected)) {
+			buf = append(buf, byte(u), byte(u>>8))
+		}
+		return buf
+	}
+	utf16be := []byte{0xfe, 0xff}
+	for _, u := range utf16.Encode([]rune(expected)) {
+		utf16be = append(utf16be, byte(u>>8), byte(u))
+	}
+	testData := []struct {
+		src      []byte
+		encoding string
+		bom      bool
+	}{
+		{[]byte(expected), "utf-8", false},
+		{append([]byte{0xef, 0xbb, 0xbf}, expected...), "utf-8", true},
+		{utf16le(true), "utf-16le", true},
+		{utf16le(false), "utf-16le", false},
+		{utf16be, "utf-16be", true},
+		{[]byte("name;city\nJos\xe9;Z\xfcrich\n\x93Bob\x94;K\xf6ln\n"), "windows-1252", false},
+	}
+	for i, test := range testData {
+		in, dialect, err := SniffReader(bytes.NewReader(test.src))
+		if err != nil {
+			t.Errorf("(%d) %s", i, err)
+			continue
+		}
+		if dialect.Encoding != test.encoding || dialect.BOM != test.bom {
+			t.Errorf("(%d) expected %s (BOM %t), got %+v", i, test.encoding, test.bom, dialect)
+		}
+		if dialect.Delimiter != ";" || !dialect.HasHeader {
+			t.Errorf("(%d) unexpected dialect %+v", i, dialect)
+		}
+		src, err := io.ReadAll(in)
+		if err != nil {
+			t.Errorf("(%d) %s", i, err)
+		}
+		if string(src) != expected {
+			t.Errorf("(%d) expected %q, got %q", i, expected, src)
+		}
+	}
+
+	// Latin-1 has no bytes in the 0x80 to 0x9F range
+	in, dialect, _ := SniffReader(strings.NewReader("a,b\n\xe9t\xe9,1\n"))
+	if dialect.Encoding != "iso-8859-1" {
+		t.Errorf("expected iso-8859-1, got %+v", dialect)
+	}
+	if src, _ := io.ReadAll(in); string(src) != "a,b\nété,1\n" {
+		t.Errorf("unexpected latin-1 transcoding %q", src)
+	}
+
+	// Bare carriage returns are turned into line feeds for encoding/csv
+	in, _, _ = SniffReader(strings.NewReader("a,b\r1,2\r3,4\r"))
+	rows, err := csv.NewReader(in).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Errorf("expected 3 rows, got %+v, %v", rows, err)
+	}
+
+	// A sample larger than SniffSampleSize is read in full
+	src := "id,value\n" + strings.Repeat("12345,abc\n", SniffSampleSize/5)
+	in, dialect, _ = SniffReader(strings.NewReader(src))
+	if !dialect.HasHeader || dialect.Columns != 2 {
+		t.Errorf("unexpected dialect %+v", dialect)
+	}
+	if got, _ := io.ReadAll(in); string(got) != src {
+		t.Errorf("expected %d bytes, got %d", len(src), len(got))
+	}
+}
+
+func TestSniffInput(t *testing.T) {
+	src := "name;born\nAda;1815\nGrace;1906\n"
+	in := strings.NewReader(src)
+	r, delimiter, header, err := SniffInput(in, false, "", false, "header-row")
+	if err != nil || r != in || delimiter != "" || header {
+		t.Errorf("expected options unchanged without sniff, got %q, %t, %v", delimiter, header, err)
+	}
+	r, delimiter, header, err = SniffInput(strings.NewReader(src), true, "", false, "header-row")
+	if err != nil || delimiter != ";" || !header {
+		t.Errorf("expected the guessed delimiter and header, got %q, %t, %v", delimiter, header, err)
+	}
+	if b, _ := io.ReadAll(r); string(b) != src {
+		t.Errorf("expected %q, got %q", src, b)
+	}
+	// An explicit delimiter is kept, an empty header flag leaves header alone
+	_, delimiter, header, err = SniffInput(strings.NewReader(src), true, "|", false, "")
+	if err != nil || delimiter != "|" || header {
+		t.Errorf("expected | and no header, got %q, %t, %v", delimiter, header, err)
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"database/sql"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	// 3rd Party packages
 	//sql "github.com/jmoiron/sqlx"
@@ -58,6 +63,10 @@ type SQLStore struct {
 
 	// The db handle of the opened connection
 	db *sql.DB
+
+	// emptyNull writes NULL as an empty cell, LoadCSV stores empty
+	// cells as NULL so CSV stores write them back the same way
+	emptyNull bool
 }
 
 func dsnFixUp(driverName string, dsn string, workPath string) string {
@@ -150,7 +159,9 @@ func (store *SQLStore) QueryToCSV(out *csv.Writer, stmt string) error {
 		}
 		for i := 0; i < len(columnTypes); i++ {
 			val := *vals[i].(*interface{})
-			if val == nil {
+			if val == nil && store.emptyNull {
+				cells[i] = ""
+			} else if val == nil {
 				// FIXME: this should be configurable as 'NULL' or empty
 				// string.
 				cells[i] = "NULL"
@@ -167,10 +178,10 @@ func (store *SQLStore) QueryToCSV(out *csv.Writer, stmt string) error {
 					cells[i] = fmt.Sprintf("%T", x)
 				case float32:
 					x := val.(float32)
-					cells[i] = fmt.Sprintf("%f", x)
+					cells[i] = strconv.FormatFloat(float64(x), 'f', -1, 32)
 				case float64:
 					x := val.(float64)
-					cells[i] = fmt.Sprintf("%f", x)
+					cells[i] = strconv.FormatFloat(x, 'f', -1, 64)
 				case int:
 					x := val.(int)
 					cells[i] = fmt.Sprintf("%d", x)
@@ -204,3 +215,582 @@ func (store *SQLStore) QueryToCSV(out *csv.Writer, stmt string) error {
 	}
 	return nil
 }
+
+// OpenCSVStore opens an in-memory SQLite3 database suitable for
+// loading CSV content with LoadCSV and querying it with QueryToCSV.
+// The tables only exist until the store is closed. NULL values are
+// written as empty cells.
+func OpenCSVStore() (*SQLStore, error) {
+	store, err := OpenSQLStore("sqlite://:memory:")
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: each connection to ":memory:" is a separate database so
+	// we limit the pool to a single connection.
+	store.db.SetMaxOpenConns(1)
+	store.emptyNull = true
+	return store, nil
+}
+
+// CSVTableName derives a SQL table name from a CSV filename, e.g.
+// "data/My Books.csv" becomes "My_Books".
+func CSVTableName(fName string) string {
+	name := strings.TrimSuffix(path.Base(fName), path.Ext(fName))
+	return sqlIdentifier(name, "csv")
+}
+
+// sqlIdentifier replaces characters that are awkward in a SQL
+// identifier with underscores, falling back to defaultName if
+// nothing usable remains.
+func sqlIdentifier(s string, defaultName string) string {
+	s = strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) || unicode.IsNumber(c) || c == '_' {
+			return c
+		}
+		return '_'
+	}, strings.TrimSpace(s))
+	if s == "" {
+		return defaultName
+	}
+	return s
+}
+
+// sqlColumnNames turns a CSV header row into a list of unique
+// column names safe to use as SQL identifiers.
+func sqlColumnNames(header []string) []string {
+	seen := map[string]int{}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = sqlIdentifier(name, fmt.Sprintf("col%d", i+1))
+		key := strings.ToLower(name)
+		if n, ok := seen[key]; ok {
+			seen[key] = n + 1
+			name = fmt.Sprintf("%s_%d", name, n+1)
+		} else {
+			seen[key] = 1
+		}
+		columns[i] = name
+	}
+	return columns
+}
+
+// quoteIdent quotes a table or column name for the store's database.
+func (store *SQLStore) quoteIdent(name string) string {
+	if store.driverName == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlValue converts a CSV cell into an int64 or float64 when it
+// round trips cleanly so numeric comparisons and aggregates behave
+// as expected, otherwise the cell is kept as a string.
+func sqlValue(cell string) interface{} {
+	if i, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		if strconv.FormatInt(i, 10) == cell {
+			return i
+		}
+		return cell
+	}
+	if strings.Trim(cell, "-.0123456789") != "" || strings.HasPrefix(strings.TrimPrefix(cell, "-"), "00") {
+		return cell
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil {
+		return f
+	}
+	return cell
+}
+
+// LoadCSV reads CSV content from r into a new table called tableName.
+// The first row provides the column names. Empty cells are stored as
+// NULL so aggregates skip them, short rows are padded with NULL and long
+// rows are truncated to the width of the header.
+func (store *SQLStore) LoadCSV(tableName string, r *csv.Reader) error {
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("%s has no header row", tableName)
+		}
+		return err
+	}
+	columns := sqlColumnNames(header)
+	params := make([]string, len(columns))
+	for i, name := range columns {
+		columns[i] = store.quoteIdent(name)
+		params[i] = "?"
+	}
+	// NOTE: columns are left without a declared type so SQLite3 keeps
+	// the numeric or text values provided by sqlValue().
+	stmt := fmt.Sprintf("CREATE TABLE %s (%s)", store.quoteIdent(tableName), strings.Join(columns, ", "))
+	if _, err := store.db.Exec(stmt); err != nil {
+		return err
+	}
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	insert, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", store.quoteIdent(tableName), strings.Join(columns, ", "), strings.Join(params, ", ")))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer insert.Close()
+	vals := make([]interface{}, len(header))
+	for lineNo := 2; ; lineNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s, row %d, %s", tableName, lineNo, err)
+		}
+		for i := 0; i < len(vals); i++ {
+			if i < len(row) && row[i] != "" {
+				vals[i] = sqlValue(row[i])
+			} else {
+				vals[i] = nil
+			}
+		}
+		if _, err := insert.Exec(vals...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s, row %d, %s", tableName, lineNo, err)
+		}
+	}
+	return tx.Commit()
+}
+
+const (
+	// Load modes used by CSVToSQL
+	SQLAppend  = "append"
+	SQLReplace = "replace"
+	SQLUpsert  = "upsert"
+
+	// maxSQLParams is kept below the smallest bound parameter limit
+	// of the supported databases (SQLite3's 32766).
+	maxSQLParams = 32000
+)
+
+// CSVToSQLCfg describes how CSV content is loaded into a SQL table
+// by CSVToSQL.
+type CSVToSQLCfg struct {
+	// Table is the name of the table to load
+	Table string `json:"table,omitempty"`
+	// Mode is either "append", "replace" or "upsert", defaults to "append"
+	Mode string `json:"mode,omitempty"`
+	// Key is the column name used to match rows in "upsert" mode, it
+	// becomes the primary key when a table is created.
+	Key string `json:"key,omitempty"`
+	// BatchSize is the number of rows sent in each INSERT statement
+	BatchSize int `json:"batch_size,omitempty"`
+	// InferRows is the number of rows examined to infer column types,
+	// zero examines all rows (and holds them in memory).
+	InferRows int `json:"infer_rows,omitempty"`
+	// Create will cause the table to be created if it doesn't exist.
+	// In "replace" mode the table is dropped first.
+	Create bool `json:"create,omitempty"`
+}
+
+// sqlBatch holds the parameters of the rows sent in one INSERT
+// statement. In "upsert" mode a batch holds each key once since
+// PostgreSQL can't update the same row twice in one statement.
+type sqlBatch struct {
+	vals []interface{}
+	rows int
+	keys map[string]bool
+}
+
+// add appends the values of a row, it returns false without adding
+// them if the batch already holds key (when keyed).
+func (batch *sqlBatch) add(vals []interface{}, key interface{}, keyed bool) bool {
+	if keyed {
+		k := fmt.Sprint(key)
+		if batch.keys[k] {
+			return false
+		}
+		if batch.keys == nil {
+			batch.keys = map[string]bool{}
+		}
+		batch.keys[k] = true
+	}
+	batch.vals = append(batch.vals, vals...)
+	batch.rows++
+	return true
+}
+
+// reset empties the batch
+func (batch *sqlBatch) reset() {
+	batch.vals, batch.rows, batch.keys = batch.vals[:0], 0, nil
+}
+
+// SQLColumn describes a column inferred from CSV content. Type is
+// one of "integer", "real", "date" or "text".
+type SQLColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// sqlCellType returns the narrowest type that describes a non-empty cell
+func sqlCellType(cell string) string {
+	switch sqlValue(cell).(type) {
+	case int64:
+		return "integer"
+	case float64:
+		return "real"
+	}
+	if _, err := time.Parse("2006-01-02", cell); err == nil {
+		return "date"
+	}
+	return "text"
+}
+
+// mergeCellType returns the narrowest type describing both a column
+// type seen so far (empty if none) and the type of another cell.
+func mergeCellType(colType string, cellType string) string {
+	switch {
+	case colType == "" || colType == cellType:
+		return cellType
+	case (colType == "integer" && cellType == "real") || (colType == "real" && cellType == "integer"):
+		return "real"
+	}
+	return "text"
+}
+
+// InferSQLColumns returns a column description for each cell in the
+// header based on the values found in rows. Empty cells are ignored,
+// columns mixing integers and decimals are "real", other mixes are "text".
+func InferSQLColumns(header []string, rows [][]string) []*SQLColumn {
+	names := sqlColumnNames(header)
+	columns := make([]*SQLColumn, len(names))
+	for i, name := range names {
+		colType := ""
+		for _, row := range rows {
+			if i >= len(row) || row[i] == "" {
+				continue
+			}
+			colType = mergeCellType(colType, sqlCellType(row[i]))
+			if colType == "text" {
+				break
+			}
+		}
+		if colType == "" {
+			colType = "text"
+		}
+		columns[i] = &SQLColumn{Name: name, Type: colType}
+	}
+	return columns
+}
+
+// sqlType maps an inferred column type to the store's database type
+func (store *SQLStore) sqlType(colType string, isKey bool) string {
+	switch colType {
+	case "integer":
+		if store.driverName == "sqlite" {
+			return "INTEGER"
+		}
+		return "BIGINT"
+	case "real":
+		switch store.driverName {
+		case "mysql":
+			return "DOUBLE"
+		case "postgres":
+			return "DOUBLE PRECISION"
+		}
+		return "REAL"
+	case "date":
+		return "DATE"
+	}
+	// NOTE: MySQL can't index a TEXT column without a prefix length
+	if store.driverName == "mysql" && isKey {
+		return "VARCHAR(255)"
+	}
+	return "TEXT"
+}
+
+// CreateTableStmt returns a CREATE TABLE statement for the columns
+// using the types of the store's database. If key is not empty it
+// is declared as the primary key.
+func (store *SQLStore) CreateTableStmt(tableName string, columns []*SQLColumn, key string) string {
+	defs := []string{}
+	for _, col := range columns {
+		def := fmt.Sprintf("%s %s", store.quoteIdent(col.Name), store.sqlType(col.Type, col.Name == key))
+		if col.Name == key {
+			def += " PRIMARY KEY"
+		}
+		defs = append(defs, def)
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", store.quoteIdent(tableName), strings.Join(defs, ",\n  "))
+}
+
+// insertStmt returns an INSERT statement for rowCount rows honoring
+// the load mode.
+func (store *SQLStore) insertStmt(tableName string, columns []*SQLColumn, rowCount int, mode string, key string) string {
+	names := []string{}
+	for _, col := range columns {
+		names = append(names, store.quoteIdent(col.Name))
+	}
+	values := []string{}
+	n := 1
+	for i := 0; i < rowCount; i++ {
+		params := []string{}
+		for range columns {
+			if store.driverName == "postgres" {
+				params = append(params, fmt.Sprintf("$%d", n))
+			} else {
+				params = append(params, "?")
+			}
+			n++
+		}
+		values = append(values, "("+strings.Join(params, ", ")+")")
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", store.quoteIdent(tableName), strings.Join(names, ", "), strings.Join(values, ", "))
+	if mode != SQLUpsert {
+		return stmt
+	}
+	updates := []string{}
+	for _, col := range columns {
+		if col.Name == key {
+			continue
+		}
+		name := store.quoteIdent(col.Name)
+		if store.driverName == "mysql" {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", name, name))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", name, name))
+		}
+	}
+	switch {
+	case store.driverName == "mysql" && len(updates) == 0:
+		return strings.Replace(stmt, "INSERT INTO", "INSERT IGNORE INTO", 1)
+	case store.driverName == "mysql":
+		return fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s", stmt, strings.Join(updates, ", "))
+	case len(updates) == 0:
+		return fmt.Sprintf("%s ON CONFLICT (%s) DO NOTHING", stmt, store.quoteIdent(key))
+	}
+	return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s", stmt, store.quoteIdent(key), strings.Join(updates, ", "))
+}
+
+// columnValue converts a CSV cell into a value for a column of colType.
+// Empty cells become NULL unless the column is text.
+func columnValue(cell string, colType string) (interface{}, error) {
+	if cell == "" {
+		if colType == "text" {
+			return "", nil
+		}
+		return nil, nil
+	}
+	switch colType {
+	case "integer":
+		return strconv.ParseInt(cell, 10, 64)
+	case "real":
+		return strconv.ParseFloat(cell, 64)
+	case "date":
+		if _, err := time.Parse("2006-01-02", cell); err != nil {
+			return nil, err
+		}
+	}
+	return cell, nil
+}
+
+// readCSVSample reads the header row and up to n rows (all rows if
+// n is zero) so column types can be inferred.
+func readCSVSample(r *csv.Reader, n int) ([]string, [][]string, error) {
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("missing header row")
+		}
+		return nil, nil, err
+	}
+	rows := [][]string{}
+	for n <= 0 || len(rows) < n {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("row %d, %s", len(rows)+2, err)
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, nil
+}
+
+// CSVToDDL reads the header and a sample of rows from r and returns
+// the CREATE TABLE statement CSVToSQL would use.
+func (store *SQLStore) CSVToDDL(r *csv.Reader, cfg *CSVToSQLCfg) (string, error) {
+	header, rows, err := readCSVSample(r, cfg.InferRows)
+	if err != nil {
+		return "", err
+	}
+	columns := InferSQLColumns(header, rows)
+	key, err := sqlKeyColumn(header, columns, cfg.Key)
+	if err != nil {
+		return "", err
+	}
+	return store.CreateTableStmt(cfg.Table, columns, key), nil
+}
+
+// sqlKeyColumn returns the column name of a key given as it appears
+// in the header or as the column name it becomes (e.g. "Item ID" or
+// "Item_ID"). An empty key is returned as is.
+func sqlKeyColumn(header []string, columns []*SQLColumn, key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	for i, col := range columns {
+		if col.Name == key || (i < len(header) && header[i] == key) {
+			return col.Name, nil
+		}
+	}
+	return "", fmt.Errorf("key column %q not found in header", key)
+}
+
+// CSVToSQL loads the CSV content read from r into a table. The first
+// row provides the column names, column types are inferred from the
+// first cfg.InferRows rows. All rows are inserted inside a single
+// transaction using multi-row INSERT statements of cfg.BatchSize rows.
+// Returns the number of rows loaded.
+func (store *SQLStore) CSVToSQL(r *csv.Reader, cfg *CSVToSQLCfg) (int, error) {
+	if cfg.Table == "" {
+		return 0, fmt.Errorf("missing table name")
+	}
+	mode := cfg.Mode
+	switch mode {
+	case "":
+		mode = SQLAppend
+	case SQLAppend, SQLReplace:
+	case SQLUpsert:
+		if cfg.Key == "" {
+			return 0, fmt.Errorf("upsert requires a key column")
+		}
+	default:
+		return 0, fmt.Errorf("unknown mode %q", mode)
+	}
+	header, rows, err := readCSVSample(r, cfg.InferRows)
+	if err != nil {
+		return 0, err
+	}
+	columns := InferSQLColumns(header, rows)
+	key, err := sqlKeyColumn(header, columns, cfg.Key)
+	if err != nil {
+		return 0, err
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if len(columns) > 0 && batchSize*len(columns) > maxSQLParams {
+		batchSize = maxSQLParams / len(columns)
+	}
+
+	// The table is dropped and created inside the transaction so a
+	// failed load leaves it as it was (MySQL commits DDL statements
+	// immediately so this only protects SQLite3 and PostgreSQL).
+	tx, err := store.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	if cfg.Create {
+		if mode == SQLReplace {
+			if _, err := tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", store.quoteIdent(cfg.Table))); err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+		}
+		if _, err := tx.Exec(store.CreateTableStmt(cfg.Table, columns, key)); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if mode == SQLReplace && !cfg.Create {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", store.quoteIdent(cfg.Table))); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	keyIndex := -1
+	if mode == SQLUpsert {
+		for i, col := range columns {
+			if col.Name == key {
+				keyIndex = i
+			}
+		}
+	}
+	count, lineNo := 0, 1
+	batch := new(sqlBatch)
+	// last is the line number of the last row in the batch
+	last := 0
+	flush := func() error {
+		if batch.rows == 0 {
+			return nil
+		}
+		if _, err := tx.Exec(store.insertStmt(cfg.Table, columns, batch.rows, mode, key), batch.vals...); err != nil {
+			return fmt.Errorf("rows %d to %d, %s", last-batch.rows+1, last, err)
+		}
+		count += batch.rows
+		batch.reset()
+		return nil
+	}
+	addRow := func(row []string) error {
+		lineNo++
+		vals := make([]interface{}, 0, len(columns))
+		for i, col := range columns {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			val, err := columnValue(cell, col.Type)
+			if err != nil {
+				return fmt.Errorf("row %d, column %q, %s", lineNo, col.Name, err)
+			}
+			vals = append(vals, val)
+		}
+		var keyVal interface{}
+		if keyIndex >= 0 {
+			keyVal = vals[keyIndex]
+		}
+		if !batch.add(vals, keyVal, keyIndex >= 0) {
+			// A repeated key starts a new batch so the later row wins
+			if err := flush(); err != nil {
+				return err
+			}
+			batch.add(vals, keyVal, true)
+		}
+		last = lineNo
+		if batch.rows >= batchSize {
+			return flush()
+		}
+		return nil
+	}
+	for _, row := range rows {
+		if err := addRow(row); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("row %d, %s", lineNo+1, err)
+		}
+		if err := addRow(row); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := flush(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	// SQL drivers
@@ -85,3 +86,260 @@ func TestSQLQueryToCSV(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestCSVQueryToCSV(t *testing.T) {
+	store, err := OpenCSVStore()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer store.Close()
+
+	books := `id,title,year
+1,The Hobbit,1937
+2,Canary Row,1945
+3,The Wee Free Men,2003
+4,Monstrous Regiment,2003
+`
+	authors := `book_id,name
+1,Tolkien
+2,Steinbeck
+3,Pratchett
+4,Pratchett
+`
+	if err := store.LoadCSV(CSVTableName("data/books.csv"), csv.NewReader(strings.NewReader(books))); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := store.LoadCSV("authors", csv.NewReader(strings.NewReader(authors))); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	stmt := `SELECT a.name, COUNT(*) AS total FROM books AS b
+JOIN authors AS a ON b.id = a.book_id
+WHERE b.year > 1940
+GROUP BY a.name ORDER BY a.name`
+	buf := bytes.NewBuffer([]byte{})
+	w := csv.NewWriter(buf)
+	if err := store.QueryToCSV(w, stmt); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := `name,total
+Pratchett,2
+Steinbeck,1
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestCSVStoreValues(t *testing.T) {
+	store, err := OpenCSVStore()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer store.Close()
+
+	prices := `id,price
+1,9.5
+2,10
+3,
+4,0.1234567891
+`
+	if err := store.LoadCSV("prices", csv.NewReader(strings.NewReader(prices))); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	buf := bytes.NewBuffer([]byte{})
+	w := csv.NewWriter(buf)
+	if err := store.QueryToCSV(w, `SELECT id, price FROM prices ORDER BY id`); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if got := buf.String(); got != prices {
+		t.Errorf("expected %q, got %q", prices, got)
+	}
+	buf.Reset()
+	if err := store.QueryToCSV(w, `SELECT avg(price) AS average, count(price) AS total FROM prices WHERE id < 4`); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := "average,total\n9.75,2\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestCSVTableName(t *testing.T) {
+	for fName, expected := range map[string]string{
+		"books.csv":         "books",
+		"data/My Books.csv": "My_Books",
+		"2024-exports.tab":  "2024_exports",
+		"no-extension":      "no_extension",
+	} {
+		if got := CSVTableName(fName); got != expected {
+			t.Errorf("for %q, expected %q, got %q", fName, expected, got)
+		}
+	}
+}
+
+func TestInferSQLColumns(t *testing.T) {
+	header := []string{"id", "price", "added", "zip", "notes", "empty"}
+	rows := [][]string{
+		{"1", "9", "2024-01-02", "02134", "", ""},
+		{"2", "10.5", "", "91125", "some text"},
+	}
+	expected := []string{"integer", "real", "date", "text", "text", "text"}
+	columns := InferSQLColumns(header, rows)
+	if len(columns) != len(expected) {
+		t.Errorf("expected %d columns, got %d", len(expected), len(columns))
+		t.FailNow()
+	}
+	for i, col := range columns {
+		if col.Name != header[i] {
+			t.Errorf("expected name %q, got %q", header[i], col.Name)
+		}
+		if col.Type != expected[i] {
+			t.Errorf("expected %q to be %q, got %q", col.Name, expected[i], col.Type)
+		}
+	}
+}
+
+func TestCSVToSQL(t *testing.T) {
+	store, err := OpenCSVStore()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer store.Close()
+
+	src := `id,title,year
+1,The Hobbit,1937
+2,Canary Row,1945
+3,The Wee Free Men,2003
+`
+	cfg := &CSVToSQLCfg{
+		Table:     "books",
+		Key:       "id",
+		Create:    true,
+		BatchSize: 2,
+	}
+	count, err := store.CSVToSQL(csv.NewReader(strings.NewReader(src)), cfg)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if count != 3 {
+		t.Errorf("expected 3 rows loaded, got %d", count)
+	}
+
+	// Upsert a correction and a new row
+	src = `id,title,year
+2,Cannery Row,1945
+4,Monstrous Regiment,2003
+`
+	cfg.Mode, cfg.Create = SQLUpsert, false
+	if _, err := store.CSVToSQL(csv.NewReader(strings.NewReader(src)), cfg); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	buf := bytes.NewBuffer([]byte{})
+	w := csv.NewWriter(buf)
+	if err := store.QueryToCSV(w, "SELECT id, title FROM books ORDER BY id"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := `id,title
+1,The Hobbit
+2,Cannery Row
+3,The Wee Free Men
+4,Monstrous Regiment
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	// A bad value should leave the table unchanged
+	src = `id,title,year
+5,Going Postal,2004
+6,Thud!,soon
+`
+	cfg = &CSVToSQLCfg{Table: "books", Mode: SQLReplace, InferRows: 1}
+	if _, err := store.CSVToSQL(csv.NewReader(strings.NewReader(src)), cfg); err == nil {
+		t.Errorf("expected an error loading %q", src)
+	}
+	buf.Reset()
+	if err := store.QueryToCSV(w, "SELECT COUNT(*) AS total FROM books"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if got := buf.String(); got != "total\n4\n" {
+		t.Errorf("expected table to be unchanged, got %q", got)
+	}
+
+	// Replacing with -create shouldn't drop the table if the load fails
+	cfg.Create = true
+	if _, err := store.CSVToSQL(csv.NewReader(strings.NewReader(src)), cfg); err == nil {
+		t.Errorf("expected an error loading %q", src)
+	}
+	buf.Reset()
+	if err := store.QueryToCSV(w, "SELECT COUNT(*) AS total FROM books"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if got := buf.String(); got != "total\n4\n" {
+		t.Errorf("expected table to be unchanged, got %q", got)
+	}
+
+	// The key may be named as it appears in the header
+	src = `Item ID,Name
+1,One
+1,Uno
+`
+	cfg = &CSVToSQLCfg{Table: "items", Key: "Item ID", Mode: SQLUpsert, Create: true}
+	if _, err := store.CSVToSQL(csv.NewReader(strings.NewReader(src)), cfg); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	buf.Reset()
+	if err := store.QueryToCSV(w, "SELECT Item_ID, Name FROM items"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if got := buf.String(); got != "Item_ID,Name\n1,Uno\n" {
+		t.Errorf("expected the upsert to keep one row, got %q", got)
+	}
+}
+
+func TestSQLBatch(t *testing.T) {
+	// A repeated key is refused so it can start a new batch, PostgreSQL
+	// rejects an upsert that updates the same row twice
+	batch := new(sqlBatch)
+	for i, test := range []struct {
+		key      interface{}
+		expected bool
+	}{
+		{int64(1), true},
+		{int64(2), true},
+		{int64(1), false},
+		{"1", false},
+	} {
+		if ok := batch.add([]interface{}{test.key, "x"}, test.key, true); ok != test.expected {
+			t.Errorf("%d, expected add(%v) to return %t", i, test.key, test.expected)
+		}
+	}
+	if batch.rows != 2 || len(batch.vals) != 4 {
+		t.Errorf("expected 2 rows and 4 values, got %d and %d", batch.rows, len(batch.vals))
+	}
+	batch.reset()
+	if !batch.add([]interface{}{int64(1), "y"}, int64(1), true) || batch.rows != 1 {
+		t.Errorf("expected a reset batch to accept key 1")
+	}
+	// Without a key every row is added
+	batch = new(sqlBatch)
+	if !batch.add([]interface{}{"a"}, nil, false) || !batch.add([]interface{}{"a"}, nil, false) {
+		t.Errorf("expected rows without a key to be added")
+	}
+}
@@ -0,0 +1,1206 @@
+// tableschema.go implements Frictionless Data Table Schema, casting CSV
+// cells to typed values, validating CSV content and inferring a draft schema.
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package datatools
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultInferRows is the number of rows InferTableSchema samples
+	DefaultInferRows = 1000
+)
+
+var (
+	// schemaTypes are the field types understood, mapped to their
+	// default format
+	schemaTypes = map[string]string{
+		"string":    "default",
+		"number":    "default",
+		"integer":   "default",
+		"boolean":   "default",
+		"object":    "default",
+		"array":     "default",
+		"date":      "default",
+		"time":      "default",
+		"datetime":  "default",
+		"year":      "default",
+		"yearmonth": "default",
+		"duration":  "default",
+		"geopoint":  "default",
+		"geojson":   "default",
+		"list":      "default",
+		"any":       "default",
+	}
+
+	// defaultTrueValues and defaultFalseValues are used when a boolean
+	// field doesn't list its own
+	defaultTrueValues  = []string{"true", "True", "TRUE", "1"}
+	defaultFalseValues = []string{"false", "False", "FALSE", "0"}
+
+	// jsonNumberRE matches the number syntax of RFC 8259
+	jsonNumberRE = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+	// anyDateLayouts are tried in order for dates with the format "any"
+	anyDateLayouts = []string{
+		"2006-01-02",
+		"2006/01/02",
+		"01/02/2006",
+		"1/2/2006",
+		"2 Jan 2006",
+		"2 January 2006",
+		"Jan 2, 2006",
+		"January 2, 2006",
+	}
+
+	// anyDatetimeLayouts are tried in order for datetimes with the
+	// format "any"
+	anyDatetimeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		time.RFC1123Z,
+		time.RFC1123,
+		time.RFC850,
+		time.ANSIC,
+	}
+
+	// strftimeLayouts maps strftime directives to Go time layouts
+	strftimeLayouts = map[byte]string{
+		'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2",
+		'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+		'b': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+		'z': "-0700", 'Z': "MST", 'f': "000000", 'j': "002",
+		'%': "%",
+	}
+
+	uuidRE     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	durationRE = regexp.MustCompile(`^P(\d+Y)?(\d+M)?(\d+W)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?$`)
+)
+
+// StringList holds a JSON value which may be either a single string or
+// an array of strings, e.g. a Table Schema primaryKey.
+type StringList []string
+
+// UnmarshalJSON accepts a string or an array of strings
+func (l *StringList) UnmarshalJSON(src []byte) error {
+	s := ""
+	if err := json.Unmarshal(src, &s); err == nil {
+		*l = []string{s}
+		return nil
+	}
+	a := []string{}
+	if err := json.Unmarshal(src, &a); err != nil {
+		return fmt.Errorf("expected a string or array of strings, %s", err)
+	}
+	*l = a
+	return nil
+}
+
+// TableSchema describes the fields of a CSV file following the
+// Frictionless Data Table Schema specification,
+// https://specs.frictionlessdata.io/table-schema/
+type TableSchema struct {
+	Fields        []*SchemaField `json:"fields"`
+	PrimaryKey    StringList     `json:"primaryKey,omitempty"`
+	ForeignKeys   []*ForeignKey  `json:"foreignKeys,omitempty"`
+	MissingValues []string       `json:"missingValues,omitempty"`
+
+	// missing is nil until Compile, afterwards it holds MissingValues
+	// or the default of an empty string
+	missing map[string]bool
+}
+
+// SchemaField describes a single column
+type SchemaField struct {
+	Name        string            `json:"name"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	Format      string            `json:"format,omitempty"`
+	TrueValues  []string          `json:"trueValues,omitempty"`
+	FalseValues []string          `json:"falseValues,omitempty"`
+	BareNumber  *bool             `json:"bareNumber,omitempty"`
+	DecimalChar string            `json:"decimalChar,omitempty"`
+	GroupChar   string            `json:"groupChar,omitempty"`
+	Delimiter   string            `json:"delimiter,omitempty"`
+	ItemType    string            `json:"itemType,omitempty"`
+	Constraints *FieldConstraints `json:"constraints,omitempty"`
+
+	layout  string
+	item    *SchemaField
+	pattern *regexp.Regexp
+	enum    map[string]bool
+	minimum interface{}
+	maximum interface{}
+}
+
+// FieldConstraints limit the values allowed in a field
+type FieldConstraints struct {
+	Required  bool          `json:"required,omitempty"`
+	Unique    bool          `json:"unique,omitempty"`
+	MinLength *int          `json:"minLength,omitempty"`
+	MaxLength *int          `json:"maxLength,omitempty"`
+	Minimum   interface{}   `json:"minimum,omitempty"`
+	Maximum   interface{}   `json:"maximum,omitempty"`
+	Pattern   string        `json:"pattern,omitempty"`
+	Enum      []interface{} `json:"enum,omitempty"`
+}
+
+// ForeignKey requires the values of Fields to be found in the
+// referenced fields of another resource, an empty Resource refers to
+// the table itself.
+type ForeignKey struct {
+	Fields    StringList          `json:"fields"`
+	Reference ForeignKeyReference `json:"reference"`
+}
+
+// ForeignKeyReference names the resource and fields a ForeignKey points at
+type ForeignKeyReference struct {
+	Resource string     `json:"resource"`
+	Fields   StringList `json:"fields"`
+}
+
+// SchemaViolation describes a single failure to conform to a schema
+type SchemaViolation struct {
+	// Row in the CSV file counting from one, the header is row 1
+	Row int `json:"row"`
+	// Column counting from one, zero if the violation is not about a cell
+	Column int `json:"column,omitempty"`
+	// Field name from the schema
+	Field string `json:"field,omitempty"`
+	// Value of the cell (or key) which failed
+	Value string `json:"value"`
+	// Error is the kind of check which failed, e.g. "type", "required",
+	// "unique", "enum", "pattern", "minimum", "primaryKey", "foreignKey"
+	Error string `json:"error"`
+	// Message explains the failure
+	Message string `json:"message"`
+}
+
+// ReadTableSchema reads a Table Schema from a JSON file and compiles it
+func ReadTableSchema(fName string) (*TableSchema, error) {
+	src, err := os.ReadFile(fName)
+	if err != nil {
+		return nil, err
+	}
+	schema := new(TableSchema)
+	if err := json.Unmarshal(src, schema); err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	if err := schema.Compile(); err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	return schema, nil
+}
+
+// Compile checks the schema's types, formats and constraints preparing
+// it for use by Cast and TableValidator.
+func (schema *TableSchema) Compile() error {
+	schema.missing = map[string]bool{}
+	if schema.MissingValues == nil {
+		schema.missing[""] = true
+	}
+	for _, s := range schema.MissingValues {
+		schema.missing[s] = true
+	}
+	names := map[string]bool{}
+	for _, field := range schema.Fields {
+		if field.Name == "" {
+			return fmt.Errorf("field without a name")
+		}
+		if names[field.Name] {
+			return fmt.Errorf("field %q is defined more than once", field.Name)
+		}
+		names[field.Name] = true
+		if err := field.compile(); err != nil {
+			return fmt.Errorf("field %q, %s", field.Name, err)
+		}
+	}
+	for _, name := range schema.PrimaryKey {
+		if !names[name] {
+			return fmt.Errorf("primary key field %q is not defined", name)
+		}
+	}
+	for _, fk := range schema.ForeignKeys {
+		if len(fk.Fields) == 0 || len(fk.Fields) != len(fk.Reference.Fields) {
+			return fmt.Errorf("foreign key %v must have the same number of fields as its reference %v", fk.Fields, fk.Reference.Fields)
+		}
+		for _, name := range fk.Fields {
+			if !names[name] {
+				return fmt.Errorf("foreign key field %q is not defined", name)
+			}
+		}
+	}
+	return nil
+}
+
+// IsMissing returns true if cell is one of the schema's missing values
+func (schema *TableSchema) IsMissing(cell string) bool {
+	if schema.missing == nil {
+		return cell == "" && schema.MissingValues == nil
+	}
+	return schema.missing[cell]
+}
+
+// Field returns the named field or nil
+func (schema *TableSchema) Field(name string) *SchemaField {
+	for _, field := range schema.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// compile works out the time layout for date and time types and casts
+// the constraint values to the field's type.
+func (field *SchemaField) compile() error {
+	if field.Type == "" {
+		field.Type = "string"
+	}
+	if _, ok := schemaTypes[field.Type]; !ok {
+		return fmt.Errorf("unknown type %q", field.Type)
+	}
+	format := field.Format
+	if format == "" {
+		format = "default"
+	}
+	switch field.Type {
+	case "string":
+		switch format {
+		case "default", "email", "uri", "binary", "uuid":
+		default:
+			return fmt.Errorf("unknown string format %q", format)
+		}
+	case "date", "time", "datetime":
+		switch {
+		case format == "default":
+			field.layout = map[string]string{
+				"date":     "2006-01-02",
+				"time":     "15:04:05",
+				"datetime": time.RFC3339Nano,
+			}[field.Type]
+		case format == "any":
+		default:
+			layout, err := strftimeLayout(strings.TrimPrefix(format, "fmt:"))
+			if err != nil {
+				return err
+			}
+			field.layout = layout
+		}
+	case "geopoint":
+		switch format {
+		case "default", "array", "object":
+		default:
+			return fmt.Errorf("unknown geopoint format %q", format)
+		}
+	case "list":
+		field.item = &SchemaField{Type: field.ItemType}
+		switch field.item.Type {
+		case "list", "object", "array", "geojson":
+			return fmt.Errorf("unsupported list item type %q", field.ItemType)
+		}
+		if err := field.item.compile(); err != nil {
+			return fmt.Errorf("item type, %s", err)
+		}
+	}
+	c := field.Constraints
+	if c == nil {
+		return nil
+	}
+	if c.Pattern != "" {
+		re, err := regexp.Compile(`^(?:` + c.Pattern + `)$`)
+		if err != nil {
+			return fmt.Errorf("pattern %s", err)
+		}
+		field.pattern = re
+	}
+	if c.Enum != nil {
+		field.enum = map[string]bool{}
+		for _, v := range c.Enum {
+			val, err := field.Cast(constraintString(v))
+			if err != nil {
+				return fmt.Errorf("enum value %v, %s", v, err)
+			}
+			field.enum[valueKey(val)] = true
+		}
+	}
+	var err error
+	if c.Minimum != nil {
+		if field.minimum, err = field.Cast(constraintString(c.Minimum)); err != nil {
+			return fmt.Errorf("minimum %v, %s", c.Minimum, err)
+		}
+	}
+	if c.Maximum != nil {
+		if field.maximum, err = field.Cast(constraintString(c.Maximum)); err != nil {
+			return fmt.Errorf("maximum %v, %s", c.Maximum, err)
+		}
+	}
+	return nil
+}
+
+// constraintString turns a JSON constraint value back into the text
+// form found in a CSV cell
+func constraintString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		src, _ := json.Marshal(v)
+		return string(src)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// valueKey gives a comparable string for a cast value
+func valueKey(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		src, _ := json.Marshal(v)
+		return string(src)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// strftimeLayout converts a strftime style pattern, e.g. "%d/%m/%Y",
+// into a Go time layout
+func strftimeLayout(format string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i >= len(format) {
+			return "", fmt.Errorf("format %q ends with %%", format)
+		}
+		layout, ok := strftimeLayouts[format[i]]
+		if !ok {
+			return "", fmt.Errorf("format %q, unsupported directive %%%c", format, format[i])
+		}
+		sb.WriteString(layout)
+	}
+	return sb.String(), nil
+}
+
+// Cast converts the text of a cell into the field's type. Integers are
+// returned as int64, numbers as float64, booleans as bool, dates and
+// times as time.Time, years as int64, objects and arrays as decoded
+// JSON, geopoints as []float64 (longitude, latitude), lists (cells
+// split on the field's delimiter, a comma by default) as a slice of
+// their item type and everything else as a string. A missing value
+// should be checked for before calling Cast.
+func (field *SchemaField) Cast(cell string) (interface{}, error) {
+	switch field.Type {
+	case "", "string":
+		return field.castString(cell)
+	case "integer":
+		return strconv.ParseInt(field.numberText(cell), 10, 64)
+	case "number":
+		s := field.numberText(cell)
+		switch s {
+		case "NaN":
+			return math.NaN(), nil
+		case "INF":
+			return math.Inf(1), nil
+		case "-INF":
+			return math.Inf(-1), nil
+		}
+		return strconv.ParseFloat(s, 64)
+	case "boolean":
+		trueValues, falseValues := field.TrueValues, field.FalseValues
+		if trueValues == nil {
+			trueValues = defaultTrueValues
+		}
+		if falseValues == nil {
+			falseValues = defaultFalseValues
+		}
+		for _, s := range trueValues {
+			if cell == s {
+				return true, nil
+			}
+		}
+		for _, s := range falseValues {
+			if cell == s {
+				return false, nil
+			}
+		}
+		return nil, fmt.Errorf("not a boolean")
+	case "date", "datetime":
+		if field.layout != "" {
+			return time.Parse(field.layout, cell)
+		}
+		layouts := anyDateLayouts
+		if field.Type == "datetime" {
+			layouts = anyDatetimeLayouts
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, cell); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("unrecognized %s", field.Type)
+	case "time":
+		if field.layout != "" {
+			return time.Parse(field.layout, cell)
+		}
+		for _, layout := range []string{"15:04:05", "15:04", "3:04 PM", "3:04PM", "3:04:05 PM"} {
+			if t, err := time.Parse(layout, cell); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("unrecognized time")
+	case "year":
+		if len(cell) != 4 {
+			return nil, fmt.Errorf("expected a four digit year")
+		}
+		return strconv.ParseInt(cell, 10, 64)
+	case "yearmonth":
+		return time.Parse("2006-01", cell)
+	case "duration":
+		if cell == "P" || strings.HasSuffix(cell, "T") || !durationRE.MatchString(cell) {
+			return nil, fmt.Errorf("expected an ISO 8601 duration")
+		}
+		return cell, nil
+	case "object", "geojson":
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(cell), &obj); err != nil {
+			return nil, fmt.Errorf("expected a JSON object")
+		}
+		return obj, nil
+	case "array":
+		a := []interface{}{}
+		if err := json.Unmarshal([]byte(cell), &a); err != nil {
+			return nil, fmt.Errorf("expected a JSON array")
+		}
+		return a, nil
+	case "geopoint":
+		return field.castGeopoint(cell)
+	case "list":
+		return field.castList(cell)
+	}
+	return cell, nil
+}
+
+// castList splits a cell on the field's delimiter casting each item
+func (field *SchemaField) castList(cell string) (interface{}, error) {
+	item := field.item
+	if item == nil {
+		item = &SchemaField{Type: field.ItemType}
+		if err := item.compile(); err != nil {
+			return nil, err
+		}
+	}
+	delimiter := field.Delimiter
+	if delimiter == "" {
+		delimiter = ","
+	}
+	list := []interface{}{}
+	if cell == "" {
+		return list, nil
+	}
+	for i, s := range strings.Split(cell, delimiter) {
+		val, err := item.Cast(s)
+		if err != nil {
+			return nil, fmt.Errorf("item %d, %s", i+1, err)
+		}
+		list = append(list, val)
+	}
+	return list, nil
+}
+
+// ColumnFields returns the schema field for each of the columns,
+// matched by the names in header or by position if header is empty.
+// Columns without a field are nil.
+func (schema *TableSchema) ColumnFields(header []string, columns int) []*SchemaField {
+	if len(header) > columns {
+		columns = len(header)
+	}
+	fields := make([]*SchemaField, columns)
+	for i := range fields {
+		switch {
+		case len(header) > 0 && i < len(header):
+			fields[i] = schema.Field(header[i])
+		case len(header) == 0 && i < len(schema.Fields):
+			fields[i] = schema.Fields[i]
+		}
+	}
+	return fields
+}
+
+// JSONValue casts a cell to a value ready for JSON encoding. Missing
+// values become nil, dates and times become ISO 8601 strings and
+// numbers JSON can't represent (NaN and the infinities) are left as
+// text. Integers and numbers are returned as json.Number so their
+// digits are kept as written, e.g. 12345678901234567890 isn't rounded
+// to a float64. Cells without a field (field is nil) are returned as is.
+func (schema *TableSchema) JSONValue(field *SchemaField, cell string) (interface{}, error) {
+	if field == nil {
+		return cell, nil
+	}
+	if schema.IsMissing(cell) {
+		return nil, nil
+	}
+	switch field.Type {
+	case "integer":
+		// Big integers are valid, big.Int also drops a "+" sign and
+		// leading zeros JSON doesn't allow
+		if n, ok := new(big.Int).SetString(field.numberText(cell), 10); ok {
+			return json.Number(n.String()), nil
+		}
+	case "number":
+		if s := field.numberText(cell); jsonNumberRE.MatchString(s) {
+			return json.Number(s), nil
+		}
+	}
+	val, err := field.Cast(cell)
+	if err != nil {
+		return cell, err
+	}
+	return jsonValue(field, val), nil
+}
+
+// jsonValue does the work of JSONValue on a cast value
+func jsonValue(field *SchemaField, val interface{}) interface{} {
+	switch v := val.(type) {
+	case time.Time:
+		switch field.Type {
+		case "date":
+			return v.Format("2006-01-02")
+		case "time":
+			return v.Format("15:04:05.999999999")
+		case "yearmonth":
+			return v.Format("2006-01")
+		}
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if field.Type == "number" {
+			return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	case []interface{}:
+		if field.Type == "list" {
+			item := field.item
+			if item == nil {
+				item = &SchemaField{Type: field.ItemType}
+			}
+			for i := range v {
+				v[i] = jsonValue(item, v[i])
+			}
+		}
+	}
+	return val
+}
+
+// castString checks the format of a string field
+func (field *SchemaField) castString(cell string) (interface{}, error) {
+	switch field.Format {
+	case "email":
+		if addr, err := mail.ParseAddress(cell); err != nil || addr.Address != cell {
+			return nil, fmt.Errorf("not an email address")
+		}
+	case "uri":
+		if u, err := url.Parse(cell); err != nil || u.Scheme == "" {
+			return nil, fmt.Errorf("not a URI")
+		}
+	case "uuid":
+		if !uuidRE.MatchString(cell) {
+			return nil, fmt.Errorf("not a UUID")
+		}
+	case "binary":
+		if _, err := base64.StdEncoding.DecodeString(cell); err != nil {
+			return nil, fmt.Errorf("not base64 encoded")
+		}
+	}
+	return cell, nil
+}
+
+// numberText removes group characters, swaps the decimal character
+// and, if bareNumber is false, strips leading and trailing
+// non-numeric text such as currency symbols or percent signs.
+func (field *SchemaField) numberText(cell string) string {
+	s := strings.TrimSpace(cell)
+	if field.GroupChar != "" {
+		s = strings.ReplaceAll(s, field.GroupChar, "")
+	}
+	if field.DecimalChar != "" && field.DecimalChar != "." {
+		s = strings.ReplaceAll(s, field.DecimalChar, ".")
+	}
+	if field.BareNumber != nil && !*field.BareNumber {
+		s = strings.TrimFunc(s, func(r rune) bool {
+			return !(r >= '0' && r <= '9') && r != '-' && r != '+' && r != '.'
+		})
+	}
+	return s
+}
+
+// castGeopoint parses "lon, lat", "[lon, lat]" or {"lon": .., "lat": ..}
+func (field *SchemaField) castGeopoint(cell string) (interface{}, error) {
+	var lon, lat float64
+	var err error
+	switch field.Format {
+	case "array":
+		a := []float64{}
+		if err = json.Unmarshal([]byte(cell), &a); err == nil && len(a) != 2 {
+			err = fmt.Errorf("expected two numbers")
+		}
+		if err == nil {
+			lon, lat = a[0], a[1]
+		}
+	case "object":
+		obj := map[string]float64{}
+		if err = json.Unmarshal([]byte(cell), &obj); err == nil {
+			lon, lat = obj["lon"], obj["lat"]
+		}
+	default:
+		parts := strings.Split(cell, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected \"lon, lat\"")
+		}
+		if lon, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64); err == nil {
+			lat, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		}
+	}
+	if err == nil && (lon < -180 || lon > 180 || lat < -90 || lat > 90) {
+		err = fmt.Errorf("longitude or latitude out of range")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("not a geopoint, %s", err)
+	}
+	return []float64{lon, lat}, nil
+}
+
+// compareValues compares two cast values of the same type returning
+// -1, 0 or 1, ok is false if they can't be ordered
+func compareValues(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b), true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	}
+	return 0, false
+}
+
+// valueLength is the length used by minLength and maxLength
+func valueLength(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case string:
+		return len([]rune(v)), true
+	case []interface{}:
+		return len(v), true
+	case map[string]interface{}:
+		return len(v), true
+	}
+	return 0, false
+}
+
+// check casts a cell and tests it against the field constraints, other
+// than unique, returning the cast value and the name of the check that
+// failed along with an explanation.
+func (field *SchemaField) check(cell string) (interface{}, string, string) {
+	val, err := field.Cast(cell)
+	if err != nil {
+		kind := field.Type
+		if field.Format != "" && field.Format != "default" {
+			kind = fmt.Sprintf("%s (%s)", field.Type, field.Format)
+		}
+		msg := fmt.Sprintf("expected %s", kind)
+		if ne, ok := err.(*strconv.NumError); ok {
+			err = ne.Err
+		}
+		return nil, "type", fmt.Sprintf("%s, %s", msg, err)
+	}
+	c := field.Constraints
+	if c == nil {
+		return val, "", ""
+	}
+	if field.pattern != nil && !field.pattern.MatchString(cell) {
+		return val, "pattern", fmt.Sprintf("does not match pattern %q", c.Pattern)
+	}
+	if field.enum != nil && !field.enum[valueKey(val)] {
+		return val, "enum", "not one of the allowed values"
+	}
+	if n, ok := valueLength(val); ok {
+		if c.MinLength != nil && n < *c.MinLength {
+			return val, "minLength", fmt.Sprintf("shorter than %d", *c.MinLength)
+		}
+		if c.MaxLength != nil && n > *c.MaxLength {
+			return val, "maxLength", fmt.Sprintf("longer than %d", *c.MaxLength)
+		}
+	}
+	if field.minimum != nil {
+		if cmp, ok := compareValues(val, field.minimum); ok && cmp < 0 {
+			return val, "minimum", fmt.Sprintf("less than %s", constraintString(c.Minimum))
+		}
+	}
+	if field.maximum != nil {
+		if cmp, ok := compareValues(val, field.maximum); ok && cmp > 0 {
+			return val, "maximum", fmt.Sprintf("greater than %s", constraintString(c.Maximum))
+		}
+	}
+	return val, "", ""
+}
+
+// TableValidator checks CSV content against a TableSchema
+type TableValidator struct {
+	// Schema to validate against, it must be compiled
+	Schema *TableSchema
+	// Resources maps the resource names used by foreign keys to CSV
+	// files. The file is read when the first row needing it is checked.
+	Resources map[string]string
+	// MaxViolations stops validation once reached, zero means no limit
+	MaxViolations int
+
+	report     func(*SchemaViolation) error
+	violations int
+	lookups    map[string]map[string]bool
+}
+
+// errMaxViolations stops validation once MaxViolations is reached
+var errMaxViolations = fmt.Errorf("maximum number of violations reached")
+
+// add passes a violation to the report function
+func (v *TableValidator) add(violation *SchemaViolation) error {
+	v.violations++
+	if err := v.report(violation); err != nil {
+		return err
+	}
+	if v.MaxViolations > 0 && v.violations >= v.MaxViolations {
+		return errMaxViolations
+	}
+	return nil
+}
+
+// Validate streams the CSV content of r, which must start with a
+// header row, passing each violation found to report. It returns the
+// number of violations. Fields are matched to columns by the names in
+// the header. Foreign keys which refer to the table itself are checked
+// after the last row.
+func (v *TableValidator) Validate(r *csv.Reader, report func(*SchemaViolation) error) (int, error) {
+	schema := v.Schema
+	if schema.missing == nil {
+		if err := schema.Compile(); err != nil {
+			return 0, err
+		}
+	}
+	r.FieldsPerRecord = -1
+	v.report, v.violations, v.lookups = report, 0, map[string]map[string]bool{}
+	err := v.validate(r)
+	if err == errMaxViolations {
+		err = nil
+	}
+	return v.violations, err
+}
+
+// validate does the work of Validate
+func (v *TableValidator) validate(r *csv.Reader) error {
+	schema := v.Schema
+	header, err := r.Read()
+	if err == io.EOF {
+		return fmt.Errorf("missing header row")
+	}
+	if err != nil {
+		return fmt.Errorf("row 1, %s", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+	colNo := make([]int, len(schema.Fields))
+	known := map[string]bool{}
+	for i, field := range schema.Fields {
+		known[field.Name] = true
+		col, ok := columns[field.Name]
+		if !ok {
+			colNo[i] = -1
+			if err := v.add(&SchemaViolation{Row: 1, Field: field.Name, Error: "missing-field", Message: "field not found in header"}); err != nil {
+				return err
+			}
+			continue
+		}
+		colNo[i] = col
+	}
+	for i, name := range header {
+		if !known[name] {
+			if err := v.add(&SchemaViolation{Row: 1, Column: i + 1, Value: name, Error: "extra-field", Message: "column not described by the schema"}); err != nil {
+				return err
+			}
+		}
+	}
+	fieldIndex := func(name string) int {
+		for i, field := range schema.Fields {
+			if field.Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+	pkIndex := []int{}
+	for _, name := range schema.PrimaryKey {
+		pkIndex = append(pkIndex, fieldIndex(name))
+	}
+	primaryKeys := map[string]int{}
+	unique := map[int]map[string]int{}
+	for i, field := range schema.Fields {
+		if field.Constraints != nil && field.Constraints.Unique {
+			unique[i] = map[string]int{}
+		}
+	}
+	type pendingKey struct {
+		row int
+		fk  *ForeignKey
+		key string
+	}
+	pending := []pendingKey{}
+	selfKeys := map[*ForeignKey]map[string]bool{}
+
+	for rowNo := 2; ; rowNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("row %d, %s", rowNo, err)
+		}
+		if len(row) > len(header) {
+			for i := len(header); i < len(row); i++ {
+				if row[i] != "" {
+					if err := v.add(&SchemaViolation{Row: rowNo, Column: i + 1, Value: row[i], Error: "extra-cell", Message: "cell beyond the last column of the header"}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		values := make([]string, len(schema.Fields))
+		missing := make([]bool, len(schema.Fields))
+		for i, field := range schema.Fields {
+			if colNo[i] < 0 {
+				missing[i] = true
+				continue
+			}
+			cell := cellAt(row, colNo[i])
+			values[i] = cell
+			violation := &SchemaViolation{Row: rowNo, Column: colNo[i] + 1, Field: field.Name, Value: cell}
+			if schema.IsMissing(cell) {
+				missing[i] = true
+				if field.Constraints != nil && field.Constraints.Required {
+					violation.Error, violation.Message = "required", "a value is required"
+					if err := v.add(violation); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			val, failed, msg := field.check(cell)
+			if failed != "" {
+				violation.Error, violation.Message = failed, msg
+				if err := v.add(violation); err != nil {
+					return err
+				}
+			}
+			if seen, ok := unique[i]; ok && val != nil {
+				key := valueKey(val)
+				if first, dup := seen[key]; dup {
+					violation := &SchemaViolation{Row: rowNo, Column: colNo[i] + 1, Field: field.Name, Value: cell, Error: "unique"}
+					violation.Message = fmt.Sprintf("duplicates the value in row %d", first)
+					if err := v.add(violation); err != nil {
+						return err
+					}
+				} else {
+					seen[key] = rowNo
+				}
+			}
+		}
+		if len(pkIndex) > 0 {
+			parts := []string{}
+			complete := true
+			for _, i := range pkIndex {
+				parts = append(parts, values[i])
+				complete = complete && !missing[i]
+			}
+			key := strings.Join(parts, "\x00")
+			violation := &SchemaViolation{Row: rowNo, Field: strings.Join(schema.PrimaryKey, ", "), Value: strings.Join(parts, ", "), Error: "primaryKey"}
+			if !complete {
+				violation.Message = "primary key has a missing value"
+			} else if first, dup := primaryKeys[key]; dup {
+				violation.Message = fmt.Sprintf("duplicates the primary key in row %d", first)
+			} else {
+				primaryKeys[key] = rowNo
+			}
+			if violation.Message != "" {
+				if err := v.add(violation); err != nil {
+					return err
+				}
+			}
+		}
+		for _, fk := range schema.ForeignKeys {
+			if fk.Reference.Resource == "" {
+				// Remember the referenced values of the table itself
+				if selfKeys[fk] == nil {
+					selfKeys[fk] = map[string]bool{}
+				}
+				if key, ok := keyOf(fk.Reference.Fields, fieldIndex, values, missing); ok {
+					selfKeys[fk][key] = true
+				}
+			}
+			key, ok := keyOf(fk.Fields, fieldIndex, values, missing)
+			if !ok {
+				continue
+			}
+			if fk.Reference.Resource == "" {
+				pending = append(pending, pendingKey{rowNo, fk, key})
+				continue
+			}
+			lookup, err := v.lookup(fk.Reference)
+			if err != nil {
+				return err
+			}
+			if !lookup[key] {
+				if err := v.add(foreignKeyViolation(rowNo, fk, key)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, p := range pending {
+		if !selfKeys[p.fk][p.key] {
+			if err := v.add(foreignKeyViolation(p.row, p.fk, p.key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keyOf joins the values of the named fields with a NUL so values
+// containing the separator can't collide, ok is false if one of them
+// is missing
+func keyOf(names []string, fieldIndex func(string) int, values []string, missing []bool) (string, bool) {
+	parts := []string{}
+	for _, name := range names {
+		i := fieldIndex(name)
+		if i < 0 || missing[i] {
+			return "", false
+		}
+		parts = append(parts, values[i])
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// foreignKeyViolation describes a key not found in its reference
+func foreignKeyViolation(rowNo int, fk *ForeignKey, key string) *SchemaViolation {
+	resource := fk.Reference.Resource
+	if resource == "" {
+		resource = "this table"
+	}
+	return &SchemaViolation{
+		Row:     rowNo,
+		Field:   strings.Join(fk.Fields, ", "),
+		Value:   strings.ReplaceAll(key, "\x00", ", "),
+		Error:   "foreignKey",
+		Message: fmt.Sprintf("not found in %s (%s)", resource, strings.Join(fk.Reference.Fields, ", ")),
+	}
+}
+
+// lookup reads the referenced fields of a resource into a set of keys
+func (v *TableValidator) lookup(ref ForeignKeyReference) (map[string]bool, error) {
+	id := ref.Resource + "\x00" + strings.Join(ref.Fields, "\x00")
+	if lookup, ok := v.lookups[id]; ok {
+		return lookup, nil
+	}
+	fName, ok := v.Resources[ref.Resource]
+	if !ok {
+		return nil, fmt.Errorf("foreign key resource %q has no file", ref.Resource)
+	}
+	fp, err := os.Open(fName)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	r := csv.NewReader(fp)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	cols := []int{}
+	for _, name := range ref.Fields {
+		col := -1
+		for i, s := range header {
+			if s == name {
+				col = i
+				break
+			}
+		}
+		if col < 0 {
+			return nil, fmt.Errorf("%s, %q column not found", fName, name)
+		}
+		cols = append(cols, col)
+	}
+	lookup := map[string]bool{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s", fName, err)
+		}
+		parts := []string{}
+		for _, col := range cols {
+			parts = append(parts, cellAt(row, col))
+		}
+		lookup[strings.Join(parts, "\x00")] = true
+	}
+	v.lookups[id] = lookup
+	return lookup, nil
+}
+
+// InferTableSchema reads a header row and up to sampleRows rows from r
+// (DefaultInferRows if sampleRows is zero or less) and returns a draft
+// schema using InferTableSchemaRows.
+func InferTableSchema(r *csv.Reader, sampleRows int) (*TableSchema, error) {
+	if sampleRows <= 0 {
+		sampleRows = DefaultInferRows
+	}
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("missing header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row 1, %s", err)
+	}
+	header = append([]string{}, header...)
+	rows := [][]string{}
+	for rowNo := 2; rowNo <= sampleRows+1; rowNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d, %s", rowNo, err)
+		}
+		rows = append(rows, append([]string{}, row...))
+	}
+	return InferTableSchemaRows(header, rows), nil
+}
+
+// InferTableSchemaRows returns a draft schema for the rows. Each field
+// is given the narrowest of the types integer, number, boolean, date,
+// datetime or string which fits every non-empty cell. Values with
+// leading zeros, e.g. "007", are kept as strings.
+func InferTableSchemaRows(header []string, rows [][]string) *TableSchema {
+	candidates := []string{"integer", "number", "boolean", "date", "datetime"}
+	probes := map[string]*SchemaField{}
+	for _, name := range candidates {
+		probes[name] = &SchemaField{Type: name}
+		probes[name].compile()
+	}
+	probes["datetime"].Format = "any"
+	probes["datetime"].layout = ""
+	probes["boolean"].TrueValues = []string{"true", "True", "TRUE"}
+	probes["boolean"].FalseValues = []string{"false", "False", "FALSE"}
+	viable := make([]map[string]bool, len(header))
+	seen := make([]bool, len(header))
+	for i := range header {
+		viable[i] = map[string]bool{}
+		for _, name := range candidates {
+			viable[i][name] = true
+		}
+	}
+	for _, row := range rows {
+		for i := range header {
+			cell := cellAt(row, i)
+			if cell == "" {
+				continue
+			}
+			seen[i] = true
+			leadingZero := len(cell) > 1 && cell[0] == '0' && cell[1] != '.'
+			for name := range viable[i] {
+				if _, err := probes[name].Cast(cell); err != nil || (leadingZero && (name == "integer" || name == "number")) {
+					delete(viable[i], name)
+				}
+			}
+		}
+	}
+	schema := &TableSchema{}
+	for i, name := range header {
+		field := &SchemaField{Name: name, Type: "string"}
+		if seen[i] {
+			for _, t := range candidates {
+				if viable[i][t] {
+					field.Type = t
+					break
+				}
+			}
+		}
+		if field.Type == "datetime" {
+			field.Format = "any"
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	return schema
+}
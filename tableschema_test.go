@@ -0,0 +1,261 @@
+package datatools
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaFieldCast(t *testing.T) {
+	bare := false
+	testData := []struct {
+		field    *SchemaField
+		cell     string
+		expected interface{}
+		ok       bool
+	}{
+		{&SchemaField{Type: "integer"}, "42", int64(42), true},
+		{&SchemaField{Type: "integer"}, "4.2", nil, false},
+		{&SchemaField{Type: "integer", GroupChar: ","}, "1,024", int64(1024), true},
+		{&SchemaField{Type: "number", BareNumber: &bare}, "$12.50", 12.5, true},
+		{&SchemaField{Type: "number", DecimalChar: ",", GroupChar: "."}, "1.234,5", 1234.5, true},
+		{&SchemaField{Type: "boolean"}, "TRUE", true, true},
+		{&SchemaField{Type: "boolean", TrueValues: []string{"yes"}}, "TRUE", nil, false},
+		{&SchemaField{Type: "date"}, "2024-02-29", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), true},
+		{&SchemaField{Type: "date", Format: "%d/%m/%Y"}, "29/02/2024", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), true},
+		{&SchemaField{Type: "date", Format: "any"}, "Feb 29, 2024", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), true},
+		{&SchemaField{Type: "date"}, "2023-02-29", nil, false},
+		{&SchemaField{Type: "year"}, "1999", int64(1999), true},
+		{&SchemaField{Type: "duration"}, "P1Y2M10DT2H30M", "P1Y2M10DT2H30M", true},
+		{&SchemaField{Type: "duration"}, "P", nil, false},
+		{&SchemaField{Type: "string", Format: "email"}, "jane@example.edu", "jane@example.edu", true},
+		{&SchemaField{Type: "string", Format: "email"}, "jane at example", nil, false},
+		{&SchemaField{Type: "string", Format: "uri"}, "https://example.edu/a", "https://example.edu/a", true},
+		{&SchemaField{Type: "string", Format: "uuid"}, "not-a-uuid", nil, false},
+		{&SchemaField{Type: "geopoint"}, "-118.1, 34.1", []float64{-118.1, 34.1}, true},
+		{&SchemaField{Type: "array"}, `[1, "a"]`, []interface{}{1.0, "a"}, true},
+	}
+	for i, test := range testData {
+		if err := test.field.compile(); err != nil {
+			t.Errorf("(%d) %s", i, err)
+			continue
+		}
+		val, err := test.field.Cast(test.cell)
+		if test.ok != (err == nil) {
+			t.Errorf("(%d) expected ok %t for %q, got %v", i, test.ok, test.cell, err)
+			continue
+		}
+		if test.ok && valueKey(val) != valueKey(test.expected) {
+			t.Errorf("(%d) expected %v, got %v", i, test.expected, val)
+		}
+	}
+}
+
+func TestTableValidator(t *testing.T) {
+	schemaSrc := `{
+    "fields": [
+        {"name": "id", "type": "integer"},
+        {"name": "name", "constraints": {"required": true, "maxLength": 10}},
+        {"name": "email", "format": "email", "constraints": {"unique": true}},
+        {"name": "status", "constraints": {"enum": ["active", "retired"]}},
+        {"name": "born", "type": "date", "constraints": {"minimum": "1800-01-01"}},
+        {"name": "code", "constraints": {"pattern": "[A-Z]{3}"}},
+        {"name": "manager", "type": "integer"},
+        {"name": "dept"}
+    ],
+    "primaryKey": "id",
+    "foreignKeys": [
+        {"fields": "manager", "reference": {"resource": "", "fields": "id"}},
+        {"fields": "dept", "reference": {"resource": "depts", "fields": "code"}}
+    ],
+    "missingValues": ["", "NA"]
+}`
+	schema := new(TableSchema)
+	if err := json.Unmarshal([]byte(schemaSrc), schema); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir := t.TempDir()
+	deptsName := path.Join(tmpDir, "depts.csv")
+	if err := os.WriteFile(deptsName, []byte("code,label\nLIB,Library\nARC,Archives\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	src := `id,name,email,status,born,code,manager,dept,notes
+1,Ada,ada@example.edu,active,1815-12-10,ABC,NA,LIB,
+2,Grace,grace@example.edu,retired,1906-12-09,DEF,1,ARC,
+x,NA,ada@example.edu,unknown,1700-01-01,abc,9,MUS,
+2,Alan Mathison Turing,alan@example.edu,active,1912-06-23,GHI,2,LIB,extra
+`
+	validator := &TableValidator{
+		Schema:    schema,
+		Resources: map[string]string{"depts": deptsName},
+	}
+	got := []string{}
+	n, err := validator.Validate(csv.NewReader(strings.NewReader(src)), func(v *SchemaViolation) error {
+		got = append(got, strings.Join([]string{v.Field, v.Error}, ":"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		":extra-field",
+		"id:type",
+		"name:required",
+		"email:unique",
+		"status:enum",
+		"born:minimum",
+		"code:pattern",
+		"dept:foreignKey",
+		"name:maxLength",
+		"id:primaryKey",
+		"manager:foreignKey",
+	}
+	if n != len(expected) || strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %d violations\n%s\ngot %d\n%s", len(expected), strings.Join(expected, " "), n, strings.Join(got, " "))
+	}
+
+	// MaxViolations stops early
+	validator.MaxViolations = 2
+	n, err = validator.Validate(csv.NewReader(strings.NewReader(src)), func(v *SchemaViolation) error { return nil })
+	if err != nil || n != 2 {
+		t.Errorf("expected 2 violations, got %d, %v", n, err)
+	}
+
+	// Composite keys whose values contain the separator don't collide
+	schema = new(TableSchema)
+	if err := json.Unmarshal([]byte(`{
+    "fields": [{"name": "a"}, {"name": "b"}],
+    "primaryKey": ["a", "b"],
+    "foreignKeys": [{"fields": ["a", "b"], "reference": {"resource": "pairs", "fields": ["x", "y"]}}]
+}`), schema); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	pairsName := path.Join(tmpDir, "pairs.csv")
+	if err := os.WriteFile(pairsName, []byte("x,y\n\"1, 2\",3\n1,\"2, 3\"\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	validator = &TableValidator{
+		Schema:    schema,
+		Resources: map[string]string{"pairs": pairsName},
+	}
+	src = `a,b
+"1, 2",3
+1,"2, 3"
+1,2
+`
+	got = []string{}
+	n, err = validator.Validate(csv.NewReader(strings.NewReader(src)), func(v *SchemaViolation) error {
+		got = append(got, fmt.Sprintf("%d:%s:%s", v.Row, v.Error, v.Value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || got[0] != "4:foreignKey:1, 2" {
+		t.Errorf("expected one foreignKey violation in row 4, got %d %q", n, got)
+	}
+}
+
+func TestInferTableSchema(t *testing.T) {
+	src := `id,zip,price,active,published,updated,title,empty
+1,01234,1.50,true,2020-01-02,2020-01-02T10:00:00Z,A,
+2,91125,2,false,2021-12-31,2021-12-31 08:30:00,B,
+3,91106,,TRUE,,,C,
+`
+	schema, err := InferTableSchema(csv.NewReader(strings.NewReader(src)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"integer", "string", "number", "boolean", "date", "datetime", "string", "string"}
+	for i, field := range schema.Fields {
+		if field.Type != expected[i] {
+			t.Errorf("expected %s to be %s, got %s", field.Name, expected[i], field.Type)
+		}
+	}
+	// The draft must validate the sample it came from
+	n, err := (&TableValidator{Schema: schema}).Validate(csv.NewReader(strings.NewReader(src)), func(v *SchemaViolation) error {
+		t.Errorf("unexpected violation %+v", v)
+		return nil
+	})
+	if err != nil || n != 0 {
+		t.Errorf("expected no violations, got %d, %v", n, err)
+	}
+}
+
+func TestTableSchemaJSONValue(t *testing.T) {
+	schemaSrc := `{
+    "fields": [
+        {"name": "id", "type": "integer"},
+        {"name": "published", "type": "date", "format": "%m/%d/%Y"},
+        {"name": "updated", "type": "datetime", "format": "any"},
+        {"name": "tags", "type": "list", "delimiter": ";"},
+        {"name": "scores", "type": "list", "itemType": "number"},
+        {"name": "ratio", "type": "number"}
+    ]
+}`
+	schema := new(TableSchema)
+	if err := json.Unmarshal([]byte(schemaSrc), schema); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"title", "id", "published", "updated", "tags", "scores", "ratio"}
+	row := []string{"A", "7", "02/29/2024", "2024-02-29 13:45:00", "x;y", "1.5,2", "NaN"}
+	fields := schema.ColumnFields(header, len(row))
+	if fields[0] != nil || fields[1].Name != "id" {
+		t.Fatalf("unexpected fields %+v", fields)
+	}
+	object := map[string]interface{}{}
+	for i, cell := range row {
+		val, err := schema.JSONValue(fields[i], cell)
+		if err != nil {
+			t.Errorf("%s, %s", header[i], err)
+		}
+		object[header[i]] = val
+	}
+	src, _ := json.Marshal(object)
+	expected := `{"id":7,"published":"2024-02-29","ratio":"NaN","scores":[1.5,2],"tags":["x","y"],"title":"A","updated":"2024-02-29T13:45:00Z"}`
+	if string(src) != expected {
+		t.Errorf("expected %s\ngot %s", expected, src)
+	}
+	if val, err := schema.JSONValue(fields[1], ""); val != nil || err != nil {
+		t.Errorf("expected nil for a missing value, got %v, %v", val, err)
+	}
+	if val, err := schema.JSONValue(fields[1], "seven"); val != "seven" || err == nil {
+		t.Errorf("expected an error and the cell, got %v, %v", val, err)
+	}
+	// Numbers keep their digits rather than being rounded to a float64
+	for _, test := range []struct {
+		field    *SchemaField
+		cell     string
+		expected string
+	}{
+		{fields[1], "9007199254740993", "9007199254740993"},
+		{fields[1], "12345678901234567890", "12345678901234567890"},
+		{fields[1], "+007", "7"},
+		{fields[6], "0.1000000000000000055511151231257827", "0.1000000000000000055511151231257827"},
+		{fields[6], "12345678901234567890", "12345678901234567890"},
+		{fields[6], ".5", "0.5"},
+	} {
+		val, err := schema.JSONValue(test.field, test.cell)
+		if err != nil {
+			t.Errorf("%q, %s", test.cell, err)
+			continue
+		}
+		if src, _ := json.Marshal(val); string(src) != test.expected {
+			t.Errorf("expected %q to be %s, got %s", test.cell, test.expected, src)
+		}
+	}
+}